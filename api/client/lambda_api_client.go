@@ -91,6 +91,27 @@ func (impl *LambdaAPIClient) Stats(ctx context.Context, token *api.Token, uid st
 	return
 }
 
+// Search stats records of the app from newest to oldest (UID in query is ignored)
+func (impl *LambdaAPIClient) QueryStats(ctx context.Context, token *api.Token, uid string, query stats.Query) (reply []stats.Record, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.QueryStats", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, query)
+	return
+}
+
+/*
+Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+Zero window means maximum available
+*/
+func (impl *LambdaAPIClient) Summary(ctx context.Context, token *api.Token, uid string, window types.JsonDuration) (reply *stats.Summary, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Summary", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, window)
+	return
+}
+
+// Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+func (impl *LambdaAPIClient) Logs(ctx context.Context, token *api.Token, uid string, after uint64, limit int) (reply []types.LogEntry, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Logs", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid, after, limit)
+	return
+}
+
 // Actions available for the app
 func (impl *LambdaAPIClient) Actions(ctx context.Context, token *api.Token, uid string) (reply []string, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "LambdaAPI.Actions", atomic.AddUint64(&impl.sequence, 1), &reply, token, uid)
@@ -5,6 +5,7 @@ import (
 	client "github.com/reddec/jsonrpc2/client"
 	api "github.com/reddec/trusted-cgi/api"
 	application "github.com/reddec/trusted-cgi/application"
+	types "github.com/reddec/trusted-cgi/types"
 	"sync/atomic"
 )
 
@@ -52,3 +53,27 @@ func (impl *PoliciesAPIClient) Clear(ctx context.Context, token *api.Token, lamb
 	err = client.CallHTTP(ctx, impl.BaseURL, "PoliciesAPI.Clear", atomic.AddUint64(&impl.sequence, 1), &reply, token, lambda)
 	return
 }
+
+// Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+func (impl *PoliciesAPIClient) IssueToken(ctx context.Context, token *api.Token, policy string, title string, ttl types.JsonDuration) (reply *application.IssuedToken, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "PoliciesAPI.IssueToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, policy, title, ttl)
+	return
+}
+
+// Rotate secret of the policy token. Secret is returned only once
+func (impl *PoliciesAPIClient) RotateToken(ctx context.Context, token *api.Token, policy string, tokenID string) (reply *application.IssuedToken, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "PoliciesAPI.RotateToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, policy, tokenID)
+	return
+}
+
+// Revoke (remove) policy token
+func (impl *PoliciesAPIClient) RevokeToken(ctx context.Context, token *api.Token, policy string, tokenID string) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "PoliciesAPI.RevokeToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, policy, tokenID)
+	return
+}
+
+// Enable or disable policy token
+func (impl *PoliciesAPIClient) EnableToken(ctx context.Context, token *api.Token, policy string, tokenID string, enabled bool) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "PoliciesAPI.EnableToken", atomic.AddUint64(&impl.sequence, 1), &reply, token, policy, tokenID, enabled)
+	return
+}
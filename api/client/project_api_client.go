@@ -6,6 +6,7 @@ import (
 	api "github.com/reddec/trusted-cgi/api"
 	application "github.com/reddec/trusted-cgi/application"
 	stats "github.com/reddec/trusted-cgi/stats"
+	types "github.com/reddec/trusted-cgi/types"
 	"sync/atomic"
 )
 
@@ -60,6 +61,18 @@ func (impl *ProjectAPIClient) Stats(ctx context.Context, token *api.Token, limit
 	return
 }
 
+// Search global stats records from newest to oldest
+func (impl *ProjectAPIClient) QueryStats(ctx context.Context, token *api.Token, query stats.Query) (reply []stats.Record, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.QueryStats", atomic.AddUint64(&impl.sequence, 1), &reply, token, query)
+	return
+}
+
+// Aggregated stats of the whole project and each app over window till now. Zero window means maximum available
+func (impl *ProjectAPIClient) Summary(ctx context.Context, token *api.Token, window types.JsonDuration) (reply *stats.ProjectSummary, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Summary", atomic.AddUint64(&impl.sequence, 1), &reply, token, window)
+	return
+}
+
 // Create new app (lambda)
 func (impl *ProjectAPIClient) Create(ctx context.Context, token *api.Token) (reply *application.Definition, err error) {
 	err = client.CallHTTP(ctx, impl.BaseURL, "ProjectAPI.Create", atomic.AddUint64(&impl.sequence, 1), &reply, token)
@@ -5,6 +5,7 @@ import (
 	client "github.com/reddec/jsonrpc2/client"
 	api "github.com/reddec/trusted-cgi/api"
 	application "github.com/reddec/trusted-cgi/application"
+	types "github.com/reddec/trusted-cgi/types"
 	"sync/atomic"
 )
 
@@ -46,3 +47,45 @@ func (impl *QueuesAPIClient) Assign(ctx context.Context, token *api.Token, name
 	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Assign", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, lambda)
 	return
 }
+
+// Failed requests (dead letters) of the queue
+func (impl *QueuesAPIClient) DeadLetters(ctx context.Context, token *api.Token, name string) (reply []types.DeadLetter, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.DeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+func (impl *QueuesAPIClient) ReplayDeadLetters(ctx context.Context, token *api.Token, name string, ids []string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.ReplayDeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, ids)
+	return
+}
+
+// Remove failed requests (all if IDs not set). Returns number of removed requests
+func (impl *QueuesAPIClient) PurgeDeadLetters(ctx context.Context, token *api.Token, name string, ids []string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.PurgeDeadLetters", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, ids)
+	return
+}
+
+// Depth, size in bytes and number of in-flight requests of the queue
+func (impl *QueuesAPIClient) Stats(ctx context.Context, token *api.Token, name string) (reply *application.QueueStats, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Stats", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+func (impl *QueuesAPIClient) Peek(ctx context.Context, token *api.Token, name string, limit int) (reply []types.QueueItem, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Peek", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, limit)
+	return
+}
+
+// Delete request from the queue by ID. In-flight requests can not be deleted
+func (impl *QueuesAPIClient) DeleteMessage(ctx context.Context, token *api.Token, name string, id uint64) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.DeleteMessage", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, id)
+	return
+}
+
+// Delete all not in-flight requests from the queue. Returns number of deleted requests
+func (impl *QueuesAPIClient) Purge(ctx context.Context, token *api.Token, name string) (reply int, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "QueuesAPI.Purge", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
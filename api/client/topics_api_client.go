@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	client "github.com/reddec/jsonrpc2/client"
+	api "github.com/reddec/trusted-cgi/api"
+	application "github.com/reddec/trusted-cgi/application"
+	"sync/atomic"
+)
+
+func DefaultTopicsAPI() *TopicsAPIClient {
+	return &TopicsAPIClient{BaseURL: "https://127.0.0.1:3434/u/"}
+}
+
+type TopicsAPIClient struct {
+	BaseURL  string
+	sequence uint64
+}
+
+// Create topic without subscribers
+func (impl *TopicsAPIClient) Create(ctx context.Context, token *api.Token, name string) (reply *application.Topic, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "TopicsAPI.Create", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// Remove topic. Subscribed queues are not affected
+func (impl *TopicsAPIClient) Remove(ctx context.Context, token *api.Token, name string) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "TopicsAPI.Remove", atomic.AddUint64(&impl.sequence, 1), &reply, token, name)
+	return
+}
+
+// List of all topics
+func (impl *TopicsAPIClient) List(ctx context.Context, token *api.Token) (reply []application.Topic, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "TopicsAPI.List", atomic.AddUint64(&impl.sequence, 1), &reply, token)
+	return
+}
+
+// Subscribe queue to topic: each published request will be copied to the queue
+func (impl *TopicsAPIClient) Subscribe(ctx context.Context, token *api.Token, name string, queue string) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "TopicsAPI.Subscribe", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, queue)
+	return
+}
+
+// Unsubscribe queue from topic
+func (impl *TopicsAPIClient) Unsubscribe(ctx context.Context, token *api.Token, name string, queue string) (reply bool, err error) {
+	err = client.CallHTTP(ctx, impl.BaseURL, "TopicsAPI.Unsubscribe", atomic.AddUint64(&impl.sequence, 1), &reply, token, name, queue)
+	return
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
+	stats "github.com/reddec/trusted-cgi/stats"
 	types "github.com/reddec/trusted-cgi/types"
 )
 
@@ -276,6 +277,73 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Stats(ctx, args.Arg0, args.Arg1, args.Arg2)
 	})
 
+	router.RegisterFunc("LambdaAPI.QueryStats", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token  `json:"token"`
+			Arg1 string      `json:"uid"`
+			Arg2 stats.Query `json:"query"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.QueryStats(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.Summary", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"uid"`
+			Arg2 types.JsonDuration `json:"window"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Summary(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("LambdaAPI.Logs", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"uid"`
+			Arg2 uint64     `json:"after"`
+			Arg3 int        `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Logs(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
 	router.RegisterFunc("LambdaAPI.Actions", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -362,5 +430,5 @@ func RegisterLambdaAPI(router *jsonrpc2.Router, wrap api.LambdaAPI, typeHandler
 		return wrap.Unlink(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"LambdaAPI.Upload", "LambdaAPI.Download", "LambdaAPI.Push", "LambdaAPI.Pull", "LambdaAPI.Remove", "LambdaAPI.Files", "LambdaAPI.Info", "LambdaAPI.Update", "LambdaAPI.CreateFile", "LambdaAPI.RemoveFile", "LambdaAPI.RenameFile", "LambdaAPI.Stats", "LambdaAPI.Actions", "LambdaAPI.Invoke", "LambdaAPI.Link", "LambdaAPI.Unlink"}
+	return []string{"LambdaAPI.Upload", "LambdaAPI.Download", "LambdaAPI.Push", "LambdaAPI.Pull", "LambdaAPI.Remove", "LambdaAPI.Files", "LambdaAPI.Info", "LambdaAPI.Update", "LambdaAPI.CreateFile", "LambdaAPI.RemoveFile", "LambdaAPI.RenameFile", "LambdaAPI.Stats", "LambdaAPI.QueryStats", "LambdaAPI.Summary", "LambdaAPI.Logs", "LambdaAPI.Actions", "LambdaAPI.Invoke", "LambdaAPI.Link", "LambdaAPI.Unlink"}
 }
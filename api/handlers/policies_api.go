@@ -7,6 +7,7 @@ import (
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
 	application "github.com/reddec/trusted-cgi/application"
+	types "github.com/reddec/trusted-cgi/types"
 )
 
 func RegisterPoliciesAPI(router *jsonrpc2.Router, wrap api.PoliciesAPI, typeHandler interface {
@@ -140,5 +141,95 @@ func RegisterPoliciesAPI(router *jsonrpc2.Router, wrap api.PoliciesAPI, typeHand
 		return wrap.Clear(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"PoliciesAPI.List", "PoliciesAPI.Create", "PoliciesAPI.Remove", "PoliciesAPI.Update", "PoliciesAPI.Apply", "PoliciesAPI.Clear"}
+	router.RegisterFunc("PoliciesAPI.IssueToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 string             `json:"policy"`
+			Arg2 string             `json:"title"`
+			Arg3 types.JsonDuration `json:"ttl"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.IssueToken(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	router.RegisterFunc("PoliciesAPI.RotateToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"policy"`
+			Arg2 string     `json:"tokenID"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RotateToken(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("PoliciesAPI.RevokeToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"policy"`
+			Arg2 string     `json:"tokenID"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.RevokeToken(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("PoliciesAPI.EnableToken", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"policy"`
+			Arg2 string     `json:"tokenID"`
+			Arg3 bool       `json:"enabled"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2, &args.Arg3)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.EnableToken(ctx, args.Arg0, args.Arg1, args.Arg2, args.Arg3)
+	})
+
+	return []string{"PoliciesAPI.List", "PoliciesAPI.Create", "PoliciesAPI.Remove", "PoliciesAPI.Update", "PoliciesAPI.Apply", "PoliciesAPI.Clear", "PoliciesAPI.IssueToken", "PoliciesAPI.RotateToken", "PoliciesAPI.RevokeToken", "PoliciesAPI.EnableToken"}
 }
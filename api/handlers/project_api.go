@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	jsonrpc2 "github.com/reddec/jsonrpc2"
 	api "github.com/reddec/trusted-cgi/api"
+	stats "github.com/reddec/trusted-cgi/stats"
+	types "github.com/reddec/trusted-cgi/types"
 )
 
 func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandler interface {
@@ -154,6 +156,48 @@ func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandle
 		return wrap.Stats(ctx, args.Arg0, args.Arg1)
 	})
 
+	router.RegisterFunc("ProjectAPI.QueryStats", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token  `json:"token"`
+			Arg1 stats.Query `json:"query"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.QueryStats(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("ProjectAPI.Summary", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token         `json:"token"`
+			Arg1 types.JsonDuration `json:"window"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Summary(ctx, args.Arg0, args.Arg1)
+	})
+
 	router.RegisterFunc("ProjectAPI.Create", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
 		var args struct {
 			Arg0 *api.Token `json:"token"`
@@ -216,5 +260,5 @@ func RegisterProjectAPI(router *jsonrpc2.Router, wrap api.ProjectAPI, typeHandle
 		return wrap.CreateFromGit(ctx, args.Arg0, args.Arg1)
 	})
 
-	return []string{"ProjectAPI.Config", "ProjectAPI.SetUser", "ProjectAPI.SetEnvironment", "ProjectAPI.AllTemplates", "ProjectAPI.List", "ProjectAPI.Templates", "ProjectAPI.Stats", "ProjectAPI.Create", "ProjectAPI.CreateFromTemplate", "ProjectAPI.CreateFromGit"}
+	return []string{"ProjectAPI.Config", "ProjectAPI.SetUser", "ProjectAPI.SetEnvironment", "ProjectAPI.AllTemplates", "ProjectAPI.List", "ProjectAPI.Templates", "ProjectAPI.Stats", "ProjectAPI.QueryStats", "ProjectAPI.Summary", "ProjectAPI.Create", "ProjectAPI.CreateFromTemplate", "ProjectAPI.CreateFromGit"}
 }
@@ -117,5 +117,156 @@ func RegisterQueuesAPI(router *jsonrpc2.Router, wrap api.QueuesAPI, typeHandler
 		return wrap.Assign(ctx, args.Arg0, args.Arg1, args.Arg2)
 	})
 
-	return []string{"QueuesAPI.Create", "QueuesAPI.Remove", "QueuesAPI.Linked", "QueuesAPI.List", "QueuesAPI.Assign"}
+	router.RegisterFunc("QueuesAPI.DeadLetters", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.DeadLetters(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("QueuesAPI.ReplayDeadLetters", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 []string   `json:"ids"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.ReplayDeadLetters(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("QueuesAPI.PurgeDeadLetters", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 []string   `json:"ids"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.PurgeDeadLetters(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("QueuesAPI.Stats", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Stats(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("QueuesAPI.Peek", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 int        `json:"limit"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Peek(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("QueuesAPI.DeleteMessage", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 uint64     `json:"id"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.DeleteMessage(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("QueuesAPI.Purge", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Purge(ctx, args.Arg0, args.Arg1)
+	})
+
+	return []string{"QueuesAPI.Create", "QueuesAPI.Remove", "QueuesAPI.Linked", "QueuesAPI.List", "QueuesAPI.Assign", "QueuesAPI.DeadLetters", "QueuesAPI.ReplayDeadLetters", "QueuesAPI.PurgeDeadLetters", "QueuesAPI.Stats", "QueuesAPI.Peek", "QueuesAPI.DeleteMessage", "QueuesAPI.Purge"}
 }
@@ -0,0 +1,121 @@
+// Code generated by jsonrpc2. DO NOT EDIT.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	jsonrpc2 "github.com/reddec/jsonrpc2"
+	api "github.com/reddec/trusted-cgi/api"
+)
+
+func RegisterTopicsAPI(router *jsonrpc2.Router, wrap api.TopicsAPI, typeHandler interface {
+	ValidateToken(ctx context.Context, value *api.Token) error
+}) []string {
+	router.RegisterFunc("TopicsAPI.Create", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Create(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("TopicsAPI.Remove", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Remove(ctx, args.Arg0, args.Arg1)
+	})
+
+	router.RegisterFunc("TopicsAPI.List", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.List(ctx, args.Arg0)
+	})
+
+	router.RegisterFunc("TopicsAPI.Subscribe", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 string     `json:"queue"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Subscribe(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	router.RegisterFunc("TopicsAPI.Unsubscribe", func(ctx context.Context, params json.RawMessage, positional bool) (interface{}, error) {
+		var args struct {
+			Arg0 *api.Token `json:"token"`
+			Arg1 string     `json:"name"`
+			Arg2 string     `json:"queue"`
+		}
+		var err error
+		if positional {
+			err = jsonrpc2.UnmarshalArray(params, &args.Arg0, &args.Arg1, &args.Arg2)
+		} else {
+			err = json.Unmarshal(params, &args)
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = typeHandler.ValidateToken(ctx, args.Arg0)
+		if err != nil {
+			return nil, err
+		}
+		return wrap.Unsubscribe(ctx, args.Arg0, args.Arg1, args.Arg2)
+	})
+
+	return []string{"TopicsAPI.Create", "TopicsAPI.Remove", "TopicsAPI.List", "TopicsAPI.Subscribe", "TopicsAPI.Unsubscribe"}
+}
@@ -1,5 +1,5 @@
 // Code generated by jsonrpc2. DO NOT EDIT.
-//go:generate jsonrpc2-gen -f ../../jsonrpc2.yaml -I UserAPI -I ProjectAPI -I LambdaAPI -I QueuesAPI -I PoliciesAPI
+//go:generate jsonrpc2-gen -f ../../jsonrpc2.yaml -I UserAPI -I ProjectAPI -I LambdaAPI -I QueuesAPI -I TopicsAPI -I PoliciesAPI
 package handlers
 
 import (
@@ -69,6 +69,13 @@ type LambdaAPI interface {
 	RenameFile(ctx context.Context, token *Token, uid string, oldPath, newPath string) (bool, error)
 	// Stats for the app
 	Stats(ctx context.Context, token *Token, uid string, limit int) ([]stats.Record, error)
+	// Search stats records of the app from newest to oldest (UID in query is ignored)
+	QueryStats(ctx context.Context, token *Token, uid string, query stats.Query) ([]stats.Record, error)
+	// Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+	// Zero window means maximum available
+	Summary(ctx context.Context, token *Token, uid string, window types.JsonDuration) (*stats.Summary, error)
+	// Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+	Logs(ctx context.Context, token *Token, uid string, after uint64, limit int) ([]types.LogEntry, error)
 	// Actions available for the app
 	Actions(ctx context.Context, token *Token, uid string) ([]string, error)
 	// Invoke action in the app (if make installed)
@@ -95,6 +102,10 @@ type ProjectAPI interface {
 	Templates(ctx context.Context, token *Token) ([]*Template, error)
 	// Global last records
 	Stats(ctx context.Context, token *Token, limit int) ([]stats.Record, error)
+	// Search global stats records from newest to oldest
+	QueryStats(ctx context.Context, token *Token, query stats.Query) ([]stats.Record, error)
+	// Aggregated stats of the whole project and each app over window till now. Zero window means maximum available
+	Summary(ctx context.Context, token *Token, window types.JsonDuration) (*stats.ProjectSummary, error)
 	// Create new app (lambda)
 	Create(ctx context.Context, token *Token) (*application.Definition, error)
 	// Create new app/lambda/function using pre-defined template
@@ -123,6 +134,34 @@ type QueuesAPI interface {
 	List(ctx context.Context, token *Token) ([]application.Queue, error)
 	// Assign lambda to queue (re-link)
 	Assign(ctx context.Context, token *Token, name string, lambda string) (bool, error)
+	// Failed requests (dead letters) of the queue
+	DeadLetters(ctx context.Context, token *Token, name string) ([]types.DeadLetter, error)
+	// Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+	ReplayDeadLetters(ctx context.Context, token *Token, name string, ids []string) (int, error)
+	// Remove failed requests (all if IDs not set). Returns number of removed requests
+	PurgeDeadLetters(ctx context.Context, token *Token, name string, ids []string) (int, error)
+	// Depth, size in bytes and number of in-flight requests of the queue
+	Stats(ctx context.Context, token *Token, name string) (*application.QueueStats, error)
+	// The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+	Peek(ctx context.Context, token *Token, name string, limit int) ([]types.QueueItem, error)
+	// Delete request from the queue by ID. In-flight requests can not be deleted
+	DeleteMessage(ctx context.Context, token *Token, name string, id uint64) (bool, error)
+	// Delete all not in-flight requests from the queue. Returns number of deleted requests
+	Purge(ctx context.Context, token *Token, name string) (int, error)
+}
+
+// API for managing topics
+type TopicsAPI interface {
+	// Create topic without subscribers
+	Create(ctx context.Context, token *Token, name string) (*application.Topic, error)
+	// Remove topic. Subscribed queues are not affected
+	Remove(ctx context.Context, token *Token, name string) (bool, error)
+	// List of all topics
+	List(ctx context.Context, token *Token) ([]application.Topic, error)
+	// Subscribe queue to topic: each published request will be copied to the queue
+	Subscribe(ctx context.Context, token *Token, name string, queue string) (bool, error)
+	// Unsubscribe queue from topic
+	Unsubscribe(ctx context.Context, token *Token, name string, queue string) (bool, error)
 }
 
 // API for managing policies
@@ -139,4 +178,12 @@ type PoliciesAPI interface {
 	Apply(ctx context.Context, token *Token, lambda string, policy string) (bool, error)
 	// Clear applied policy for the lambda
 	Clear(ctx context.Context, token *Token, lambda string) (bool, error)
+	// Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+	IssueToken(ctx context.Context, token *Token, policy string, title string, ttl types.JsonDuration) (*application.IssuedToken, error)
+	// Rotate secret of the policy token. Secret is returned only once
+	RotateToken(ctx context.Context, token *Token, policy string, tokenID string) (*application.IssuedToken, error)
+	// Revoke (remove) policy token
+	RevokeToken(ctx context.Context, token *Token, policy string, tokenID string) (bool, error)
+	// Enable or disable policy token
+	EnableToken(ctx context.Context, token *Token, policy string, tokenID string, enabled bool) (bool, error)
 }
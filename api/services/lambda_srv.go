@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
@@ -10,16 +11,18 @@ import (
 	"github.com/reddec/trusted-cgi/types"
 )
 
-func NewLambdaSrv(cases application.Cases, tracker stats.Reader) *lambdaSrv {
+func NewLambdaSrv(cases application.Cases, tracker stats.Reader, summaries stats.Summarizer) *lambdaSrv {
 	return &lambdaSrv{
-		cases:   cases,
-		tracker: tracker,
+		cases:     cases,
+		tracker:   tracker,
+		summaries: summaries,
 	}
 }
 
 type lambdaSrv struct {
-	cases   application.Cases
-	tracker stats.Reader
+	cases     application.Cases
+	tracker   stats.Reader
+	summaries stats.Summarizer
 }
 
 func (srv *lambdaSrv) Upload(ctx context.Context, token *api.Token, uid string, tarGz []byte) (bool, error) {
@@ -135,6 +138,23 @@ func (srv *lambdaSrv) Stats(ctx context.Context, token *api.Token, uid string, l
 	return srv.tracker.LastByUID(uid, limit)
 }
 
+func (srv *lambdaSrv) QueryStats(ctx context.Context, token *api.Token, uid string, query stats.Query) ([]stats.Record, error) {
+	query.UID = uid
+	return srv.tracker.Search(query)
+}
+
+func (srv *lambdaSrv) Summary(ctx context.Context, token *api.Token, uid string, window types.JsonDuration) (*stats.Summary, error) {
+	return srv.summaries.Summary(uid, time.Duration(window))
+}
+
+func (srv *lambdaSrv) Logs(ctx context.Context, token *api.Token, uid string, after uint64, limit int) ([]types.LogEntry, error) {
+	fn, err := srv.cases.Platform().FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+	return fn.Lambda.Logs(after, limit), nil
+}
+
 func (srv *lambdaSrv) Actions(ctx context.Context, token *api.Token, uid string) ([]string, error) {
 	fn, err := srv.cases.Platform().FindByUID(uid)
 	if err != nil {
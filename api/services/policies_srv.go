@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+	"time"
 )
 
 func NewPoliciesSrv(policies application.Policies) *policiesSrv {
@@ -41,3 +43,21 @@ func (srv *policiesSrv) Clear(ctx context.Context, token *api.Token, lambda stri
 	err := srv.policies.Clear(lambda)
 	return err == nil, err
 }
+
+func (srv *policiesSrv) IssueToken(ctx context.Context, token *api.Token, policy string, title string, ttl types.JsonDuration) (*application.IssuedToken, error) {
+	return srv.policies.IssueToken(policy, title, time.Duration(ttl))
+}
+
+func (srv *policiesSrv) RotateToken(ctx context.Context, token *api.Token, policy string, tokenID string) (*application.IssuedToken, error) {
+	return srv.policies.RotateToken(policy, tokenID)
+}
+
+func (srv *policiesSrv) RevokeToken(ctx context.Context, token *api.Token, policy string, tokenID string) (bool, error) {
+	err := srv.policies.RevokeToken(policy, tokenID)
+	return err == nil, err
+}
+
+func (srv *policiesSrv) EnableToken(ctx context.Context, token *api.Token, policy string, tokenID string, enabled bool) (bool, error) {
+	err := srv.policies.EnableToken(policy, tokenID, enabled)
+	return err == nil, err
+}
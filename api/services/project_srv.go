@@ -6,18 +6,22 @@ import (
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+	"time"
 )
 
-func NewProjectSrv(cases application.Cases, tracker stats.Reader) *projectSrv {
+func NewProjectSrv(cases application.Cases, tracker stats.Reader, summaries stats.Summarizer) *projectSrv {
 	return &projectSrv{
-		cases:   cases,
-		tracker: tracker,
+		cases:     cases,
+		tracker:   tracker,
+		summaries: summaries,
 	}
 }
 
 type projectSrv struct {
-	cases   application.Cases
-	tracker stats.Reader // for stats
+	cases     application.Cases
+	tracker   stats.Reader // for stats
+	summaries stats.Summarizer
 }
 
 func (srv *projectSrv) Create(ctx context.Context, token *api.Token) (*application.Definition, error) {
@@ -121,3 +125,11 @@ func (srv *projectSrv) Templates(ctx context.Context, token *api.Token) ([]*api.
 func (srv *projectSrv) Stats(ctx context.Context, token *api.Token, limit int) ([]stats.Record, error) {
 	return srv.tracker.Last(limit)
 }
+
+func (srv *projectSrv) QueryStats(ctx context.Context, token *api.Token, query stats.Query) ([]stats.Record, error) {
+	return srv.tracker.Search(query)
+}
+
+func (srv *projectSrv) Summary(ctx context.Context, token *api.Token, window types.JsonDuration) (*stats.ProjectSummary, error) {
+	return srv.summaries.ProjectSummary(time.Duration(window))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/reddec/trusted-cgi/api"
 	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 func NewQueuesSrv(queues application.Queues) *queuesSrv {
@@ -35,3 +36,32 @@ func (srv *queuesSrv) Assign(ctx context.Context, token *api.Token, name string,
 	err := srv.queues.Assign(name, lambda)
 	return err == nil, err
 }
+
+func (srv *queuesSrv) DeadLetters(ctx context.Context, token *api.Token, name string) ([]types.DeadLetter, error) {
+	return srv.queues.DeadLetters(name)
+}
+
+func (srv *queuesSrv) ReplayDeadLetters(ctx context.Context, token *api.Token, name string, ids []string) (int, error) {
+	return srv.queues.ReplayDeadLetters(name, ids)
+}
+
+func (srv *queuesSrv) PurgeDeadLetters(ctx context.Context, token *api.Token, name string, ids []string) (int, error) {
+	return srv.queues.PurgeDeadLetters(name, ids)
+}
+
+func (srv *queuesSrv) Stats(ctx context.Context, token *api.Token, name string) (*application.QueueStats, error) {
+	return srv.queues.Stats(name)
+}
+
+func (srv *queuesSrv) Peek(ctx context.Context, token *api.Token, name string, limit int) ([]types.QueueItem, error) {
+	return srv.queues.Peek(name, limit)
+}
+
+func (srv *queuesSrv) DeleteMessage(ctx context.Context, token *api.Token, name string, id uint64) (bool, error) {
+	err := srv.queues.DeleteMessage(name, id)
+	return err == nil, err
+}
+
+func (srv *queuesSrv) Purge(ctx context.Context, token *api.Token, name string) (int, error) {
+	return srv.queues.Purge(name)
+}
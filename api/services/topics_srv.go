@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"github.com/reddec/trusted-cgi/api"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+func NewTopicsSrv(topics application.Topics) *topicsSrv {
+	return &topicsSrv{topics: topics}
+}
+
+type topicsSrv struct {
+	topics application.Topics
+}
+
+func (srv *topicsSrv) Create(ctx context.Context, token *api.Token, name string) (*application.Topic, error) {
+	err := srv.topics.Add(name)
+	if err != nil {
+		return nil, err
+	}
+	return srv.topics.Get(name)
+}
+
+func (srv *topicsSrv) Remove(ctx context.Context, token *api.Token, name string) (bool, error) {
+	err := srv.topics.Remove(name)
+	return err == nil, err
+}
+
+func (srv *topicsSrv) List(ctx context.Context, token *api.Token) ([]application.Topic, error) {
+	return srv.topics.List(), nil
+}
+
+func (srv *topicsSrv) Subscribe(ctx context.Context, token *api.Token, name string, queue string) (bool, error) {
+	err := srv.topics.Subscribe(name, queue)
+	return err == nil, err
+}
+
+func (srv *topicsSrv) Unsubscribe(ctx context.Context, token *api.Token, name string, queue string) (bool, error) {
+	err := srv.topics.Unsubscribe(name, queue)
+	return err == nil, err
+}
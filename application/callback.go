@@ -0,0 +1,64 @@
+package application
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// CallbackAllowed checks that callback URL has same scheme and host as one of allowed callback URLs of queue,
+// and path of callback is equal to or nested in path of the allowed URL.
+func (q Queue) CallbackAllowed(callback string) bool {
+	target, err := parseCallbackURL(callback)
+	if err != nil {
+		return false
+	}
+	if clean := path.Clean("/" + target.Path); clean != target.Path && clean+"/" != target.Path {
+		return false // no relative segments
+	}
+	for prefix := range q.CallbackURLs {
+		allowed, err := parseCallbackURL(prefix)
+		if err != nil {
+			continue
+		}
+		if allowed.Scheme != target.Scheme || !strings.EqualFold(allowed.Host, target.Host) {
+			continue
+		}
+		base := strings.TrimSuffix(allowed.Path, "/")
+		if base == "" || target.Path == base || strings.HasPrefix(target.Path, base+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCallbackURLs validates allowed callback URLs of queue.
+func (q Queue) CheckCallbackURLs() error {
+	for prefix := range q.CallbackURLs {
+		if _, err := parseCallbackURL(prefix); err != nil {
+			return fmt.Errorf("callback URL %q: %w", prefix, err)
+		}
+	}
+	return nil
+}
+
+func parseCallbackURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("host is not defined")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("credentials are not allowed")
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, nil
+}
@@ -130,13 +130,15 @@ func (impl *casesImpl) Platform() application.Platform {
 	return impl.platform
 }
 
-func (impl *casesImpl) RunScheduledActions(ctx context.Context) {
+func (impl *casesImpl) RunScheduledActions(ctx context.Context) []types.ScheduleResult {
 	now := time.Now()
 	last := impl.lastScheduler
 	impl.lastScheduler = now
+	var results []types.ScheduleResult
 	for _, fn := range impl.platform.List() {
-		fn.Lambda.DoScheduled(ctx, last, impl.platform.Config().Environment) // FIXME: too much access into platform internals
+		results = append(results, fn.Lambda.DoScheduled(ctx, last, impl.platform.Config().Environment)...) // FIXME: too much access into platform internals
 	}
+	return results
 }
 
 func (impl *casesImpl) Templates() (map[string]*templates.Template, error) {
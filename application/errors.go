@@ -0,0 +1,26 @@
+package application
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrTooManyRequests    = errors.New("too many concurrent requests")        // concurrency limit reached and waiting queue is full
+	ErrWaitTimeout        = errors.New("timeout while waiting for free slot") // concurrency limit reached and waiting took too long
+	ErrRateLimited        = errors.New("rate limit exceeded")                 // policy rate limit reached
+	ErrMethodNotAllowed   = errors.New("method not allowed")                  // request method is not allowed by lambda manifest
+	ErrCallbackNotAllowed = errors.New("callback URL is not allowed")         // callback URL doesn't match allowed callback URLs of queue
+	ErrPayloadTooLarge    = errors.New("payload too large")                   // declared request size exceeds maximum payload of lambda
+)
+
+// RateLimitError is returned by policies when request rate limit is reached. Matches ErrRateLimited.
+type RateLimitError struct {
+	RetryAfter time.Duration // time till next allowed request
+}
+
+func (e *RateLimitError) Error() string {
+	return ErrRateLimited.Error() + ", retry after " + e.RetryAfter.String()
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
@@ -35,8 +35,8 @@ type Actions interface {
 	Actions() ([]string, error)
 	// Do target defined in Makefile. Time limit, global env and out can be nil.
 	Do(ctx context.Context, name string, timeLimit time.Duration, globalEnv map[string]string, out io.Writer) error
-	// Do scheduled actions based on last run
-	DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string)
+	// Do scheduled actions based on last run. Returns outcomes of invoked actions
+	DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string) []types.ScheduleResult
 }
 
 type Invokable interface {
@@ -68,6 +68,9 @@ type Lambda interface {
 	SetCredentials(creds *types.Credential) error
 	// Remove lambda
 	Remove() error
+	// Captured output of recent invocations, actions and processes with ID greater than after.
+	// Positive limit keeps only last entries
+	Logs(after uint64, limit int) []types.LogEntry
 }
 
 // Platform should index lambda, keep shared info (like env) and apply global configuration
@@ -121,8 +124,8 @@ type Cases interface {
 	Platform() Platform
 	// Get underlying queues manager
 	Queues() Queues
-	// Run scheduled actions from all lambda. Saves last run
-	RunScheduledActions(ctx context.Context)
+	// Run scheduled actions from all lambda. Saves last run. Returns outcomes of invoked actions
+	RunScheduledActions(ctx context.Context) []types.ScheduleResult
 	// List of all templates without availability check
 	Templates() (map[string]*templates.Template, error)
 	// Content of SSH public key if set
@@ -134,7 +137,8 @@ var QueueNameReg = regexp.MustCompile(`^[a-z0-9A-Z-]{3,64}$`)
 
 // Queues manager. Manages queues and linked worker
 type Queues interface {
-	// Put request to queue. If queue not exists, an error will be thrown
+	// Put request to queue. If queue not exists, an error will be thrown. If queue has result handling, new job ID
+	// is set to JobHeader of request
 	Put(queue string, request *types.Request) error
 	// Add new queue. See QueueNameReg for limitations
 	Add(queue Queue) error
@@ -148,6 +152,44 @@ type Queues interface {
 	Find(targetLambda string) []Queue
 	// Get queue by ID or return ErrNotExists
 	Get(queue string) (*Queue, error)
+	// Failed requests stored in dead-letter storage of the queue
+	DeadLetters(queue string) ([]types.DeadLetter, error)
+	// Put failed requests (all if IDs not set) from dead-letter storage back to the queue. Returns number of replayed requests
+	ReplayDeadLetters(queue string, ids []string) (int, error)
+	// Remove failed requests (all if IDs not set) from dead-letter storage. Returns number of removed requests
+	PurgeDeadLetters(queue string, ids []string) (int, error)
+	// Depth, size and number of in-flight requests of the queue
+	Stats(queue string) (*QueueStats, error)
+	// The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+	Peek(queue string, limit int) ([]types.QueueItem, error)
+	// Delete request from the queue by ID. In-flight requests can not be deleted
+	DeleteMessage(queue string, id uint64) error
+	// Delete all not in-flight requests from the queue. Returns number of deleted requests
+	Purge(queue string) (int, error)
+	// Job (status and result) of request in queue with store result mode
+	Job(queue string, id string) (*types.Job, error)
+}
+
+// Topic name limitations
+var TopicNameReg = regexp.MustCompile(`^[a-z0-9A-Z-]{3,64}$`)
+
+// Topics manager. Topic fans out published request to all subscribed queues, so each subscriber (lambda linked to
+// queue) processes own copy with own retries.
+type Topics interface {
+	// Publish request to all subscribed queues. If topic not exists, an error will be thrown
+	Publish(topic string, request *types.Request) error
+	// Add new topic without subscribers. See TopicNameReg for limitations
+	Add(topic string) error
+	// Remove topic. Subscribed queues are not affected
+	Remove(topic string) error
+	// Subscribe queue to topic
+	Subscribe(topic string, queue string) error
+	// Unsubscribe queue from topic
+	Unsubscribe(topic string, queue string) error
+	// List of all defined topics
+	List() []Topic
+	// Get topic by name
+	Get(topic string) (*Topic, error)
 }
 
 type Validator interface {
@@ -175,4 +217,12 @@ type Policies interface {
 	Get(policy string) (*Policy, error)
 	// Find policy by lambda
 	Find(lambda string) (*Policy, error)
+	// Issue new token for the policy. Zero TTL means token without expiration.
+	IssueToken(policy string, title string, ttl time.Duration) (*IssuedToken, error)
+	// Rotate secret of the token. Expiration is shifted by original lifetime.
+	RotateToken(policy string, tokenID string) (*IssuedToken, error)
+	// Revoke (remove) token
+	RevokeToken(policy string, tokenID string) error
+	// Enable or disable token
+	EnableToken(policy string, tokenID string, enabled bool) error
 }
@@ -0,0 +1,77 @@
+package lambda
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// headers which should not be exposed as HTTP_* variables (RFC 3875, section 4.1.18)
+var cgiExcludedHeaders = map[string]bool{
+	"Authorization":       true, // credentials are checked by policies
+	"Proxy-Authorization": true,
+	"Content-Type":        true, // exposed as CONTENT_TYPE
+	"Content-Length":      true, // exposed as CONTENT_LENGTH
+	"Proxy":               true, // httpoxy mitigation
+}
+
+// Standard CGI (RFC 3875) meta-variables for the request
+func cgiEnvironment(request types.Request) []string {
+	pathInfo := requestPathInfo(request.Path)
+	var scriptName, query string
+	if u, err := url.Parse(request.URL); err == nil {
+		scriptName = strings.TrimSuffix(u.Path, pathInfo)
+		query = u.RawQuery
+	}
+	remoteHost, remotePort, err := net.SplitHostPort(request.RemoteAddress)
+	if err != nil {
+		remoteHost = request.RemoteAddress
+	}
+	serverName, serverPort, err := net.SplitHostPort(request.Headers["Host"])
+	if err != nil {
+		serverName = request.Headers["Host"]
+	}
+
+	env := []string{
+		"GATEWAY_INTERFACE=CGI/1.1",
+		"SERVER_SOFTWARE=trusted-cgi",
+		"SERVER_PROTOCOL=HTTP/1.1",
+		"SERVER_NAME=" + serverName,
+		"SERVER_PORT=" + serverPort,
+		"REQUEST_METHOD=" + request.Method,
+		"REQUEST_URI=" + request.URL,
+		"SCRIPT_NAME=" + scriptName,
+		"PATH_INFO=" + pathInfo,
+		"QUERY_STRING=" + query,
+		"REMOTE_ADDR=" + remoteHost,
+		"REMOTE_HOST=" + remoteHost,
+		"REMOTE_PORT=" + remotePort,
+		"CONTENT_TYPE=" + request.Headers["Content-Type"],
+		"CONTENT_LENGTH=" + request.Headers["Content-Length"],
+	}
+	for header, value := range request.Headers {
+		if cgiExcludedHeaders[header] {
+			continue
+		}
+		if values := request.HeaderAll(header); len(values) > 1 {
+			separator := ", "
+			if header == "Cookie" {
+				separator = "; "
+			}
+			value = strings.Join(values, separator)
+		}
+		env = append(env, "HTTP_"+strings.ToUpper(strings.ReplaceAll(header, "-", "_"))+"="+value)
+	}
+	return env
+}
+
+// part of the request path after lambda UID or alias (with leading slash) or empty string
+func requestPathInfo(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if idx := strings.Index(path, "/"); idx >= 0 {
+		return path[idx:]
+	}
+	return ""
+}
@@ -8,11 +8,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/tracing"
 	"github.com/reddec/trusted-cgi/types"
 )
 
@@ -23,6 +27,13 @@ type localLambda struct {
 	manifest  types.Manifest
 	creds     *types.Credential
 	lock      sync.RWMutex
+
+	processLock sync.Mutex
+	workers     *workerPool
+	proxy       *proxyBackend
+	processEnv  map[string]string // global environment used for long-running processes
+
+	logs logStore
 }
 
 func (local *localLambda) UID() string { return local.uid }
@@ -41,6 +52,7 @@ func (local *localLambda) SetManifest(manifest types.Manifest) error {
 		return fmt.Errorf("save manifest: %w", err)
 	}
 	local.manifest = manifest
+	local.stopProcesses()
 	return nil
 }
 
@@ -55,82 +67,268 @@ func (local *localLambda) SetCredentials(creds *types.Credential) error {
 	defer local.lock.Unlock()
 	if !creds.Equal(local.creds) {
 		local.creds = creds
+		local.stopProcesses()
 		return local.applyFilesOwner()
 	}
 	return nil
 }
 
-func (local *localLambda) Invoke(ctx context.Context, request types.Request, response io.Writer, globalEnv map[string]string) error {
-	local.lock.RLock()
-	defer local.lock.RUnlock()
+func (local *localLambda) Logs(after uint64, limit int) []types.LogEntry {
+	return local.logs.After(after, limit)
+}
+
+func (local *localLambda) Invoke(ctx context.Context, request types.Request, response io.Writer, globalEnv map[string]string) (err error) {
 	defer request.Body.Close()
 
-	if local.staticDir != "" && request.Method == http.MethodGet {
-		return local.serveStaticFile(request, response)
+	ctx, span := tracing.Start(ctx, "process")
+	span.SetAttribute("lambda.uid", local.uid)
+	defer func() { span.End(err) }()
+
+	// invocation could be long (ex: websocket), so configuration is copied and lock is released before run;
+	// otherwise changes of manifest or credentials (and all new invocations) would wait for it
+	local.lock.RLock()
+	manifest, creds, staticDir := local.manifest, local.creds, local.staticDir
+	var backend *proxyBackend
+	var pool *workerPool
+	if manifest.Proxy != nil {
+		backend = local.proxyBackend(globalEnv)
+	} else if manifest.Workers != nil {
+		pool = local.workerPool(globalEnv)
+	}
+	local.lock.RUnlock()
+
+	if staticDir != "" && request.Method == http.MethodGet {
+		return local.serveStaticFile(staticDir, request, response)
 	}
 
-	if len(local.manifest.Run) == 0 {
+	if len(manifest.Run) == 0 && manifest.Proxy == nil {
 		return fmt.Errorf("run is not defined in manifest")
 	}
 
-	if local.manifest.Method != "" && local.manifest.Method != local.manifest.Method {
-		return fmt.Errorf("method not allowed")
+	if manifest.Method != "" && !strings.EqualFold(manifest.Method, request.Method) {
+		return fmt.Errorf("%w: %s", application.ErrMethodNotAllowed, request.Method)
 	}
 
-	if local.manifest.TimeLimit > 0 {
-		cctx, cancel := context.WithTimeout(ctx, time.Duration(local.manifest.TimeLimit))
+	if manifest.TimeLimit > 0 {
+		cctx, cancel := context.WithTimeout(ctx, time.Duration(manifest.TimeLimit))
 		defer cancel()
 		ctx = cctx
 	}
 
 	var input io.Reader = request.Body
 
-	if local.manifest.MaximumPayload > 0 {
-		input = io.LimitReader(input, local.manifest.MaximumPayload)
+	if manifest.MaximumPayload > 0 {
+		input = io.LimitReader(input, manifest.MaximumPayload)
 	}
 
-	cmd := exec.CommandContext(ctx, local.manifest.Run[0], local.manifest.Run[1:]...)
+	if backend != nil {
+		// declared size is passed to backend as is, so body should not be cut by limit
+		if size, err := strconv.ParseInt(request.Headers["Content-Length"], 10, 64); err == nil && manifest.MaximumPayload > 0 && size > manifest.MaximumPayload {
+			return fmt.Errorf("%w: %d bytes", application.ErrPayloadTooLarge, size)
+		}
+		err := backend.Invoke(ctx, request, input, response)
+		if err != nil {
+			return fmt.Errorf("proxy failed: %w", err)
+		}
+		return nil
+	}
+
+	if pool != nil {
+		return invokeWorker(ctx, pool, manifest, request, input, response)
+	}
+
+	cmd := exec.CommandContext(ctx, manifest.Run[0], manifest.Run[1:]...)
 	cmd.Dir = local.rootDir
-	cmd.Stdin = input
 	cmd.Stdout = response
-	cmd.Stderr = os.Stderr
-	internal.SetCreds(cmd, local.creds)
+	if manifest.WebSocket != nil {
+		// input is a stream of messages which could be never finished - do not wait for it after process exit
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("create stdin: %w", err)
+		}
+		go func() {
+			_, _ = io.Copy(stdin, input)
+			_ = stdin.Close()
+		}()
+	} else {
+		cmd.Stdin = input
+	}
+	var stderr logBuffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	internal.SetCreds(cmd, creds)
 	internal.SetFlags(cmd)
 	var environments = os.Environ()
 	for header, mapped := range globalEnv {
 		environments = append(environments, header+"="+mapped)
 	}
-	for header, mapped := range local.manifest.InputHeaders {
+	environments = append(environments, requestEnvironment(ctx, manifest, request)...)
+	for k, v := range manifest.Environment {
+		environments = append(environments, k+"="+v)
+	}
+	cmd.Env = environments
+	release, err := internal.SetSandbox(cmd, manifest.Sandbox, local.rootDir)
+	if err != nil {
+		return fmt.Errorf("prepare sandbox: %w", err)
+	}
+	defer release()
+	begin := time.Now()
+	err = cmd.Run()
+	local.logs.Record(types.LogRequest, "", begin, &stderr, err)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("run failed: %w: %w", ctx.Err(), err) // keep kill reason (timeout or cancel)
+	}
+	if err != nil {
+		return fmt.Errorf("run failed: %w", err)
+	}
+	return nil
+}
+
+// environment variables specific for the request
+func requestEnvironment(ctx context.Context, manifest types.Manifest, request types.Request) []string {
+	var environments []string
+	if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+		environments = append(environments, "TRACEPARENT="+traceparent)
+	}
+	if manifest.CGI {
+		environments = append(environments, cgiEnvironment(request)...)
+	}
+	for header, mapped := range manifest.InputHeaders {
 		environments = append(environments, mapped+"="+request.Headers[header])
 	}
-	for query, mapped := range local.manifest.Query {
+	for query, mapped := range manifest.Query {
 		environments = append(environments, mapped+"="+request.Form[query])
 	}
-	if local.manifest.MethodEnv != "" {
-		environments = append(environments, local.manifest.MethodEnv+"="+request.Method)
+	if manifest.MethodEnv != "" {
+		environments = append(environments, manifest.MethodEnv+"="+request.Method)
 	}
-	if local.manifest.PathEnv != "" {
-		environments = append(environments, local.manifest.PathEnv+"="+request.Path)
+	if manifest.PathEnv != "" {
+		environments = append(environments, manifest.PathEnv+"="+request.Path)
 	}
-	for k, v := range local.manifest.Environment {
-		environments = append(environments, k+"="+v)
+	for claim, value := range request.Claims {
+		environments = append(environments, claimEnv(claim)+"="+value)
 	}
-	cmd.Env = environments
-	err := cmd.Run()
+	return environments
+}
+
+// environment variable for JWT claim: JWT_ prefix and upper-cased name with non-alphanumeric symbols replaced by _
+func claimEnv(claim string) string {
+	return "JWT_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, claim)
+}
+
+func invokeWorker(ctx context.Context, pool *workerPool, manifest types.Manifest, request types.Request, input io.Reader, response io.Writer) error {
+	env := make(map[string]string)
+	for _, kv := range requestEnvironment(ctx, manifest, request) {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	err := pool.Invoke(ctx, workerRequest{Request: request, Environment: env}, input, response)
 	if err != nil {
-		return fmt.Errorf("run failed: %w", err)
+		return fmt.Errorf("worker failed: %w", err)
 	}
 	return nil
 }
 
-func (local *localLambda) serveStaticFile(request types.Request, response io.Writer) error {
+// get or create pool of workers. Pool is re-created if global environment changed.
+// Should be called under read lock.
+func (local *localLambda) workerPool(globalEnv map[string]string) *workerPool {
+	local.processLock.Lock()
+	defer local.processLock.Unlock()
+	local.unsafeCheckProcessEnv(globalEnv)
+	if local.workers == nil {
+		local.workers = newWorkerPool(*local.manifest.Workers, local.processFactory(globalEnv))
+	}
+	return local.workers
+}
+
+// get or create proxy backend. Backend is re-created if global environment changed.
+// Should be called under read lock.
+func (local *localLambda) proxyBackend(globalEnv map[string]string) *proxyBackend {
+	local.processLock.Lock()
+	defer local.processLock.Unlock()
+	local.unsafeCheckProcessEnv(globalEnv)
+	if local.proxy == nil {
+		var factory func() (*exec.Cmd, func(), error)
+		if len(local.manifest.Run) > 0 {
+			factory = local.processFactory(globalEnv)
+		}
+		local.proxy = newProxyBackend(*local.manifest.Proxy, local.rootDir, factory)
+	}
+	return local.proxy
+}
+
+// stop long-running processes if global environment changed
+func (local *localLambda) unsafeCheckProcessEnv(globalEnv map[string]string) {
+	if reflect.DeepEqual(local.processEnv, globalEnv) {
+		return
+	}
+	local.unsafeStopProcesses()
+	local.processEnv = globalEnv
+}
+
+// factory of long-running processes (workers, proxy backends) with global and lambda environment
+func (local *localLambda) processFactory(globalEnv map[string]string) func() (*exec.Cmd, func(), error) {
+	var environments = os.Environ()
+	for k, v := range globalEnv {
+		environments = append(environments, k+"="+v)
+	}
+	for k, v := range local.manifest.Environment {
+		environments = append(environments, k+"="+v)
+	}
+	manifest := local.manifest
+	creds := local.creds
+	dir := local.rootDir
+	stderr := io.MultiWriter(os.Stderr, &processLog{store: &local.logs})
+	return func() (*exec.Cmd, func(), error) {
+		cmd := exec.Command(manifest.Run[0], manifest.Run[1:]...)
+		cmd.Dir = dir
+		cmd.Stderr = stderr
+		cmd.Env = environments
+		internal.SetCreds(cmd, creds)
+		internal.SetFlags(cmd)
+		release, err := internal.SetSandbox(cmd, manifest.Sandbox, dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("prepare sandbox: %w", err)
+		}
+		return cmd, release, nil
+	}
+}
+
+// stop all long-running processes (if any). They will be started again on demand.
+func (local *localLambda) stopProcesses() {
+	local.processLock.Lock()
+	defer local.processLock.Unlock()
+	local.unsafeStopProcesses()
+}
+
+func (local *localLambda) unsafeStopProcesses() {
+	if local.workers != nil {
+		local.workers.Close()
+		local.workers = nil
+	}
+	if local.proxy != nil {
+		local.proxy.Close()
+		local.proxy = nil
+	}
+}
+
+func (local *localLambda) serveStaticFile(staticDir string, request types.Request, response io.Writer) error {
 	// poor man path trimming
 	// trailing slash always removed (later replaced by index.html)
 	_, file, _ := strings.Cut(strings.Trim(request.Path, "/"), "/")
-	return local.writeStaticFile(file, response)
+	return local.writeStaticFile(staticDir, file, response)
 }
 
 func (local *localLambda) Remove() error {
+	local.stopProcesses()
 	return os.RemoveAll(local.rootDir)
 }
 
@@ -154,6 +352,7 @@ func (local *localLambda) reindex() error {
 	}
 	local.rootDir = root
 	local.uid = filepath.Base(root)
+	local.stopProcesses()
 	return nil
 }
 
@@ -182,11 +381,11 @@ func (local *localLambda) readIgnore() ([]string, error) {
 	return nil, fmt.Errorf("read ignore file: %w", err)
 }
 
-func (local *localLambda) writeStaticFile(path string, out io.Writer) error {
+func (local *localLambda) writeStaticFile(staticDir string, path string, out io.Writer) error {
 	if path == "" {
 		path = "index.html"
 	}
-	destPath, isLocal := local.resolvePath(local.staticDir, path)
+	destPath, isLocal := local.resolvePath(staticDir, path)
 	if !isLocal {
 		return fmt.Errorf("attempt to access file out of the jail")
 	}
@@ -3,7 +3,9 @@ package lambda
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"github.com/reddec/trusted-cgi/internal"
+	"github.com/reddec/trusted-cgi/types"
 	"github.com/robfig/cron"
 	"io"
 	"log"
@@ -57,18 +59,29 @@ func (local *localLambda) Do(ctx context.Context, name string, timeLimit time.Du
 		environments = append(environments, k+"="+v)
 	}
 
+	var output logBuffer
+	combined := io.MultiWriter(out, &output)
 	cmd := exec.CommandContext(ctx, "make", name)
 	cmd.Dir = local.rootDir
-	cmd.Stdout = out
-	cmd.Stderr = out
+	cmd.Stdout = combined
+	cmd.Stderr = combined
 	internal.SetCreds(cmd, local.creds)
 	internal.SetFlags(cmd)
 	cmd.Env = environments
+	release, err := internal.SetSandbox(cmd, local.manifest.Sandbox, local.rootDir)
+	if err != nil {
+		return fmt.Errorf("prepare sandbox: %w", err)
+	}
+	defer release()
 
-	return cmd.Run()
+	begin := time.Now()
+	err = cmd.Run()
+	local.logs.Record(types.LogAction, name, begin, &output, err)
+	return err
 }
 
-func (local *localLambda) DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string) {
+func (local *localLambda) DoScheduled(ctx context.Context, lastRun time.Time, globalEnv map[string]string) []types.ScheduleResult {
+	var results []types.ScheduleResult
 	now := time.Now()
 	for _, plan := range local.manifest.Cron {
 		sched, err := cron.Parse(plan.Cron)
@@ -77,10 +90,19 @@ func (local *localLambda) DoScheduled(ctx context.Context, lastRun time.Time, gl
 			continue
 		}
 		if !sched.Next(lastRun).After(now) {
+			begin := time.Now()
 			err = local.Do(ctx, plan.Action, time.Duration(plan.TimeLimit), globalEnv, nil)
 			if err != nil {
 				log.Println(plan.Cron, plan.Action, err)
 			}
+			results = append(results, types.ScheduleResult{
+				UID:    local.uid,
+				Action: plan.Action,
+				Begin:  begin,
+				End:    time.Now(),
+				Err:    err,
+			})
 		}
 	}
+	return results
 }
@@ -4,11 +4,14 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -140,6 +143,87 @@ func TestLocalLambda_Invoke(t *testing.T) {
 	assert.Equal(t, "hello world", out.String())
 }
 
+func TestLocalLambda_InvokeMethod(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Method = http.MethodPost
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{
+		Method: "post",
+		Body:   io.NopCloser(bytes.NewBufferString("hello")),
+	}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", out.String())
+
+	err = fn.Invoke(context.Background(), types.Request{
+		Method: http.MethodGet,
+		Body:   io.NopCloser(bytes.NewBufferString("hello")),
+	}, &out, nil)
+	assert.True(t, errors.Is(err, application.ErrMethodNotAllowed))
+}
+
+func TestLocalLambda_InvokeLongRunning(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "cat", "-")
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.WebSocket = &types.WebSocket{}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	// stream of messages which is not finished till connection closed
+	input, stream := io.Pipe()
+	invoked := make(chan error, 1)
+	go func() {
+		invoked <- fn.Invoke(context.Background(), types.Request{Body: input}, io.Discard, nil)
+	}()
+	_, err = stream.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	// manifest and credentials could be changed during invocation
+	updated := make(chan error, 1)
+	go func() {
+		manifest.Environment = map[string]string{"UPDATED": "true"}
+		updated <- fn.SetManifest(manifest)
+	}()
+	select {
+	case err := <-updated:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("manifest update blocked by running invocation")
+	}
+	assert.Equal(t, "true", fn.Manifest().Environment["UPDATED"])
+
+	_ = stream.Close()
+	assert.NoError(t, <-invoked)
+}
+
+func TestLocalLambda_InvokeClaims(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "sh", "-c", `printf '%s|%s' "$JWT_SUB" "$JWT_X_ROLE"`)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{
+		Claims: map[string]string{"sub": "user-1", "x-role": "admin"},
+		Body:   io.NopCloser(bytes.NewReader(nil)),
+	}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1|admin", out.String())
+}
+
 func TestStaticFile(t *testing.T) {
 	d, err := os.MkdirTemp("", "test-lambda-*")
 	require.NoError(t, err)
@@ -203,3 +287,191 @@ func testRequest(fn application.Invokable, method string, path string, payload [
 	}, &out, nil)
 	return out.Bytes(), err
 }
+
+func TestLocalLambda_InvokeCGI(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "sh", "-c", `printf '%s|%s|%s|%s|%s|%s|%s|%s' "$REQUEST_METHOD" "$QUERY_STRING" "$SCRIPT_NAME" "$PATH_INFO" "$REMOTE_ADDR" "$CONTENT_TYPE" "$HTTP_X_FOO" "$HTTP_AUTHORIZATION"`)
+	require.NoError(t, err)
+
+	manifest := fn.Manifest()
+	manifest.CGI = true
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{
+		Method:        http.MethodPost,
+		URL:           "/a/xyz/foo/bar?name=reddec&x=1",
+		Path:          "xyz/foo/bar",
+		RemoteAddress: "127.0.0.2:9992",
+		Headers: map[string]string{
+			"Content-Type":  "text/plain",
+			"X-Foo":         "bar",
+			"Authorization": "secret",
+		},
+		Body: io.NopCloser(bytes.NewReader(nil)),
+	}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "POST|name=reddec&x=1|/a/xyz|/foo/bar|127.0.0.2|text/plain|bar|", out.String())
+}
+
+func TestLocalLambda_Sandbox(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		t.Skip("sandbox requires root on linux")
+	}
+	project, err := os.MkdirTemp("", "test-project-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(project)
+	require.NoError(t, os.WriteFile(filepath.Join(project, "secret"), []byte("secret"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(project, "fn"), 0755))
+
+	fn, err := DummyPublic(filepath.Join(project, "fn"), "sh", "-c", `
+echo -n "pid=$$;"
+cat ../secret 2>/dev/null && echo -n "secret-visible;"
+touch /usr/sandbox-test 2>/dev/null && echo -n "root-writable;"
+touch /tmp/scratch && echo -n "tmp-writable;"
+echo data > local && echo -n "dir-writable;"
+umount -l /tmp 2>/dev/null && echo -n "umount-allowed;"
+umount -l "$(dirname "$PWD")" 2>/dev/null && echo -n "umount-project-allowed;"
+mount -t tmpfs tmpfs /mnt 2>/dev/null && echo -n "mount-allowed;"
+cat ../secret 2>/dev/null && echo -n "secret-visible;"
+true
+`)
+	require.NoError(t, err)
+	manifest := fn.Manifest()
+	manifest.Sandbox = &types.Sandbox{}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	out, err := testRequest(fn, http.MethodPost, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "pid=1;tmp-writable;dir-writable;", string(out))
+	assert.FileExists(t, filepath.Join(project, "fn", "local"))
+	assert.NoFileExists(t, "/usr/sandbox-test")
+}
+
+func TestLocalLambda_Workers(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	// echo worker: replies with own PID and request body
+	fn, err := DummyPublic(d, "sh", "-c", `
+while read kind size; do
+  case $kind in
+    R) dd bs=1 count=$size of=/dev/null 2>/dev/null; body="" ;;
+    B) body="$body$(dd bs=1 count=$size 2>/dev/null)" ;;
+    E) out="$$:$body"; printf 'D %d\n%s' ${#out} "$out"; printf 'F 0\n' ;;
+  esac
+done`)
+	require.NoError(t, err)
+	defer fn.Remove()
+
+	manifest := fn.Manifest()
+	manifest.Workers = &types.Workers{Size: 1, MaxRequests: 3}
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var pids []string
+	for i := 0; i < 4; i++ {
+		out, err := testRequest(fn, http.MethodPost, "", []byte("hello"))
+		require.NoError(t, err)
+		pid, body, _ := strings.Cut(string(out), ":")
+		assert.Equal(t, "hello", body)
+		pids = append(pids, pid)
+	}
+	assert.Equal(t, pids[0], pids[1])
+	assert.Equal(t, pids[0], pids[2])
+	assert.NotEqual(t, pids[0], pids[3], "worker should be restarted after max requests")
+}
+
+func TestLocalLambda_InvokeCGIMultiValues(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "sh", "-c", `printf '%s|%s' "$HTTP_X_FOO" "$HTTP_COOKIE"`)
+	require.NoError(t, err)
+
+	manifest := fn.Manifest()
+	manifest.CGI = true
+	require.NoError(t, fn.SetManifest(manifest))
+
+	var out bytes.Buffer
+	err = fn.Invoke(context.Background(), types.Request{
+		Method:  http.MethodGet,
+		URL:     "/a/xyz",
+		Path:    "xyz",
+		Headers: map[string]string{"X-Foo": "bar", "Cookie": "a=1"},
+		HeaderValues: map[string][]string{
+			"X-Foo":  {"bar", "baz"},
+			"Cookie": {"a=1", "b=2"},
+		},
+		Body: io.NopCloser(bytes.NewReader(nil)),
+	}, &out, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "bar, baz|a=1; b=2", out.String())
+}
+
+func TestLocalLambda_Logs(t *testing.T) {
+	d, err := os.MkdirTemp("", "test-lambda-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(d)
+
+	fn, err := DummyPublic(d, "sh", "-c", `echo "out"; echo "err: $(cat)" >&2; exit 1`)
+	require.NoError(t, err)
+
+	_, err = testRequest(fn, http.MethodPost, "", []byte("first"))
+	assert.Error(t, err)
+	_, err = testRequest(fn, http.MethodPost, "", []byte("second"))
+	assert.Error(t, err)
+
+	entries := fn.Logs(0, 0)
+	require.Len(t, entries, 2)
+	assert.Equal(t, types.LogRequest, entries[0].Kind)
+	assert.Equal(t, "err: first\n", entries[0].Output)
+	assert.NotEmpty(t, entries[0].Err)
+	assert.Equal(t, "err: second\n", entries[1].Output)
+
+	entries = fn.Logs(entries[0].ID, 0)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "err: second\n", entries[0].Output)
+
+	entries = fn.Logs(0, 1)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "err: second\n", entries[0].Output)
+}
+
+func TestProcessLog(t *testing.T) {
+	var store logStore
+	store.Add(types.LogEntry{Kind: types.LogRequest, Output: "request"})
+	pl := &processLog{store: &store}
+
+	_, _ = pl.Write([]byte("first\nsec"))
+	_, _ = pl.Write([]byte("ond\nthi"))
+	pl.flush()
+	entries := store.After(0, 0)
+	require.Len(t, entries, 2)
+	assert.Equal(t, types.LogProcess, entries[1].Kind)
+	assert.Equal(t, "first\nsecond\n", entries[1].Output, "writes are grouped by lines")
+
+	pl.flush()
+	entries = store.After(entries[1].ID, 0)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "thi", entries[0].Output, "incomplete line is saved in the next window")
+
+	_, _ = pl.Write(bytes.Repeat([]byte("x"), maxLogOutput+1))
+	entries = store.After(entries[0].ID, 0)
+	require.Len(t, entries, 1, "big output is saved without waiting for window")
+	assert.Len(t, entries[0].Output, maxLogOutput)
+	pl.flush()
+
+	for i := 0; i < 2*maxProcessLogEntries; i++ {
+		_, _ = pl.Write([]byte("chatty\n"))
+		pl.flush()
+	}
+	entries = store.After(0, 0)
+	require.Len(t, entries, maxProcessLogEntries+1, "processes logs are limited separately")
+	assert.Equal(t, "request", entries[0].Output)
+	assert.Equal(t, "chatty\n", entries[len(entries)-1].Output)
+}
@@ -0,0 +1,157 @@
+package lambda
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	maxLogEntries        = 100         // maximum number of request and action log entries per lambda
+	maxProcessLogEntries = 100         // maximum number of long-running processes log entries per lambda
+	maxLogOutput         = 64 * 1024   // maximum size of captured output per entry
+	processLogWindow     = time.Second // output of long-running processes is grouped into entries per window
+)
+
+// In-memory rotating store of captured outputs. Output of long-running processes is limited separately, so
+// chatty processes don't push out requests and actions logs.
+type logStore struct {
+	lock    sync.Mutex
+	lastID  uint64
+	entries []types.LogEntry // requests and actions
+	process []types.LogEntry // long-running processes
+}
+
+// Add entry to store. Oldest entries of the same kind group will be removed if limit reached.
+func (ls *logStore) Add(entry types.LogEntry) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	ls.lastID++
+	entry.ID = ls.lastID
+	if entry.Kind == types.LogProcess {
+		ls.process = appendLimited(ls.process, entry, maxProcessLogEntries)
+	} else {
+		ls.entries = appendLimited(ls.entries, entry, maxLogEntries)
+	}
+}
+
+func appendLimited(entries []types.LogEntry, entry types.LogEntry, limit int) []types.LogEntry {
+	if len(entries) >= limit {
+		entries = append(entries[:0], entries[1:]...)
+	}
+	return append(entries, entry)
+}
+
+// Record finished execution.
+func (ls *logStore) Record(kind, name string, begin time.Time, output *logBuffer, err error) {
+	entry := types.LogEntry{
+		Kind:      kind,
+		Name:      name,
+		Begin:     begin,
+		End:       time.Now(),
+		Output:    string(output.data),
+		Truncated: output.truncated,
+	}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	ls.Add(entry)
+}
+
+// Entries with ID greater than after. Positive limit keeps only last entries.
+func (ls *logStore) After(after uint64, limit int) []types.LogEntry {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+	var ans = make([]types.LogEntry, 0)
+	// merge both groups by ID
+	entries, process := ls.entries, ls.process
+	for len(entries) > 0 || len(process) > 0 {
+		var entry types.LogEntry
+		if len(process) == 0 || (len(entries) > 0 && entries[0].ID < process[0].ID) {
+			entry, entries = entries[0], entries[1:]
+		} else {
+			entry, process = process[0], process[1:]
+		}
+		if entry.ID > after {
+			ans = append(ans, entry)
+		}
+	}
+	if limit > 0 && len(ans) > limit {
+		ans = ans[len(ans)-limit:]
+	}
+	return ans
+}
+
+// Writer that keeps only first maxLogOutput bytes. Never fails.
+type logBuffer struct {
+	data      []byte
+	truncated bool
+}
+
+func (lb *logBuffer) Write(data []byte) (int, error) {
+	if free := maxLogOutput - len(lb.data); free < len(data) {
+		lb.data = append(lb.data, data[:free]...)
+		lb.truncated = true
+	} else {
+		lb.data = append(lb.data, data...)
+	}
+	return len(data), nil
+}
+
+// Writer for long-running processes. Output is grouped into entries by processLogWindow (or by maxLogOutput
+// bytes for chatty processes); entries are cut by the last complete line if possible. Safe for concurrent use.
+type processLog struct {
+	store   *logStore
+	lock    sync.Mutex
+	begin   time.Time // time of the first pending write
+	pending []byte
+	timer   *time.Timer
+}
+
+func (pl *processLog) Write(data []byte) (int, error) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	now := time.Now()
+	if len(pl.pending) == 0 {
+		pl.begin = now
+	}
+	pl.pending = append(pl.pending, data...)
+	for len(pl.pending) >= maxLogOutput {
+		pl.emit(maxLogOutput, now)
+	}
+	if len(pl.pending) > 0 && pl.timer == nil {
+		pl.timer = time.AfterFunc(processLogWindow, pl.flush)
+	}
+	return len(data), nil
+}
+
+// save pending output at the end of window. Incomplete line is kept for the next window.
+func (pl *processLog) flush() {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	pl.timer = nil
+	if len(pl.pending) > 0 {
+		pl.emit(len(pl.pending), time.Now())
+	}
+	if len(pl.pending) > 0 {
+		pl.timer = time.AfterFunc(processLogWindow, pl.flush)
+	}
+}
+
+// save up to size bytes of pending output as an entry. Should be called under lock.
+func (pl *processLog) emit(size int, now time.Time) {
+	chunk := pl.pending[:size]
+	if idx := bytes.LastIndexByte(chunk, '\n'); idx >= 0 {
+		chunk = chunk[:idx+1]
+	}
+	pl.store.Add(types.LogEntry{
+		Kind:   types.LogProcess,
+		Begin:  pl.begin,
+		End:    now,
+		Output: string(chunk),
+	})
+	pl.pending = append([]byte(nil), pl.pending[len(chunk):]...)
+	pl.begin = now
+}
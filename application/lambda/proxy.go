@@ -0,0 +1,242 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/tracing"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const defaultProxyStartTimeout = 10 * time.Second
+
+// hop-by-hop headers which should not be forwarded (RFC 7230, section 6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Reverse proxy to local HTTP server listening on unix socket or TCP address. If factory is defined, the server process
+// will be started on demand (and restarted after exit). Response is written in CGI format (status and headers block
+// followed by body) so it could be parsed by server as-is.
+func newProxyBackend(config types.Proxy, dir string, factory func() (*exec.Cmd, func(), error)) *proxyBackend {
+	network, address := "tcp", config.Address
+	if config.Socket != "" {
+		network, address = "unix", config.Socket
+		if !filepath.IsAbs(address) {
+			address = filepath.Join(dir, address)
+		}
+	}
+	startTimeout := time.Duration(config.StartTimeout)
+	if startTimeout <= 0 {
+		startTimeout = defaultProxyStartTimeout
+	}
+	dialer := &net.Dialer{}
+	return &proxyBackend{
+		network:      network,
+		address:      address,
+		startTimeout: startTimeout,
+		factory:      factory,
+		transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			DisableCompression:  true,
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     time.Minute,
+		},
+	}
+}
+
+type proxyBackend struct {
+	network      string
+	address      string
+	startTimeout time.Duration
+	factory      func() (*exec.Cmd, func(), error)
+	transport    *http.Transport
+	lock         sync.Mutex
+	cmd          *exec.Cmd
+	done         chan struct{}
+	closed       bool
+}
+
+// Invoke forwards request to the backend and writes response with CGI headers.
+func (pb *proxyBackend) Invoke(ctx context.Context, request types.Request, body io.Reader, out io.Writer) error {
+	if err := pb.ensure(ctx); err != nil {
+		return err
+	}
+	req, err := pb.newRequest(ctx, request, body)
+	if err != nil {
+		return err
+	}
+	res, err := pb.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	for _, h := range hopHeaders {
+		res.Header.Del(h)
+	}
+	var head bytes.Buffer
+	head.WriteString("Status: " + strconv.Itoa(res.StatusCode) + " " + http.StatusText(res.StatusCode) + "\r\n")
+	if err := res.Header.Write(&head); err != nil {
+		return err
+	}
+	head.WriteString("\r\n")
+	if _, err := out.Write(head.Bytes()); err != nil {
+		return err
+	}
+	_, err = io.Copy(out, res.Body)
+	return err
+}
+
+// Close backend: stop server process (if started) and close idle connections.
+func (pb *proxyBackend) Close() {
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+	pb.closed = true
+	pb.transport.CloseIdleConnections()
+	if pb.cmd != nil {
+		stopProcess(pb.cmd, pb.done)
+		pb.cmd = nil
+	}
+}
+
+func (pb *proxyBackend) newRequest(ctx context.Context, request types.Request, body io.Reader) (*http.Request, error) {
+	target := &url.URL{Scheme: "http", Host: "localhost", Path: requestPathInfo(request.Path)}
+	if target.Path == "" {
+		target.Path = "/"
+	}
+	if u, err := url.Parse(request.URL); err == nil {
+		target.RawQuery = u.RawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, request.Method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for k := range request.Headers {
+		req.Header[k] = request.HeaderAll(k)
+	}
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Del("Host")
+	if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+		req.Header.Set("Traceparent", traceparent)
+	}
+	if host := request.Headers["Host"]; host != "" {
+		req.Host = host
+		req.Header.Set("X-Forwarded-Host", host)
+	}
+	if remote, _, err := net.SplitHostPort(request.RemoteAddress); err == nil {
+		req.Header.Set("X-Forwarded-For", remote)
+	} else if request.RemoteAddress != "" {
+		req.Header.Set("X-Forwarded-For", request.RemoteAddress)
+	}
+	// declared size (if any) is not bigger than maximum payload - checked before invoke
+	req.ContentLength = -1
+	if size, err := strconv.ParseInt(request.Headers["Content-Length"], 10, 64); err == nil {
+		req.ContentLength = size
+	}
+	if req.ContentLength == 0 {
+		req.Body = http.NoBody
+	}
+	return req, nil
+}
+
+// start server process if needed and wait for readiness
+func (pb *proxyBackend) ensure(ctx context.Context) error {
+	if pb.factory == nil {
+		return nil
+	}
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+	if pb.closed {
+		return fmt.Errorf("proxy closed")
+	}
+	if pb.cmd != nil {
+		select {
+		case <-pb.done:
+			pb.cmd = nil // exited - restart
+		default:
+			return nil
+		}
+	}
+	if pb.network == "unix" {
+		_ = os.Remove(pb.address) // stale socket from previous run
+	}
+	cmd, release, err := pb.factory()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = cmd.Stderr // server logs
+	if err := cmd.Start(); err != nil {
+		release()
+		return fmt.Errorf("start backend: %w", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		release()
+		close(done)
+	}()
+	if err := pb.waitReady(ctx, done); err != nil {
+		stopProcess(cmd, done)
+		return err
+	}
+	pb.cmd = cmd
+	pb.done = done
+	return nil
+}
+
+func (pb *proxyBackend) waitReady(ctx context.Context, done <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, pb.startTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, pb.network, pb.address)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-done:
+			return fmt.Errorf("backend exited before start")
+		case <-ctx.Done():
+			return fmt.Errorf("wait for backend: %w", ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// stop process gracefully (interrupt) and kill it after grace period
+func stopProcess(cmd *exec.Cmd, done <-chan struct{}) {
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		_ = cmd.Process.Kill()
+		return
+	}
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(stopGrace):
+			_ = cmd.Process.Kill()
+		}
+	}()
+}
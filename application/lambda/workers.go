@@ -0,0 +1,339 @@
+package lambda
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Frames of workers protocol. Each frame is "<kind> <length>\n<payload>".
+const (
+	frameRequest byte = 'R' // request head (JSON), server -> worker
+	frameBody    byte = 'B' // chunk of request body, server -> worker
+	frameEnd     byte = 'E' // end of request body (empty), server -> worker
+	frameData    byte = 'D' // chunk of response, worker -> server
+	frameFinish  byte = 'F' // end of response with optional error message, worker -> server
+)
+
+const (
+	maxFinishFrame = 64 * 1024   // maximum size of error message in finish frame
+	bodyChunkSize  = 32 * 1024   // size of request body chunks
+	stopGrace      = time.Second // time for worker to exit after stdin closed
+)
+
+// Request head passed to worker
+type workerRequest struct {
+	types.Request
+	Environment map[string]string `json:"env"` // request specific environment (mapped headers, query, CGI variables...)
+}
+
+// Pool of long-running processes (workers) which serve requests one by one over stdin/stdout using frames protocol.
+// Workers are started on demand, restarted after crash, stopped after max requests or idle timeout.
+func newWorkerPool(config types.Workers, factory func() (*exec.Cmd, func(), error)) *workerPool {
+	size := config.Size
+	if size <= 0 {
+		size = 1
+	}
+	return &workerPool{
+		config:  config,
+		factory: factory,
+		slots:   make(chan struct{}, size),
+	}
+}
+
+type workerPool struct {
+	config  types.Workers
+	factory func() (*exec.Cmd, func(), error)
+	slots   chan struct{}
+	lock    sync.Mutex
+	idle    []*worker
+	closed  bool
+}
+
+// Invoke request by any free worker. Waits for free worker if all are busy.
+func (pool *workerPool) Invoke(ctx context.Context, request workerRequest, body io.Reader, out io.Writer) error {
+	select {
+	case pool.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-pool.slots }()
+
+	w, err := pool.get()
+	if err != nil {
+		return fmt.Errorf("start worker: %w", err)
+	}
+	healthy, err := w.Serve(ctx, request, body, out)
+	pool.put(w, healthy)
+	return err
+}
+
+// Close pool and stop all workers. Busy workers will be stopped after request.
+func (pool *workerPool) Close() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	pool.closed = true
+	for _, w := range pool.idle {
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		w.Stop()
+	}
+	pool.idle = nil
+}
+
+func (pool *workerPool) get() (*worker, error) {
+	pool.lock.Lock()
+	for len(pool.idle) > 0 {
+		w := pool.idle[len(pool.idle)-1]
+		pool.idle = pool.idle[:len(pool.idle)-1]
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		if !w.Exited() {
+			pool.lock.Unlock()
+			return w, nil
+		}
+	}
+	pool.lock.Unlock()
+	cmd, release, err := pool.factory()
+	if err != nil {
+		return nil, err
+	}
+	return startWorker(cmd, release)
+}
+
+func (pool *workerPool) put(w *worker, healthy bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	w.requests++
+	if !healthy || pool.closed || (pool.config.MaxRequests > 0 && w.requests >= pool.config.MaxRequests) {
+		w.Stop()
+		return
+	}
+	pool.idle = append(pool.idle, w)
+	if idle := time.Duration(pool.config.IdleTimeout); idle > 0 {
+		w.timer = time.AfterFunc(idle, func() {
+			pool.expire(w)
+		})
+	}
+}
+
+func (pool *workerPool) expire(w *worker) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	for i, v := range pool.idle {
+		if v == w {
+			pool.idle = append(pool.idle[:i], pool.idle[i+1:]...)
+			w.Stop()
+			return
+		}
+	}
+}
+
+func startWorker(cmd *exec.Cmd, release func()) (*worker, error) {
+	stdin, childIn, err := os.Pipe()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	childOut, stdout, err := os.Pipe()
+	if err != nil {
+		_ = stdin.Close()
+		_ = childIn.Close()
+		release()
+		return nil, err
+	}
+	// input pipe: child reads from stdin, server writes to childIn
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	err = cmd.Start()
+	_ = stdin.Close()
+	_ = stdout.Close()
+	if err != nil {
+		_ = childIn.Close()
+		_ = childOut.Close()
+		release()
+		return nil, err
+	}
+	w := &worker{
+		cmd:    cmd,
+		input:  childIn,
+		reader: bufio.NewReader(childOut),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		_ = childOut.Close()
+		release()
+		close(w.done)
+	}()
+	return w, nil
+}
+
+type worker struct {
+	cmd      *exec.Cmd
+	input    io.WriteCloser
+	reader   *bufio.Reader
+	done     chan struct{}
+	requests int
+	timer    *time.Timer
+}
+
+// Serve single request. Returns false if worker can not be re-used.
+func (w *worker) Serve(ctx context.Context, request workerRequest, body io.Reader, out io.Writer) (bool, error) {
+	stopWatch := context.AfterFunc(ctx, w.Kill)
+	defer stopWatch()
+
+	head, err := json.Marshal(request)
+	if err != nil {
+		return true, err
+	}
+	// writer is not awaited if worker is killed: it will fail on the closed pipe
+	sent := make(chan error, 1)
+	go func() {
+		sent <- writeRequest(w.input, head, body)
+	}()
+
+	var outErr error
+	for {
+		kind, size, err := readFrameHeader(w.reader)
+		if err != nil {
+			w.Kill()
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, fmt.Errorf("read worker response: %w", err)
+		}
+		switch kind {
+		case frameData:
+			if outErr == nil {
+				_, outErr = io.CopyN(out, w.reader, size)
+			}
+			if outErr != nil {
+				// client is gone, but response should be consumed anyway
+				if _, err := w.reader.Discard(int(size)); err != nil {
+					w.Kill()
+					return false, outErr
+				}
+			}
+		case frameFinish:
+			if size > maxFinishFrame {
+				w.Kill()
+				return false, fmt.Errorf("too big finish frame from worker")
+			}
+			message := make([]byte, size)
+			if _, err := io.ReadFull(w.reader, message); err != nil {
+				w.Kill()
+				return false, fmt.Errorf("read worker response: %w", err)
+			}
+			healthy := true
+			// worker should consume whole request before finish
+			select {
+			case err := <-sent:
+				healthy = err == nil
+			case <-time.After(stopGrace):
+				w.Kill()
+				healthy = false
+			}
+			if len(message) > 0 {
+				return healthy, fmt.Errorf("worker: %s", string(message))
+			}
+			return healthy, outErr
+		default:
+			w.Kill()
+			return false, fmt.Errorf("unknown frame %q from worker", kind)
+		}
+	}
+}
+
+// Exited returns true if worker process finished.
+func (w *worker) Exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop worker gracefully: close input and kill after grace period.
+func (w *worker) Stop() {
+	_ = w.input.Close()
+	go func() {
+		select {
+		case <-w.done:
+		case <-time.After(stopGrace):
+			w.Kill()
+		}
+	}()
+}
+
+// Kill worker process immediately.
+func (w *worker) Kill() {
+	_ = w.cmd.Process.Kill()
+}
+
+func writeRequest(out io.Writer, head []byte, body io.Reader) error {
+	writer := bufio.NewWriter(out)
+	if err := writeFrame(writer, frameRequest, head); err != nil {
+		return err
+	}
+	var buffer = make([]byte, bodyChunkSize)
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			if err := writeFrame(writer, frameBody, buffer[:n]); err != nil {
+				return err
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read request body: %w", err)
+		}
+	}
+	if err := writeFrame(writer, frameEnd, nil); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writeFrame(out *bufio.Writer, kind byte, payload []byte) error {
+	if err := out.WriteByte(kind); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(" " + strconv.Itoa(len(payload)) + "\n"); err != nil {
+		return err
+	}
+	_, err := out.Write(payload)
+	return err
+}
+
+func readFrameHeader(in *bufio.Reader) (byte, int64, error) {
+	line, err := in.ReadString('\n')
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(line) < 4 || line[1] != ' ' {
+		return 0, 0, fmt.Errorf("malformed frame header %q", line)
+	}
+	size, err := strconv.ParseInt(line[2:len(line)-1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, fmt.Errorf("malformed frame size %q", line)
+	}
+	return line[0], size, nil
+}
@@ -0,0 +1,80 @@
+package platform
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// Concurrency limiter with FIFO waiting queue. Limits are passed on each acquire, so they could be changed in runtime.
+type limiter struct {
+	lock    sync.Mutex
+	running int
+	waiters []chan struct{}
+}
+
+// Acquire slot for execution. Zero limit means unlimited. Zero waiting means unlimited queue, negative - no queue.
+// Zero timeout means waiting till context expiration.
+func (lim *limiter) Acquire(ctx context.Context, limit, waiting int, timeout time.Duration) error {
+	lim.lock.Lock()
+	if limit <= 0 || (lim.running < limit && len(lim.waiters) == 0) {
+		lim.running++
+		lim.lock.Unlock()
+		return nil
+	}
+	if waiting < 0 || (waiting > 0 && len(lim.waiters) >= waiting) {
+		lim.lock.Unlock()
+		return application.ErrTooManyRequests
+	}
+	ready := make(chan struct{})
+	lim.waiters = append(lim.waiters, ready)
+	lim.lock.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+		err = application.ErrWaitTimeout
+	}
+
+	lim.lock.Lock()
+	defer lim.lock.Unlock()
+	for i, w := range lim.waiters {
+		if w == ready {
+			lim.waiters = append(lim.waiters[:i], lim.waiters[i+1:]...)
+			return err
+		}
+	}
+	// slot already passed to us - pass it further
+	lim.unsafeRelease(limit)
+	return err
+}
+
+// Release acquired slot. If someone waiting, the slot is passed to the oldest waiter.
+func (lim *limiter) Release(limit int) {
+	lim.lock.Lock()
+	defer lim.lock.Unlock()
+	lim.unsafeRelease(limit)
+}
+
+func (lim *limiter) unsafeRelease(limit int) {
+	if len(lim.waiters) > 0 && (limit <= 0 || lim.running <= limit) {
+		next := lim.waiters[0]
+		lim.waiters = lim.waiters[1:]
+		close(next)
+		return
+	}
+	lim.running--
+}
@@ -36,6 +36,7 @@ type platform struct {
 	config         application.Config
 	configLocation string
 	byUID          map[string]record
+	limiters       map[string]*limiter
 }
 
 type record struct {
@@ -182,6 +183,7 @@ func (platform *platform) Remove(uid string) {
 	defer platform.lock.Unlock()
 	rec, ok := platform.byUID[uid]
 	delete(platform.byUID, uid)
+	delete(platform.limiters, uid)
 	if ok {
 		for alias := range rec.aliases {
 			delete(platform.config.Links, alias)
@@ -200,6 +202,12 @@ func (platform *platform) InvokeByUID(ctx context.Context, uid string, request t
 }
 
 func (platform *platform) Invoke(ctx context.Context, lambda application.Invokable, request types.Request, out io.Writer) error {
+	release, err := platform.acquire(ctx, lambda)
+	if err != nil {
+		_ = request.Body.Close()
+		return err
+	}
+	defer release()
 	return lambda.Invoke(ctx, request, out, platform.config.Environment)
 }
 
@@ -207,6 +215,43 @@ func (platform *platform) Do(ctx context.Context, lambda application.Lambda, act
 	return lambda.Do(ctx, action, timeLimit, platform.config.Environment, out)
 }
 
+// wait for free slot according to concurrency limits of lambda (or platform defaults)
+func (platform *platform) acquire(ctx context.Context, lambda application.Invokable) (func(), error) {
+	uid := lambda.UID()
+	platform.lock.Lock()
+	limit, waiting, timeout := platform.config.MaxConcurrency, platform.config.MaxWaiting, time.Duration(platform.config.WaitTimeout)
+	if fn, ok := lambda.(application.Lambda); ok {
+		manifest := fn.Manifest()
+		if manifest.MaxConcurrency != 0 {
+			limit = manifest.MaxConcurrency
+		}
+		if manifest.MaxWaiting != 0 {
+			waiting = manifest.MaxWaiting
+		}
+		if manifest.WaitTimeout != 0 {
+			timeout = time.Duration(manifest.WaitTimeout)
+		}
+	}
+	if limit <= 0 {
+		platform.lock.Unlock()
+		return func() {}, nil
+	}
+	lim, ok := platform.limiters[uid]
+	if !ok {
+		lim = &limiter{}
+		if platform.limiters == nil {
+			platform.limiters = make(map[string]*limiter)
+		}
+		platform.limiters[uid] = lim
+	}
+	platform.lock.Unlock()
+
+	if err := lim.Acquire(ctx, limit, waiting, timeout); err != nil {
+		return nil, err
+	}
+	return func() { lim.Release(limit) }, nil
+}
+
 // apply configuration for lambda
 func (platform *platform) setupLambda(lambda application.Lambda) error {
 	err := lambda.SetCredentials(platform.creds)
@@ -1,14 +1,23 @@
 package platform_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/application/lambda"
 	"github.com/reddec/trusted-cgi/application/platform"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 func TestPlatform_AddWithOldAliases(t *testing.T) {
@@ -72,3 +81,44 @@ func TestPlatform_AddWithOldAliases(t *testing.T) {
 		assert.Equal(t, byLink, byUID)
 	}
 }
+
+func TestPlatform_InvokeConcurrencyLimit(t *testing.T) {
+	workdir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(workdir)
+
+	plato, err := platform.New(filepath.Join(workdir, "project.json"))
+	require.NoError(t, err)
+
+	dummy, err := lambda.DummyPublic(workdir, "sleep", "0.5")
+	require.NoError(t, err)
+	manifest := dummy.Manifest()
+	manifest.MaxConcurrency = 1
+	manifest.MaxWaiting = 1
+	manifest.WaitTimeout = types.JsonDuration(100 * time.Millisecond)
+	require.NoError(t, dummy.SetManifest(manifest))
+	require.NoError(t, plato.Add("123", dummy))
+
+	invoke := func() error {
+		return plato.Invoke(context.Background(), dummy, types.Request{Body: io.NopCloser(bytes.NewReader(nil))}, io.Discard)
+	}
+
+	var firstErr = make(chan error, 1)
+	go func() {
+		firstErr <- invoke()
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	var secondErr = make(chan error, 1)
+	go func() {
+		secondErr <- invoke() // should wait and fail by timeout
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// queue is full
+	assert.True(t, errors.Is(invoke(), application.ErrTooManyRequests))
+	assert.True(t, errors.Is(<-secondErr, application.ErrWaitTimeout))
+	assert.NoError(t, <-firstErr)
+	// slot is free again
+	assert.NoError(t, invoke())
+}
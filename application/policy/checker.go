@@ -2,25 +2,173 @@ package policy
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/types"
-	"net"
 )
 
-func checkPolicy(policy application.PolicyDefinition, req *types.Request) error {
-	host, _, _ := net.SplitHostPort(req.RemoteAddress)
-	if len(policy.AllowedIP) > 0 && !policy.AllowedIP.Has(host) {
-		return fmt.Errorf("IP restricted")
+// check request against policy and return ID of used policy token (if any). Claims of verified JWT are saved to the request.
+//
+// IP, origin, HMAC and rate limit checks are always applied. Authorization is defined by the first matched rule:
+// deny rule rejects request, allow rule applies own IP lists and public flag or tokens (policy authorization is used
+// if rule is not public and has no tokens). Policy authorization is used if no rule matched.
+func checkPolicy(info application.Policy, compiled *compiledPolicy, req *types.Request, keys *keySets, now time.Time) (string, error) {
+	policy := info.Definition
+	if err := checkIP(compiled.allowedIP, compiled.deniedIP, req); err != nil {
+		return "", err
 	}
 	if len(policy.AllowedOrigin) > 0 && !policy.AllowedOrigin.Has(req.Headers["Origin"]) {
-		return fmt.Errorf("origin restricted")
+		return "", fmt.Errorf("origin restricted")
 	}
-
-	if !policy.Public {
-		_, ok := policy.Tokens[req.Headers["Authorization"]]
-		if !ok {
-			return fmt.Errorf("token restricted")
+	var tokenID string
+	if idx := matchRule(compiled.rules, req); idx >= 0 {
+		rule := compiled.rules[idx]
+		if rule.Action == application.RuleDeny {
+			return "", fmt.Errorf("denied by rule #%d", idx+1)
+		}
+		if err := checkIP(rule.allowedIP, rule.deniedIP, req); err != nil {
+			return "", fmt.Errorf("rule #%d: %w", idx+1, err)
 		}
+		id, err := checkRuleAuthorization(info, rule.PolicyRule, req, keys, now)
+		if err != nil {
+			return "", fmt.Errorf("rule #%d: %w", idx+1, err)
+		}
+		tokenID = id
+	} else {
+		id, err := checkAuthorization(info, req, keys, now)
+		if err != nil {
+			return "", err
+		}
+		tokenID = id
+	}
+	if policy.HMAC != nil {
+		if err := checkHMAC(policy.HMAC, req); err != nil {
+			return "", fmt.Errorf("signature restricted: %w", err)
+		}
+	}
+	return tokenID, nil
+}
+
+func checkIP(allowed, denied types.Networks, req *types.Request) error {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil
+	}
+	ip := types.ParseIP(req.RemoteAddress)
+	if ip == nil || denied.Contains(ip) {
+		return fmt.Errorf("IP restricted")
+	}
+	if len(allowed) > 0 && !allowed.Contains(ip) {
+		return fmt.Errorf("IP restricted")
 	}
 	return nil
 }
+
+func checkRuleAuthorization(info application.Policy, rule application.PolicyRule, req *types.Request, keys *keySets, now time.Time) (string, error) {
+	if rule.Public {
+		return "", nil
+	}
+	if len(rule.TokenIDs) == 0 {
+		return checkAuthorization(info, req, keys, now)
+	}
+	var tokens []application.PolicyToken
+	for _, token := range info.Tokens {
+		if rule.TokenIDs.Has(token.ID) {
+			tokens = append(tokens, token)
+		}
+	}
+	id, ok := findToken(tokens, req.Headers["Authorization"], now)
+	if !ok {
+		return "", fmt.Errorf("token restricted")
+	}
+	return id, nil
+}
+
+// policy-level authorization by JWT or by tokens not bound to rules
+func checkAuthorization(info application.Policy, req *types.Request, keys *keySets, now time.Time) (string, error) {
+	policy := info.Definition
+	if policy.JWT != nil {
+		claims, err := checkJWT(policy.JWT, req.Headers["Authorization"], keys)
+		if err != nil {
+			return "", fmt.Errorf("token restricted: %w", err)
+		}
+		req.Claims = claims
+		return "", nil
+	}
+	if policy.Public {
+		return "", nil
+	}
+	bound := ruleTokens(policy)
+	var tokens = make([]application.PolicyToken, 0, len(info.Tokens))
+	for _, token := range info.Tokens {
+		if !bound.Has(token.ID) {
+			tokens = append(tokens, token)
+		}
+	}
+	id, ok := findToken(tokens, req.Headers["Authorization"], now)
+	if !ok {
+		return "", fmt.Errorf("token restricted")
+	}
+	return id, nil
+}
+
+// policy definition with parsed networks and compiled rules, prepared once on create, update or load
+type compiledPolicy struct {
+	allowedIP types.Networks
+	deniedIP  types.Networks
+	rules     []compiledRule
+}
+
+// validate definition before saving and prepare it for requests checks
+func compileDefinition(policy application.PolicyDefinition) (*compiledPolicy, error) {
+	allowed, denied, err := compileIP(policy.AllowedIP, policy.DeniedIP)
+	if err != nil {
+		return nil, err
+	}
+	if policy.JWT != nil {
+		if err := checkJWTDefinition(policy.JWT); err != nil {
+			return nil, fmt.Errorf("JWT: %w", err)
+		}
+	}
+	if policy.HMAC != nil {
+		if err := checkHMACDefinition(policy.HMAC); err != nil {
+			return nil, fmt.Errorf("HMAC: %w", err)
+		}
+	}
+	if policy.RateLimit != nil {
+		if err := checkRateLimitDefinition(policy.RateLimit); err != nil {
+			return nil, fmt.Errorf("rate limit: %w", err)
+		}
+	}
+	var rules = make([]compiledRule, 0, len(policy.Rules))
+	for i, rule := range policy.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		rules = append(rules, *compiled)
+	}
+	return &compiledPolicy{
+		allowedIP: allowed,
+		deniedIP:  denied,
+		rules:     rules,
+	}, nil
+}
+
+func compileIP(allowed, denied types.JsonStringSet) (allowedIP, deniedIP types.Networks, err error) {
+	for value := range allowed {
+		network, err := types.ParseNetwork(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("allowed IP: %w", err)
+		}
+		allowedIP = append(allowedIP, network)
+	}
+	for value := range denied {
+		network, err := types.ParseNetwork(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("denied IP: %w", err)
+		}
+		deniedIP = append(deniedIP, network)
+	}
+	return allowedIP, deniedIP, nil
+}
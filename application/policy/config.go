@@ -1,14 +1,72 @@
 package policy
 
 import (
+	"fmt"
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/internal"
+	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 type naiveFileStorePayload struct {
-	Policies []application.Policy `json:"policies"`
+	Policies []storedPolicy `json:"policies"`
+}
+
+// persisted policy: unlike API representation keeps salt and hash of tokens, JWT and HMAC secrets
+type storedPolicy struct {
+	application.Policy
+	Tokens     []storedToken `json:"tokens,omitempty"`
+	JWTSecret  string        `json:"jwt_secret,omitempty"`
+	HMACSecret string        `json:"hmac_secret,omitempty"`
+}
+
+type storedToken struct {
+	application.PolicyToken
+	Salt string `json:"salt"`
+	Hash string `json:"hash"`
+}
+
+func toStored(policies []application.Policy) []storedPolicy {
+	var ans = make([]storedPolicy, 0, len(policies))
+	for _, policy := range policies {
+		stored := storedPolicy{Policy: policy}
+		if policy.Definition.JWT != nil {
+			stored.JWTSecret = policy.Definition.JWT.Secret
+		}
+		if policy.Definition.HMAC != nil {
+			stored.HMACSecret = policy.Definition.HMAC.Secret
+		}
+		for _, token := range policy.Tokens {
+			stored.Tokens = append(stored.Tokens, storedToken{PolicyToken: token, Salt: token.Salt, Hash: token.Hash})
+		}
+		ans = append(ans, stored)
+	}
+	return ans
+}
+
+func fromStored(stored []storedPolicy) []application.Policy {
+	var ans = make([]application.Policy, 0, len(stored))
+	for _, item := range stored {
+		policy := item.Policy
+		// secrets inside definition are plain only in files saved before secrets were stored separately
+		if policy.Definition.JWT != nil && item.JWTSecret != "" {
+			policy.Definition.JWT.Secret = item.JWTSecret
+		}
+		if policy.Definition.HMAC != nil && item.HMACSecret != "" {
+			policy.Definition.HMAC.Secret = item.HMACSecret
+		}
+		policy.Tokens = nil
+		for _, token := range item.Tokens {
+			info := token.PolicyToken
+			info.Salt = token.Salt
+			info.Hash = token.Hash
+			policy.Tokens = append(policy.Tokens, info)
+		}
+		ans = append(ans, policy)
+	}
+	return ans
 }
 
 func FileConfig(filename string) *naiveFileStore {
@@ -23,21 +81,39 @@ type naiveFileStore struct {
 func (nfs *naiveFileStore) SetPolicies(policies []application.Policy) error {
 	nfs.lock.Lock()
 	defer nfs.lock.Unlock()
-	return internal.AtomicWriteJson(nfs.file, &naiveFileStorePayload{Policies: policies})
+	return internal.AtomicWriteJson(nfs.file, &naiveFileStorePayload{Policies: toStored(policies)})
 }
 
 func (nfs *naiveFileStore) GetPolicies() ([]application.Policy, error) {
 	nfs.lock.RLock()
-	defer nfs.lock.RUnlock()
 	var pd naiveFileStorePayload
 	err := internal.ReadJson(nfs.file, &pd)
-	if err == nil {
-		return pd.Policies, nil
-	}
+	nfs.lock.RUnlock()
 	if os.IsNotExist(err) {
 		return nil, nil
 	}
-	return nil, err
+	if err != nil {
+		return nil, err
+	}
+	policies := fromStored(pd.Policies)
+	return policies, nfs.migrate(policies)
+}
+
+// one-time migration of plain-text tokens to hashed tokens
+func (nfs *naiveFileStore) migrate(policies []application.Policy) error {
+	var changed bool
+	for i := range policies {
+		migrated, err := migrateTokens(&policies[i], time.Now())
+		if err != nil {
+			return fmt.Errorf("migrate tokens of policy %s: %w", policies[i].ID, err)
+		}
+		changed = changed || migrated
+	}
+	if !changed {
+		return nil
+	}
+	log.Println("policies: plain tokens migrated to hashed tokens in", nfs.file)
+	return nfs.SetPolicies(policies)
 }
 
 func Mock(policies ...application.Policy) *mockStore {
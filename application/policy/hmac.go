@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	maxSignedBody    = 10 * 1024 * 1024 // maximum size of body for HMAC verification
+	defaultTolerance = 5 * time.Minute
+)
+
+// verify HMAC signature of request body. Body is buffered in memory and replaced by the copy.
+func checkHMAC(policy *application.HMACPolicy, req *types.Request) error {
+	algorithm, err := hmacAlgorithm(policy.Algorithm)
+	if err != nil {
+		return err
+	}
+	signature := req.Headers[http.CanonicalHeaderKey(hmacHeader(policy))]
+	if signature == "" {
+		return fmt.Errorf("signature required")
+	}
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, maxSignedBody+1))
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if len(body) > maxSignedBody {
+			return fmt.Errorf("body is too big to verify signature")
+		}
+	}
+	if policy.Format == application.HMACStripe {
+		return checkStripeSignature(policy, algorithm, signature, body)
+	}
+	prefix := policy.Prefix
+	if prefix == "" && strings.Contains(signature, "=") {
+		prefix = hmacAlgorithmName(policy.Algorithm) + "=" // GitHub style: sha256=<hex>
+	}
+	if !strings.HasPrefix(signature, prefix) {
+		return fmt.Errorf("signature mismatch")
+	}
+	if !validSignature(policy.Secret, algorithm, strings.TrimPrefix(signature, prefix), body) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// signature header looks like t=<unix time>,v1=<hex>[,v1=<hex>]; signed payload is <unix time>.<body>
+func checkStripeSignature(policy *application.HMACPolicy, algorithm func() hash.Hash, header string, body []byte) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp")
+	}
+	tolerance := time.Duration(policy.Tolerance)
+	if tolerance <= 0 {
+		tolerance = defaultTolerance
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is out of tolerance")
+	}
+	payload := append([]byte(timestamp+"."), body...)
+	for _, signature := range signatures {
+		if validSignature(policy.Secret, algorithm, signature, payload) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+func validSignature(secret string, algorithm func() hash.Hash, signature string, payload []byte) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(algorithm, []byte(secret))
+	_, _ = mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func hmacHeader(policy *application.HMACPolicy) string {
+	switch {
+	case policy.Header != "":
+		return policy.Header
+	case policy.Format == application.HMACStripe:
+		return "Stripe-Signature"
+	default:
+		return "X-Hub-Signature-256"
+	}
+}
+
+func hmacAlgorithmName(name string) string {
+	if name == "" {
+		return "sha256"
+	}
+	return name
+}
+
+func hmacAlgorithm(name string) (func() hash.Hash, error) {
+	switch hmacAlgorithmName(name) {
+	case "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported HMAC algorithm %s", name)
+	}
+}
+
+func checkHMACDefinition(policy *application.HMACPolicy) error {
+	if policy.Secret == "" {
+		return fmt.Errorf("secret not defined")
+	}
+	if policy.Secret == application.MaskedSecret {
+		return fmt.Errorf("masked secret can be used only to keep saved secret")
+	}
+	if _, err := hmacAlgorithm(policy.Algorithm); err != nil {
+		return err
+	}
+	switch policy.Format {
+	case "", application.HMACHex, application.HMACStripe:
+		return nil
+	default:
+		return fmt.Errorf("unsupported HMAC format %s", policy.Format)
+	}
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/types"
@@ -21,6 +22,10 @@ func New(store Store) (*policiesImpl, error) {
 		store:            store,
 		policiesByID:     map[string]*application.Policy{},
 		policiesByLambda: map[string]string{},
+		compiled:         map[string]*compiledPolicy{},
+		keys:             newKeySets(),
+		limiters:         map[string]*limiter{},
+		usage:            map[string]map[string]time.Time{},
 	}
 	return impl, impl.load()
 }
@@ -30,6 +35,12 @@ type policiesImpl struct {
 	lock             sync.RWMutex
 	policiesByID     map[string]*application.Policy
 	policiesByLambda map[string]string
+	compiled         map[string]*compiledPolicy // by policy ID, rebuilt on each definition change
+	keys             *keySets
+	limitersLock     sync.Mutex
+	limiters         map[string]*limiter // by policy ID, shared by all linked lambdas
+	usageLock        sync.Mutex
+	usage            map[string]map[string]time.Time // last usage of tokens by policy ID and token ID, not saved yet
 }
 
 func (policies *policiesImpl) load() error {
@@ -39,7 +50,12 @@ func (policies *policiesImpl) load() error {
 	}
 	for _, item := range list {
 		cp := item
+		compiled, err := compileDefinition(item.Definition)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", item.ID, err)
+		}
 		policies.policiesByID[item.ID] = &cp
+		policies.compiled[item.ID] = compiled
 		for lambda := range item.Lambdas {
 			policies.policiesByLambda[lambda] = item.ID
 		}
@@ -54,6 +70,10 @@ func (policies *policiesImpl) List() []application.Policy {
 }
 
 func (policies *policiesImpl) Create(policy string, definition application.PolicyDefinition) (*application.Policy, error) {
+	compiled, err := compileDefinition(definition)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", policy, err)
+	}
 	policies.lock.Lock()
 	defer policies.lock.Unlock()
 	_, exist := policies.policiesByID[policy]
@@ -65,7 +85,11 @@ func (policies *policiesImpl) Create(policy string, definition application.Polic
 		Definition: definition,
 		Lambdas:    make(types.JsonStringSet),
 	}
+	if _, err := migrateTokens(info, time.Now()); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", policy, err)
+	}
 	policies.policiesByID[policy] = info
+	policies.compiled[policy] = compiled
 	return info, policies.store.SetPolicies(policies.unsafeList())
 }
 
@@ -80,6 +104,13 @@ func (policies *policiesImpl) Remove(policy string) error {
 		delete(policies.policiesByLambda, lambda)
 	}
 	delete(policies.policiesByID, policy)
+	delete(policies.compiled, policy)
+	policies.limitersLock.Lock()
+	delete(policies.limiters, policy)
+	policies.limitersLock.Unlock()
+	policies.usageLock.Lock()
+	delete(policies.usage, policy)
+	policies.usageLock.Unlock()
 	return policies.store.SetPolicies(policies.unsafeList())
 }
 
@@ -90,7 +121,18 @@ func (policies *policiesImpl) Update(policy string, definition application.Polic
 	if !exist {
 		return fmt.Errorf("policy %s does not exists", policy)
 	}
-	info.Definition = definition
+	definition = unmaskSecrets(definition, info.Definition)
+	compiled, err := compileDefinition(definition)
+	if err != nil {
+		return fmt.Errorf("policy %s: %w", policy, err)
+	}
+	updated := *info
+	updated.Definition = definition
+	if _, err := migrateTokens(&updated, time.Now()); err != nil {
+		return fmt.Errorf("policy %s: %w", policy, err)
+	}
+	*info = updated
+	policies.compiled[policy] = compiled
 	return policies.store.SetPolicies(policies.unsafeList())
 }
 
@@ -112,14 +154,199 @@ func (policies *policiesImpl) Apply(lambda string, policy string) error {
 }
 
 func (policies *policiesImpl) Inspect(lambda string, request *types.Request) error {
-	policy, applicable, err := policies.findPolicy(lambda)
+	info, compiled, applicable, err := policies.findPolicy(lambda)
 	if err != nil {
 		return err
 	}
 	if !applicable {
 		return nil
 	}
-	return checkPolicy(policy, request)
+	now := time.Now()
+	tokenID, err := checkPolicy(info, compiled, request, policies.keys, now)
+	if err != nil {
+		return err
+	}
+	if limit := info.Definition.RateLimit; limit != nil {
+		if wait, ok := policies.limiter(info.ID, *limit).Take(limitKey(*limit, request), now); !ok {
+			return &application.RateLimitError{RetryAfter: wait}
+		}
+	}
+	if tokenID != "" {
+		policies.touchToken(info.ID, tokenID, now)
+	}
+	return nil
+}
+
+// remember last usage time of token in memory. It will be saved by Dump
+func (policies *policiesImpl) touchToken(policy string, tokenID string, now time.Time) {
+	policies.usageLock.Lock()
+	defer policies.usageLock.Unlock()
+	tokens, ok := policies.usage[policy]
+	if !ok {
+		tokens = make(map[string]time.Time)
+		policies.usage[policy] = tokens
+	}
+	tokens[tokenID] = now
+}
+
+// forget not saved usage of token (ex: after rotation). Should be called under policies lock.
+func (policies *policiesImpl) unsafeForgetUsage(policy string, tokenID string) {
+	policies.usageLock.Lock()
+	defer policies.usageLock.Unlock()
+	delete(policies.usage[policy], tokenID)
+}
+
+// Dump saves last usage time of tokens, but not often than lastUsedPrecision for each token.
+func (policies *policiesImpl) Dump() error {
+	policies.lock.Lock()
+	defer policies.lock.Unlock()
+	policies.usageLock.Lock()
+	usage := policies.usage
+	policies.usage = make(map[string]map[string]time.Time)
+	policies.usageLock.Unlock()
+
+	var changed bool
+	for policy, used := range usage {
+		info, exists := policies.policiesByID[policy]
+		if !exists {
+			continue
+		}
+		var tokens []application.PolicyToken
+		for tokenID, at := range used {
+			idx := findTokenIndex(info.Tokens, tokenID)
+			if idx < 0 {
+				continue
+			}
+			if last := info.Tokens[idx].LastUsed; last != nil && at.Sub(*last) < lastUsedPrecision {
+				continue
+			}
+			if tokens == nil {
+				tokens = append([]application.PolicyToken(nil), info.Tokens...) // tokens could be used by readers
+			}
+			at := at
+			tokens[idx].LastUsed = &at
+		}
+		if tokens != nil {
+			info.Tokens = tokens
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := policies.store.SetPolicies(policies.unsafeList()); err != nil {
+		return fmt.Errorf("save last usage of tokens: %w", err)
+	}
+	return nil
+}
+
+func (policies *policiesImpl) IssueToken(policy string, title string, ttl time.Duration) (*application.IssuedToken, error) {
+	secret, err := randomHex(secretSize)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token, err := newToken(title, secret, now)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		token.Expires = &expires
+	}
+	policies.lock.Lock()
+	defer policies.lock.Unlock()
+	info, exists := policies.policiesByID[policy]
+	if !exists {
+		return nil, fmt.Errorf("policy %s does not exist", policy)
+	}
+	info.Tokens = append(append(make([]application.PolicyToken, 0, len(info.Tokens)+1), info.Tokens...), token)
+	return &application.IssuedToken{ID: token.ID, Secret: secret, Expires: token.Expires}, policies.store.SetPolicies(policies.unsafeList())
+}
+
+func (policies *policiesImpl) RotateToken(policy string, tokenID string) (*application.IssuedToken, error) {
+	secret, err := randomHex(secretSize)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := randomHex(saltSize)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	policies.lock.Lock()
+	defer policies.lock.Unlock()
+	info, idx, err := policies.unsafeFindToken(policy, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := append([]application.PolicyToken(nil), info.Tokens...)
+	token := &tokens[idx]
+	if token.Expires != nil {
+		expires := now.Add(token.Expires.Sub(token.Created))
+		token.Expires = &expires
+	}
+	token.Salt = salt
+	token.Hash = hashSecret(salt, secret)
+	token.Created = now
+	token.LastUsed = nil
+	info.Tokens = tokens
+	policies.unsafeForgetUsage(policy, tokenID)
+	return &application.IssuedToken{ID: token.ID, Secret: secret, Expires: token.Expires}, policies.store.SetPolicies(policies.unsafeList())
+}
+
+func (policies *policiesImpl) RevokeToken(policy string, tokenID string) error {
+	policies.lock.Lock()
+	defer policies.lock.Unlock()
+	info, idx, err := policies.unsafeFindToken(policy, tokenID)
+	if err != nil {
+		return err
+	}
+	tokens := make([]application.PolicyToken, 0, len(info.Tokens)-1)
+	tokens = append(append(tokens, info.Tokens[:idx]...), info.Tokens[idx+1:]...)
+	info.Tokens = tokens
+	policies.unsafeForgetUsage(policy, tokenID)
+	return policies.store.SetPolicies(policies.unsafeList())
+}
+
+func (policies *policiesImpl) EnableToken(policy string, tokenID string, enabled bool) error {
+	policies.lock.Lock()
+	defer policies.lock.Unlock()
+	info, idx, err := policies.unsafeFindToken(policy, tokenID)
+	if err != nil {
+		return err
+	}
+	if info.Tokens[idx].Enabled == enabled {
+		return nil
+	}
+	tokens := append([]application.PolicyToken(nil), info.Tokens...)
+	tokens[idx].Enabled = enabled
+	info.Tokens = tokens
+	return policies.store.SetPolicies(policies.unsafeList())
+}
+
+func (policies *policiesImpl) unsafeFindToken(policy string, tokenID string) (*application.Policy, int, error) {
+	info, exists := policies.policiesByID[policy]
+	if !exists {
+		return nil, 0, fmt.Errorf("policy %s does not exist", policy)
+	}
+	idx := findTokenIndex(info.Tokens, tokenID)
+	if idx < 0 {
+		return nil, 0, fmt.Errorf("token %s does not exist in policy %s", tokenID, policy)
+	}
+	return info, idx, nil
+}
+
+// get or create limiter of the policy. Limiter is re-created if configuration changed.
+func (policies *policiesImpl) limiter(policyID string, config application.RateLimit) *limiter {
+	policies.limitersLock.Lock()
+	defer policies.limitersLock.Unlock()
+	l, ok := policies.limiters[policyID]
+	if !ok || l.config != config {
+		l = newLimiter(config)
+		policies.limiters[policyID] = l
+	}
+	return l
 }
 
 func (policies *policiesImpl) Clear(lambda string) error {
@@ -178,7 +405,7 @@ func (policies *policiesImpl) unsafeList() []application.Policy {
 	return ans
 }
 
-func (policies *policiesImpl) findPolicy(lambda string) (policy application.PolicyDefinition, applicable bool, err error) {
+func (policies *policiesImpl) findPolicy(lambda string) (policy application.Policy, compiled *compiledPolicy, applicable bool, err error) {
 	policies.lock.RLock()
 	defer policies.lock.RUnlock()
 	policyId, exists := policies.policiesByLambda[lambda]
@@ -191,5 +418,20 @@ func (policies *policiesImpl) findPolicy(lambda string) (policy application.Poli
 		err = fmt.Errorf("corrupted policy data: lambda %s linked to unknown policy %s", lambda, policyId)
 		return
 	}
-	return info.Definition, true, nil
+	return *info, policies.compiled[policyId], true, nil
+}
+
+// replace masked secrets (as returned by API) by saved secrets
+func unmaskSecrets(definition application.PolicyDefinition, saved application.PolicyDefinition) application.PolicyDefinition {
+	if definition.JWT != nil && definition.JWT.Secret == application.MaskedSecret && saved.JWT != nil {
+		jwt := *definition.JWT
+		jwt.Secret = saved.JWT.Secret
+		definition.JWT = &jwt
+	}
+	if definition.HMAC != nil && definition.HMAC.Secret == application.MaskedSecret && saved.HMAC != nil {
+		hmac := *definition.HMAC
+		hmac.Secret = saved.HMAC.Secret
+		definition.HMAC = &hmac
+	}
+	return definition
 }
@@ -2,11 +2,27 @@ package policy
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/types"
 	"github.com/stretchr/testify/assert"
-	"io/ioutil"
-	"testing"
 )
 
 func TestNew(t *testing.T) {
@@ -14,15 +30,15 @@ func TestNew(t *testing.T) {
 		ID: "foo",
 		Definition: application.PolicyDefinition{
 			Public: false,
-			Tokens: map[string]string{
-				"DEADBEAF": "Consumer 1",
-				"BEAFDEAD": "Consumer 2",
-			},
 		},
 		Lambdas: map[string]bool{
 			"lambda-1": true,
 			"lambda-2": true,
 		},
+		Tokens: []application.PolicyToken{
+			mockToken("consumer-1", "DEADBEAF"),
+			mockToken("consumer-2", "BEAFDEAD"),
+		},
 	}))
 	if err != nil {
 		t.Error(err)
@@ -85,6 +101,539 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestIPRestrictions(t *testing.T) {
+	policy, err := New(Mock(application.Policy{
+		ID: "office",
+		Definition: application.PolicyDefinition{
+			Public:    true,
+			AllowedIP: types.StringSet("10.0.0.0/8", "2001:db8::/32", "127.0.0.2"),
+			DeniedIP:  types.StringSet("10.0.13.0/24"),
+		},
+		Lambdas: types.StringSet("lambda-1"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for address, allowed := range map[string]bool{
+		"127.0.0.2:9992":            true,
+		"10.1.2.3:1234":             true,
+		"[::ffff:10.1.2.3]:1234":    true,
+		"[2001:db8::1]:1234":        true,
+		"10.0.13.7:1234":            false,
+		"[::ffff:10.0.13.7]:1234":   false,
+		"192.168.1.1:1234":          false,
+		"[2001:db9::1]:1234":        false,
+		"10.1.2.3":                  true,
+		"not-an-address":            false,
+		"[::ffff:192.168.1.1]:1234": false,
+	} {
+		req := mockRequest("hello")
+		req.RemoteAddress = address
+		err := policy.Inspect("lambda-1", req)
+		if allowed {
+			assert.NoError(t, err, address)
+		} else {
+			assert.Error(t, err, address)
+		}
+	}
+	_, err = policy.Create("broken", application.PolicyDefinition{AllowedIP: types.StringSet("10.0.0.0/33")})
+	assert.Error(t, err)
+	err = policy.Update("office", application.PolicyDefinition{DeniedIP: types.StringSet("localhost")})
+	assert.Error(t, err)
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	b64 := func(v *big.Int) string { return base64.RawURLEncoding.EncodeToString(v.Bytes()) }
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		map[string]string{"kty": "RSA", "kid": "rsa-1", "alg": "RS256", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+		map[string]string{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+	}})
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if !assert.NoError(t, ioutil.WriteFile(jwksFile, jwks, 0600)) {
+		return
+	}
+
+	policy, err := New(Mock(application.Policy{
+		ID: "shared",
+		Definition: application.PolicyDefinition{
+			JWT: &application.JWTPolicy{
+				Secret:   "s3cr3t",
+				Issuer:   "issuer",
+				Audience: "service",
+				Claims:   map[string]string{"role": "admin", "sub": ""},
+			},
+		},
+		Lambdas: types.StringSet("lambda-1"),
+	}, application.Policy{
+		ID: "keys",
+		Definition: application.PolicyDefinition{
+			JWT: &application.JWTPolicy{JWKS: jwksFile},
+		},
+		Lambdas: types.StringSet("lambda-2"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		data, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return data
+	}
+	inspect := func(lambda string, token string) (*types.Request, error) {
+		req := mockRequest("hello")
+		req.Headers["Authorization"] = "Bearer " + token
+		return req, policy.Inspect(lambda, req)
+	}
+
+	valid := jwt.MapClaims{"iss": "issuer", "aud": []string{"other", "service"}, "exp": exp, "sub": "user-1", "role": "admin", "meta": map[string]int{"level": 2}}
+	req, err := inspect("lambda-1", sign(jwt.SigningMethodHS256, "", []byte("s3cr3t"), valid))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"iss": "issuer", "aud": `["other","service"]`, "exp": strconv.FormatInt(exp, 10), "sub": "user-1", "role": "admin", "meta": `{"level":2}`}, req.Claims)
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired":      {"iss": "issuer", "aud": "service", "exp": time.Now().Add(-time.Minute).Unix(), "sub": "user-1", "role": "admin"},
+		"no exp":       {"iss": "issuer", "aud": "service", "sub": "user-1", "role": "admin"},
+		"issuer":       {"iss": "other", "aud": "service", "exp": exp, "sub": "user-1", "role": "admin"},
+		"audience":     {"iss": "issuer", "aud": "other", "exp": exp, "sub": "user-1", "role": "admin"},
+		"claim value":  {"iss": "issuer", "aud": "service", "exp": exp, "sub": "user-1", "role": "user"},
+		"claim absent": {"iss": "issuer", "aud": "service", "exp": exp, "role": "admin"},
+	} {
+		_, err := inspect("lambda-1", sign(jwt.SigningMethodHS256, "", []byte("s3cr3t"), claims))
+		assert.Error(t, err, name)
+	}
+	_, err = inspect("lambda-1", sign(jwt.SigningMethodHS256, "", []byte("wrong"), valid))
+	assert.Error(t, err, "wrong secret")
+	_, err = inspect("lambda-1", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, valid))
+	assert.Error(t, err, "unexpected algorithm")
+
+	simple := jwt.MapClaims{"exp": exp, "sub": "user-2"}
+	req, err = inspect("lambda-2", sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, simple))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-2", req.Claims["sub"])
+	_, err = inspect("lambda-2", sign(jwt.SigningMethodES256, "ec-1", ecKey, simple))
+	assert.NoError(t, err)
+	_, err = inspect("lambda-2", sign(jwt.SigningMethodES256, "rsa-1", ecKey, simple))
+	assert.Error(t, err, "key of other type")
+	_, err = inspect("lambda-2", sign(jwt.SigningMethodHS256, "", []byte("s3cr3t"), simple))
+	assert.Error(t, err, "shared secret for JWKS")
+
+	_, err = policy.Create("broken", application.PolicyDefinition{JWT: &application.JWTPolicy{}})
+	assert.Error(t, err)
+}
+
+func TestHMAC(t *testing.T) {
+	policy, err := New(Mock(application.Policy{
+		ID:         "github",
+		Definition: application.PolicyDefinition{Public: true, HMAC: &application.HMACPolicy{Secret: "s3cr3t"}},
+		Lambdas:    types.StringSet("lambda-1"),
+	}, application.Policy{
+		ID:         "stripe",
+		Definition: application.PolicyDefinition{Public: true, HMAC: &application.HMACPolicy{Secret: "s3cr3t", Format: application.HMACStripe}},
+		Lambdas:    types.StringSet("lambda-2"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	signature := func(payload string) string {
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write([]byte(payload))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	inspect := func(lambda, header, signature string) error {
+		req := mockRequest("hello")
+		req.Headers[header] = signature
+		err := policy.Inspect(lambda, req)
+		// body is still readable
+		data, _ := ioutil.ReadAll(req.Body)
+		assert.Equal(t, "hello", string(data))
+		return err
+	}
+	assert.NoError(t, inspect("lambda-1", "X-Hub-Signature-256", "sha256="+signature("hello")))
+	assert.NoError(t, inspect("lambda-1", "X-Hub-Signature-256", signature("hello")))
+	assert.Error(t, inspect("lambda-1", "X-Hub-Signature-256", "sha256="+signature("world")))
+	assert.Error(t, inspect("lambda-1", "X-Signature", "sha256="+signature("hello")))
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	assert.NoError(t, inspect("lambda-2", "Stripe-Signature", "t="+now+",v1=00,v1="+signature(now+".hello")))
+	assert.Error(t, inspect("lambda-2", "Stripe-Signature", "t="+old+",v1="+signature(old+".hello")))
+	assert.Error(t, inspect("lambda-2", "Stripe-Signature", "t="+now+",v1="+signature("hello")))
+
+	_, err = policy.Create("broken", application.PolicyDefinition{HMAC: &application.HMACPolicy{Secret: "x", Algorithm: "md5"}})
+	assert.Error(t, err)
+}
+
+func TestSecrets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.json")
+	policy, err := New(FileConfig(file))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = policy.Create("signed", application.PolicyDefinition{
+		Public: true,
+		HMAC:   &application.HMACPolicy{Secret: "s3cr3t"},
+		JWT:    &application.JWTPolicy{Secret: "jwt-s3cr3t"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, policy.Apply("lambda-1", "signed"))
+
+	// API representation masks secrets
+	data, err := json.Marshal(policy.List())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(data), "s3cr3t")
+	assert.Contains(t, string(data), application.MaskedSecret)
+
+	// masked secret from API keeps saved secret on update
+	var list []application.Policy
+	if !assert.NoError(t, json.Unmarshal(data, &list)) || !assert.Len(t, list, 1) {
+		return
+	}
+	definition := list[0].Definition
+	definition.JWT = nil
+	assert.NoError(t, policy.Update("signed", definition))
+	_, err = policy.Create("masked", definition)
+	assert.Error(t, err, "masked secret without saved secret")
+
+	signed := func(policy application.Policies) error {
+		mac := hmac.New(sha256.New, []byte("s3cr3t"))
+		mac.Write([]byte("hello"))
+		req := mockRequest("hello")
+		req.Headers["X-Hub-Signature-256"] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return policy.Inspect("lambda-1", req)
+	}
+	assert.NoError(t, signed(policy))
+
+	// secrets are saved separately from definition
+	stored, err := ioutil.ReadFile(file)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(stored), `"hmac_secret": "s3cr3t"`)
+	}
+	restored, err := New(FileConfig(file))
+	if assert.NoError(t, err) {
+		assert.NoError(t, signed(restored))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	l := newLimiter(application.RateLimit{Requests: 2, Interval: types.JsonDuration(time.Second)})
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		_, ok := l.Take("a", now)
+		assert.True(t, ok)
+	}
+	wait, ok := l.Take("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	_, ok = l.Take("b", now)
+	assert.True(t, ok, "separate bucket")
+	_, ok = l.Take("a", now.Add(500*time.Millisecond))
+	assert.True(t, ok, "refilled")
+
+	policy, err := New(Mock(application.Policy{
+		ID: "global",
+		Definition: application.PolicyDefinition{
+			Public:    true,
+			RateLimit: &application.RateLimit{Requests: 1, Interval: types.JsonDuration(time.Hour), Key: application.RateLimitByGlobal},
+		},
+		Lambdas: types.StringSet("lambda-1", "lambda-2"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, policy.Inspect("lambda-1", mockRequest("hello")))
+	err = policy.Inspect("lambda-2", mockRequest("hello"))
+	var limitErr *application.RateLimitError
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.True(t, limitErr.RetryAfter > 59*time.Minute)
+	}
+	// updated limit resets state
+	assert.NoError(t, policy.Update("global", application.PolicyDefinition{
+		Public:    true,
+		RateLimit: &application.RateLimit{Requests: 1, Interval: types.JsonDuration(time.Hour), Key: application.RateLimitByToken},
+	}))
+	assert.NoError(t, policy.Inspect("lambda-1", mockRequest("hello")))
+
+	_, err = policy.Create("broken", application.PolicyDefinition{RateLimit: &application.RateLimit{Requests: 1, Key: "cookie"}})
+	assert.Error(t, err)
+}
+
+func TestRules(t *testing.T) {
+	policy, err := New(Mock(application.Policy{
+		ID: "rules",
+		Definition: application.PolicyDefinition{
+			DeniedIP: types.StringSet("10.0.0.13"),
+			Rules: []application.PolicyRule{
+				{Path: "/admin/**", Action: application.RuleDeny},
+				{Methods: []string{"get", "HEAD"}, Action: application.RuleAllow, Public: true},
+				{Path: "/hooks/*", Headers: map[string]string{"x-event": "^(push|pull)$"}, Action: application.RuleAllow, TokenIDs: types.StringSet("hook")},
+				{ContentType: "text/*", Action: application.RuleAllow, AllowedIP: types.StringSet("192.168.0.0/16")},
+				{Headers: map[string]string{"X-Debug": ".+"}, Action: application.RuleDeny},
+			},
+		},
+		Lambdas: types.StringSet("lambda-1"),
+		Tokens:  []application.PolicyToken{mockToken("default", "policy-token"), mockToken("hook", "hook-token")},
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	type request struct {
+		method, path, token, remote, contentType string
+		headers                                  map[string]string
+	}
+	for name, c := range map[string]struct {
+		req     request
+		allowed bool
+	}{
+		"public GET":                {req: request{method: "GET", path: "lambda-1/users"}, allowed: true},
+		"public HEAD":               {req: request{method: "HEAD", path: "lambda-1"}, allowed: true},
+		"denied admin before GET":   {req: request{method: "GET", path: "lambda-1/admin"}, allowed: false},
+		"denied admin nested":       {req: request{method: "GET", path: "lambda-1/admin/users/1"}, allowed: false},
+		"admin-like path":           {req: request{method: "GET", path: "lambda-1/administrator"}, allowed: true},
+		"policy IP applies to rule": {req: request{method: "GET", path: "lambda-1", remote: "10.0.0.13:1234"}, allowed: false},
+		"POST without token":        {req: request{method: "POST", path: "lambda-1/users"}, allowed: false},
+		"POST with policy token":    {req: request{method: "POST", path: "lambda-1/users", token: "policy-token"}, allowed: true},
+		"hook with token":           {req: request{method: "POST", path: "lambda-1/hooks/github", token: "hook-token", headers: map[string]string{"X-Event": "push"}}, allowed: true},
+		"rule token outside rule":   {req: request{method: "POST", path: "lambda-1/users", token: "hook-token"}, allowed: false},
+		"hook with policy token":    {req: request{method: "POST", path: "lambda-1/hooks/github", token: "policy-token", headers: map[string]string{"X-Event": "push"}}, allowed: false},
+		"hook with other event":     {req: request{method: "POST", path: "lambda-1/hooks/github", token: "policy-token", headers: map[string]string{"X-Event": "issue"}}, allowed: true},
+		"hook nested path":          {req: request{method: "POST", path: "lambda-1/hooks/github/x", token: "hook-token", headers: map[string]string{"X-Event": "push"}}, allowed: false},
+		"text from allowed IP":      {req: request{method: "POST", path: "lambda-1", token: "policy-token", contentType: "text/plain; charset=utf-8", remote: "192.168.1.1:1234"}, allowed: true},
+		"text from other IP":        {req: request{method: "POST", path: "lambda-1", token: "policy-token", contentType: "text/plain", remote: "172.16.0.1:1234"}, allowed: false},
+		"text without token":        {req: request{method: "POST", path: "lambda-1", contentType: "text/plain", remote: "192.168.1.1:1234"}, allowed: false},
+		"debug header":              {req: request{method: "POST", path: "lambda-1", token: "policy-token", headers: map[string]string{"X-Debug": "1"}}, allowed: false},
+		"empty debug header":        {req: request{method: "POST", path: "lambda-1", token: "policy-token", headers: map[string]string{"X-Debug": ""}}, allowed: true},
+	} {
+		req := mockRequest("hello")
+		req.Method = c.req.method
+		req.Path = c.req.path
+		req.Headers["Content-Type"] = c.req.contentType
+		if c.req.token != "" {
+			req.Headers["Authorization"] = c.req.token
+		}
+		if c.req.remote != "" {
+			req.RemoteAddress = c.req.remote
+		}
+		for k, v := range c.req.headers {
+			req.Headers[k] = v
+		}
+		err := policy.Inspect("lambda-1", req)
+		if c.allowed {
+			assert.NoError(t, err, name)
+		} else {
+			assert.Error(t, err, name)
+		}
+	}
+
+	for name, rule := range map[string]application.PolicyRule{
+		"action":       {Action: "maybe"},
+		"path":         {Action: application.RuleAllow, Path: "/[a-"},
+		"header":       {Action: application.RuleAllow, Headers: map[string]string{"X": "("}},
+		"content type": {Action: application.RuleAllow, ContentType: "text/[x"},
+		"IP":           {Action: application.RuleAllow, AllowedIP: types.StringSet("10.0.0.0/99")},
+	} {
+		_, err := policy.Create("broken", application.PolicyDefinition{Rules: []application.PolicyRule{rule}})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRules_compiled(t *testing.T) {
+	_, err := New(Mock(application.Policy{
+		ID:         "broken",
+		Definition: application.PolicyDefinition{Rules: []application.PolicyRule{{Action: application.RuleAllow, Headers: map[string]string{"X": "("}}}},
+	}))
+	assert.Error(t, err, "stored definitions are validated on load")
+
+	policy, err := New(Mock(application.Policy{
+		ID: "rules",
+		Definition: application.PolicyDefinition{
+			Public: true,
+			Rules:  []application.PolicyRule{{Headers: map[string]string{"x-debug": ".+"}, Action: application.RuleDeny}},
+		},
+		Lambdas: types.StringSet("lambda-1"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	req := mockRequest("hello")
+	req.Headers["X-Debug"] = "1"
+	assert.Error(t, policy.Inspect("lambda-1", req))
+
+	err = policy.Update("rules", application.PolicyDefinition{
+		Public: true,
+		Rules:  []application.PolicyRule{{Headers: map[string]string{"x-debug": "^0$"}, Action: application.RuleDeny}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, policy.Inspect("lambda-1", req), "compiled rules must be replaced on update")
+
+	err = policy.Update("rules", application.PolicyDefinition{Public: true, DeniedIP: types.StringSet("10.0.0.0/99")})
+	assert.Error(t, err)
+	assert.NoError(t, policy.Inspect("lambda-1", req), "failed update must keep previous rules")
+}
+
+func TestTokens(t *testing.T) {
+	policy, err := New(Mock(application.Policy{
+		ID:      "private",
+		Lambdas: types.StringSet("lambda-1"),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	inspect := func(secret string) error {
+		req := mockRequest("hello")
+		req.Headers["Authorization"] = secret
+		return policy.Inspect("lambda-1", req)
+	}
+	issued, err := policy.IssueToken("private", "consumer", 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, issued.Expires)
+	assert.NoError(t, inspect(issued.Secret))
+	assert.Error(t, inspect(issued.ID))
+
+	info, err := policy.Get("private")
+	if assert.NoError(t, err) && assert.Len(t, info.Tokens, 1) {
+		token := info.Tokens[0]
+		assert.Equal(t, issued.ID, token.ID)
+		assert.Equal(t, "consumer", token.Title)
+		assert.NotContains(t, token.Hash, issued.Secret)
+		assert.Nil(t, token.LastUsed, "usage is saved only by dump")
+	}
+	assert.NoError(t, policy.Dump())
+	info, err = policy.Get("private")
+	if assert.NoError(t, err) && assert.Len(t, info.Tokens, 1) {
+		assert.NotNil(t, info.Tokens[0].LastUsed)
+	}
+
+	assert.NoError(t, policy.EnableToken("private", issued.ID, false))
+	assert.Error(t, inspect(issued.Secret), "disabled")
+	assert.NoError(t, policy.EnableToken("private", issued.ID, true))
+	assert.NoError(t, inspect(issued.Secret))
+
+	rotated, err := policy.RotateToken("private", issued.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, issued.ID, rotated.ID)
+	assert.Error(t, inspect(issued.Secret), "old secret")
+	assert.NoError(t, inspect(rotated.Secret))
+
+	assert.NoError(t, policy.RevokeToken("private", issued.ID))
+	assert.Error(t, inspect(rotated.Secret), "revoked")
+	assert.Error(t, policy.RevokeToken("private", issued.ID))
+
+	expiring, err := policy.IssueToken("private", "temporary", 50*time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, expiring.Expires)
+	assert.NoError(t, inspect(expiring.Secret))
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, inspect(expiring.Secret), "expired")
+	rotated, err = policy.RotateToken("private", expiring.ID)
+	if assert.NoError(t, err) {
+		assert.NoError(t, inspect(rotated.Secret), "expiration shifted")
+	}
+
+	// plain tokens are converted on update
+	assert.NoError(t, policy.Update("private", application.PolicyDefinition{Tokens: map[string]string{"plain": "legacy"}}))
+	assert.NoError(t, inspect("plain"))
+	info, err = policy.Get("private")
+	if assert.NoError(t, err) {
+		assert.Empty(t, info.Definition.Tokens)
+		assert.Len(t, info.Tokens, 2)
+	}
+}
+
+func TestTokens_usage(t *testing.T) {
+	policy, err := New(Mock(application.Policy{
+		ID:         "limited",
+		Definition: application.PolicyDefinition{RateLimit: &application.RateLimit{Requests: 1, Interval: types.JsonDuration(time.Hour), Key: application.RateLimitByGlobal}},
+		Lambdas:    types.StringSet("lambda-1"),
+		Tokens:     []application.PolicyToken{mockToken("first", "first-token"), mockToken("second", "second-token")},
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	inspect := func(secret string) error {
+		req := mockRequest("hello")
+		req.Headers["Authorization"] = secret
+		return policy.Inspect("lambda-1", req)
+	}
+	assert.NoError(t, inspect("first-token"))
+	assert.Error(t, inspect("second-token"), "rate limited")
+	assert.NoError(t, policy.Dump())
+
+	info, err := policy.Get("limited")
+	if assert.NoError(t, err) && assert.Len(t, info.Tokens, 2) {
+		assert.NotNil(t, info.Tokens[0].LastUsed)
+		assert.Nil(t, info.Tokens[1].LastUsed, "rejected request is not a token usage")
+	}
+}
+
+func TestFileConfig_migration(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.json")
+	err := ioutil.WriteFile(file, []byte(`{"policies":[{"id":"legacy","definition":{"tokens":{"DEADBEAF":"Consumer 1"}},"lambdas":["lambda-1"]}]}`), 0600)
+	if !assert.NoError(t, err) {
+		return
+	}
+	store := FileConfig(file)
+	policy, err := New(store)
+	if !assert.NoError(t, err) {
+		return
+	}
+	req := mockRequest("hello")
+	req.Headers["Authorization"] = "DEADBEAF"
+	assert.NoError(t, policy.Inspect("lambda-1", req))
+
+	data, err := ioutil.ReadFile(file)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(data), "DEADBEAF")
+	}
+	list, err := store.GetPolicies()
+	if assert.NoError(t, err) && assert.Len(t, list, 1) && assert.Len(t, list[0].Tokens, 1) {
+		assert.Equal(t, "Consumer 1", list[0].Tokens[0].Title)
+		assert.True(t, list[0].Tokens[0].Enabled)
+		assert.NotEmpty(t, list[0].Tokens[0].Hash)
+	}
+
+	// API representation hides salt and hash
+	apiData, err := json.Marshal(policy.List())
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(apiData), list[0].Tokens[0].Hash)
+		assert.NotContains(t, string(apiData), `"salt"`)
+	}
+
+	// hashed tokens survive restart
+	restored, err := New(FileConfig(file))
+	if assert.NoError(t, err) {
+		assert.NoError(t, restored.Inspect("lambda-1", req))
+	}
+}
+
+func mockToken(id, secret string) application.PolicyToken {
+	return application.PolicyToken{ID: id, Salt: "salt", Hash: hashSecret("salt", secret), Enabled: true}
+}
+
 func mockRequest(payload string) *types.Request {
 	return &types.Request{
 		Method:        "POST",
@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// verify bearer token from Authorization header and return validated claims
+func checkJWT(policy *application.JWTPolicy, authorization string, keys *keySets) (map[string]string, error) {
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return nil, fmt.Errorf("bearer token required")
+	}
+	parser := jwt.Parser{UseJSONNumber: true}
+	if policy.JWKS != "" {
+		parser.ValidMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+	} else {
+		parser.ValidMethods = []string{jwt.SigningMethodHS256.Alg()}
+	}
+	token, err := parser.Parse(strings.TrimSpace(authorization[len(prefix):]), func(token *jwt.Token) (interface{}, error) {
+		if policy.JWKS == "" {
+			if policy.Secret == "" {
+				return nil, fmt.Errorf("secret not defined")
+			}
+			return []byte(policy.Secret), nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return keys.Find(policy.JWKS, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token without expiration")
+	}
+	if policy.Issuer != "" && !claims.VerifyIssuer(policy.Issuer, true) {
+		return nil, fmt.Errorf("issuer mismatch")
+	}
+	if policy.Audience != "" && !claims.VerifyAudience(policy.Audience, true) {
+		return nil, fmt.Errorf("audience mismatch")
+	}
+	var values = make(map[string]string, len(claims))
+	for name, value := range claims {
+		values[name] = claimValue(value)
+	}
+	for name, expected := range policy.Claims {
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("claim %s required", name)
+		}
+		if expected != "" && value != expected {
+			return nil, fmt.Errorf("claim %s mismatch", name)
+		}
+	}
+	return values, nil
+}
+
+// scalar claims as-is, objects and arrays as JSON
+func claimValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool, nil:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func checkJWTDefinition(policy *application.JWTPolicy) error {
+	if (policy.Secret == "") == (policy.JWKS == "") {
+		return fmt.Errorf("exactly one of secret or JWKS file should be defined")
+	}
+	if policy.Secret == application.MaskedSecret {
+		return fmt.Errorf("masked secret can be used only to keep saved secret")
+	}
+	return nil
+}
+
+func newKeySets() *keySets {
+	return &keySets{files: make(map[string]*keySet)}
+}
+
+// cache of JWKS files. Files are re-read after modification.
+type keySets struct {
+	lock  sync.Mutex
+	files map[string]*keySet
+}
+
+type keySet struct {
+	modified time.Time
+	keys     []jwk
+}
+
+type jwk struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// Find public key by key ID (if set) and algorithm.
+func (ks *keySets) Find(file string, kid string, alg string) (interface{}, error) {
+	set, err := ks.get(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range set.keys {
+		if kid != "" && key.ID != kid {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		switch key.Key.(type) {
+		case *rsa.PublicKey:
+			if alg == jwt.SigningMethodRS256.Alg() {
+				return key.Key, nil
+			}
+		case *ecdsa.PublicKey:
+			if alg == jwt.SigningMethodES256.Alg() {
+				return key.Key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("key %q for %s not found", kid, alg)
+}
+
+func (ks *keySets) get(file string) (*keySet, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS: %w", err)
+	}
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	if set, ok := ks.files[file]; ok && set.modified.Equal(stat.ModTime()) {
+		return set, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("JWKS %s: %w", file, err)
+	}
+	set := &keySet{modified: stat.ModTime(), keys: keys}
+	ks.files[file] = set
+	return set, nil
+}
+
+// parse RSA and EC (P-256) public keys. Unsupported keys are skipped.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var payload struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	var ans []jwk
+	for _, key := range payload.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(key.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: decode modulus: %w", key.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: decode exponent: %w", key.Kid, err)
+			}
+			ans = append(ans, jwk{ID: key.Kid, Algorithm: key.Alg, Key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}})
+		case "EC":
+			if key.Crv != "P-256" {
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: decode x: %w", key.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(key.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: decode y: %w", key.Kid, err)
+			}
+			ans = append(ans, jwk{ID: key.Kid, Algorithm: key.Alg, Key: &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}})
+		}
+	}
+	return ans, nil
+}
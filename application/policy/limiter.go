@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const maxIdleBuckets = 10000 // number of buckets before cleanup of the idle ones
+
+// token bucket limiter for each key
+func newLimiter(config application.RateLimit) *limiter {
+	interval := time.Duration(config.Interval)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	burst := config.Burst
+	if burst <= 0 {
+		burst = config.Requests
+	}
+	return &limiter{
+		config:  config,
+		rate:    float64(config.Requests) / interval.Seconds(),
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+type limiter struct {
+	config  application.RateLimit
+	rate    float64 // tokens per second
+	burst   float64
+	lock    sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Take one token from the bucket of the key. Returns time to wait for the next token if bucket is empty.
+func (l *limiter) Take(key string, now time.Time) (time.Duration, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.cleanupUnsafe(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration(math.Ceil((1 - b.tokens) / l.rate * float64(time.Second)))
+	return wait, false
+}
+
+func (l *limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+}
+
+// remove full buckets - they are equal to new one
+func (l *limiter) cleanupUnsafe(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// key of the request bucket according to limit configuration
+func limitKey(config application.RateLimit, req *types.Request) string {
+	switch config.Key {
+	case application.RateLimitByToken:
+		return req.Headers["Authorization"]
+	case application.RateLimitByGlobal:
+		return ""
+	default:
+		if ip := types.ParseIP(req.RemoteAddress); ip != nil {
+			return ip.String()
+		}
+		return req.RemoteAddress
+	}
+}
+
+func checkRateLimitDefinition(config *application.RateLimit) error {
+	if config.Requests <= 0 {
+		return fmt.Errorf("number of requests should be positive")
+	}
+	if config.Interval < 0 || config.Burst < 0 {
+		return fmt.Errorf("interval and burst should not be negative")
+	}
+	switch config.Key {
+	case "", application.RateLimitByIP, application.RateLimitByToken, application.RateLimitByGlobal:
+		return nil
+	default:
+		return fmt.Errorf("unknown key %s", config.Key)
+	}
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// rule definition with parsed networks and compiled header patterns
+type compiledRule struct {
+	application.PolicyRule
+	allowedIP types.Networks
+	deniedIP  types.Networks
+	headers   map[string]*regexp.Regexp // by canonical header name
+}
+
+// first rule matched by request or -1
+func matchRule(rules []compiledRule, req *types.Request) int {
+	for i, rule := range rules {
+		if ruleMatches(rule, req) {
+			return i
+		}
+	}
+	return -1
+}
+
+func ruleMatches(rule compiledRule, req *types.Request) bool {
+	if len(rule.Methods) > 0 && !hasMethod(rule.Methods, req.Method) {
+		return false
+	}
+	if rule.Path != "" && !matchPath(rule.Path, subPath(req.Path)) {
+		return false
+	}
+	for header, exp := range rule.headers {
+		if !exp.MatchString(req.Headers[header]) {
+			return false
+		}
+	}
+	if rule.ContentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(req.Headers["Content-Type"])
+		if ok, _ := path.Match(strings.ToLower(rule.ContentType), mediaType); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sub-path of request after resource name (lambda UID, link, queue or topic) with leading slash
+func subPath(requestPath string) string {
+	parts := strings.SplitN(strings.TrimPrefix(requestPath, "/"), "/", 2)
+	if len(parts) < 2 {
+		return "/"
+	}
+	return "/" + parts[1]
+}
+
+// glob where * doesn't match /; pattern with /** suffix matches the prefix and everything under it
+func matchPath(pattern string, value string) bool {
+	if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern {
+		if prefix == "" {
+			return true
+		}
+		if ok, _ := path.Match(prefix, value); ok {
+			return true
+		}
+		for i := len(value) - 1; i > 0; i-- {
+			if value[i] != '/' {
+				continue
+			}
+			if ok, _ := path.Match(prefix, value[:i]); ok {
+				return true
+			}
+		}
+		return false
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+func compileRule(rule application.PolicyRule) (*compiledRule, error) {
+	switch rule.Action {
+	case application.RuleAllow, application.RuleDeny:
+	default:
+		return nil, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.Path != "" {
+		if _, err := path.Match(strings.TrimSuffix(rule.Path, "/**"), ""); err != nil {
+			return nil, fmt.Errorf("path: %w", err)
+		}
+	}
+	var headers = make(map[string]*regexp.Regexp, len(rule.Headers))
+	for header, pattern := range rule.Headers {
+		exp, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("header %s: %w", header, err)
+		}
+		headers[http.CanonicalHeaderKey(header)] = exp
+	}
+	if rule.ContentType != "" {
+		if _, err := path.Match(rule.ContentType, ""); err != nil {
+			return nil, fmt.Errorf("content type: %w", err)
+		}
+	}
+	allowed, denied, err := compileIP(rule.AllowedIP, rule.DeniedIP)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledRule{
+		PolicyRule: rule,
+		allowedIP:  allowed,
+		deniedIP:   denied,
+		headers:    headers,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	secretSize        = 32          // bytes of generated token secret
+	saltSize          = 16          // bytes of salt
+	lastUsedPrecision = time.Minute // last usage of token is saved not often than once per interval
+)
+
+// new token with hashed secret
+func newToken(title string, secret string, now time.Time) (application.PolicyToken, error) {
+	salt, err := randomHex(saltSize)
+	if err != nil {
+		return application.PolicyToken{}, err
+	}
+	return application.PolicyToken{
+		ID:      uuid.New().String(),
+		Title:   title,
+		Salt:    salt,
+		Hash:    hashSecret(salt, secret),
+		Created: now,
+		Enabled: true,
+	}, nil
+}
+
+func hashSecret(salt string, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	var data = make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		return "", fmt.Errorf("generate random: %w", err)
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// find valid token by secret. All tokens are checked to keep constant time.
+func findToken(tokens []application.PolicyToken, secret string, now time.Time) (string, bool) {
+	var found string
+	for _, token := range tokens {
+		match := subtle.ConstantTimeCompare([]byte(hashSecret(token.Salt, secret)), []byte(token.Hash)) == 1
+		if match && token.Valid(now) && found == "" {
+			found = token.ID
+		}
+	}
+	return found, found != ""
+}
+
+// convert plain tokens of definition to hashed tokens. Returns true if policy changed.
+func migrateTokens(policy *application.Policy, now time.Time) (bool, error) {
+	if len(policy.Definition.Tokens) == 0 {
+		return false, nil
+	}
+	var tokens = make([]application.PolicyToken, 0, len(policy.Tokens)+len(policy.Definition.Tokens))
+	tokens = append(tokens, policy.Tokens...)
+	for secret, title := range policy.Definition.Tokens {
+		if _, exists := findToken(tokens, secret, now); exists {
+			continue
+		}
+		token, err := newToken(title, secret, now)
+		if err != nil {
+			return false, err
+		}
+		tokens = append(tokens, token)
+	}
+	policy.Tokens = tokens
+	policy.Definition.Tokens = nil
+	return true, nil
+}
+
+// IDs of tokens referenced by rules: such tokens are accepted only by the rules
+func ruleTokens(definition application.PolicyDefinition) types.JsonStringSet {
+	var ans = types.StringSet()
+	for _, rule := range definition.Rules {
+		for id := range rule.TokenIDs {
+			ans.Set(id)
+		}
+	}
+	return ans
+}
+
+func findTokenIndex(tokens []application.PolicyToken, tokenID string) int {
+	for i, token := range tokens {
+		if token.ID == tokenID {
+			return i
+		}
+	}
+	return -1
+}
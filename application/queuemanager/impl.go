@@ -1,18 +1,27 @@
 package queuemanager
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"os"
+	"math"
+	"math/rand"
+	"net/http"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/queue"
+	"github.com/reddec/trusted-cgi/queue/inmemory"
+	"github.com/reddec/trusted-cgi/tracing"
 	"github.com/reddec/trusted-cgi/types"
 )
 
@@ -31,25 +40,56 @@ type Platform interface {
 
 type QueueFactory func(name string) (queue.Queue, error)
 
-func New(ctx context.Context, config Store, platform Platform, factory QueueFactory) (*queueManager, error) {
+// DeadLetterFactory creates storage for failed requests of the queue
+type DeadLetterFactory func(name string) (queue.DeadLetters, error)
+
+// JobsFactory creates storage for jobs of the queue with store result mode
+type JobsFactory func(name string) (queue.Jobs, error)
+
+// Observer of queue processing (ex: metrics). Called from workers, so it should be thread-safe and non-blocking
+type Observer interface {
+	// Task processed after number of attempts (starting from 1). Error is nil if task finally succeeded
+	Processed(queue string, attempts int, err error)
+}
+
+// New queue manager. If dead-letters or jobs factory is nil, failed requests or jobs will be kept in memory.
+func New(ctx context.Context, config Store, platform Platform, factory QueueFactory, deadLetters DeadLetterFactory, jobs JobsFactory) (*queueManager, error) {
+	if deadLetters == nil {
+		deadLetters = func(name string) (queue.DeadLetters, error) {
+			return inmemory.NewDeadLetters(), nil
+		}
+	}
+	if jobs == nil {
+		jobs = func(name string) (queue.Jobs, error) {
+			return inmemory.NewJobs(), nil
+		}
+	}
 	qm := &queueManager{
-		ctx:          ctx,
-		platform:     platform,
-		queues:       map[string]*queueDefinition{},
-		queueFactory: factory,
-		config:       config,
+		ctx:               ctx,
+		platform:          platform,
+		queues:            map[string]*queueDefinition{},
+		queueFactory:      factory,
+		deadLetterFactory: deadLetters,
+		jobsFactory:       jobs,
+		config:            config,
 	}
 	return qm, qm.init()
 }
 
 type queueManager struct {
-	ctx          context.Context
-	lock         sync.RWMutex
-	platform     Platform
-	queues       map[string]*queueDefinition
-	queueFactory QueueFactory
-	config       Store
-	wg           sync.WaitGroup
+	ctx               context.Context
+	lock              sync.RWMutex
+	platform          Platform
+	queues            map[string]*queueDefinition
+	queueFactory      QueueFactory
+	deadLetterFactory DeadLetterFactory
+	jobsFactory       JobsFactory
+	config            Store
+	wg                sync.WaitGroup
+	observerLock      sync.RWMutex // guards observer; workers never take main lock
+	observer          Observer
+	targetsLock       sync.RWMutex           // guards targets; never held while waiting for workers
+	targets           map[string]queue.Queue // backends of queues by name for forwarding from workers
 }
 
 func (qm *queueManager) init() error {
@@ -75,10 +115,36 @@ func (qm *queueManager) Put(queue string, request *types.Request) error {
 	if !ok {
 		return fmt.Errorf("queue %s does not exist", queue)
 	}
+	if callback := request.Headers[application.CallbackHeader]; q.Result == application.ResultCallback && callback != "" && !q.CallbackAllowed(callback) {
+		return fmt.Errorf("queue %s: %w", queue, application.ErrCallbackNotAllowed)
+	}
 	if q.MaxElementSize > 0 {
 		request.Body = ioutil.NopCloser(io.LimitReader(stream, q.MaxElementSize))
 	}
-	return q.queue.Put(qm.ctx, request)
+	if q.Result == "" {
+		return q.queue.Put(qm.ctx, request)
+	}
+	job := &types.Job{
+		ID:      uuid.New().String(),
+		Queue:   queue,
+		Status:  types.JobQueued,
+		Created: time.Now(),
+	}
+	job.Updated = job.Created
+	request.SetHeader(application.JobHeader, job.ID)
+	if q.jobs != nil {
+		if err := q.jobs.Save(job); err != nil {
+			return fmt.Errorf("queue %s: save job: %w", queue, err)
+		}
+	}
+	err := q.queue.Put(qm.ctx, request)
+	if err != nil && q.jobs != nil {
+		job.Status = types.JobFailed
+		job.Error = err.Error()
+		job.Updated = time.Now()
+		_ = q.jobs.Save(job)
+	}
+	return err
 }
 
 func (qm *queueManager) Add(queue application.Queue) error {
@@ -99,21 +165,48 @@ func (qm *queueManager) addQueueUnsafe(queue application.Queue) error {
 	if ok {
 		return fmt.Errorf("queue %s already exists", queue.Name)
 	}
+	if queue.DeadLetterQueue == queue.Name {
+		return fmt.Errorf("queue %s can not be dead-letter queue for itself", queue.Name)
+	}
+	switch queue.Result {
+	case "", application.ResultCallback, application.ResultStore:
+	case application.ResultReply:
+		if queue.ReplyQueue == "" || queue.ReplyQueue == queue.Name {
+			return fmt.Errorf("queue %s should have reply queue other than itself", queue.Name)
+		}
+	default:
+		return fmt.Errorf("queue %s has unknown result mode %s", queue.Name, queue.Result)
+	}
+	if err := queue.CheckCallbackURLs(); err != nil {
+		return fmt.Errorf("queue %s: %w", queue.Name, err)
+	}
 
 	back, err := qm.queueFactory(queue.Name)
 	if err != nil {
 		return fmt.Errorf("add queue %s - create backend for queue: %w", queue.Name, err)
 	}
+	dead, err := qm.deadLetterFactory(queue.Name)
+	if err != nil {
+		return fmt.Errorf("add queue %s - create dead-letter storage: %w", queue.Name, err)
+	}
 
 	q = &queueDefinition{
-		Queue:  queue,
-		worker: startWorker(qm.ctx, back, queue, qm.platform, &qm.wg),
-		queue:  back,
+		Queue: queue,
+		queue: back,
+		dead:  dead,
+	}
+	if queue.Result == application.ResultStore {
+		q.jobs, err = qm.jobsFactory(queue.Name)
+		if err != nil {
+			return fmt.Errorf("add queue %s - create jobs storage: %w", queue.Name, err)
+		}
 	}
+	q.worker = qm.startWorker(q)
 	if qm.queues == nil {
 		qm.queues = make(map[string]*queueDefinition)
 	}
 	qm.queues[queue.Name] = q
+	qm.setTarget(queue.Name, back)
 	return nil
 }
 
@@ -127,10 +220,20 @@ func (qm *queueManager) Remove(queue string) error {
 	q.worker.stop()
 	<-q.worker.done
 	delete(qm.queues, queue)
+	qm.setTarget(queue, nil)
 	err := q.queue.Destroy()
 	if err != nil {
 		return err
 	}
+	err = q.dead.Destroy()
+	if err != nil {
+		return err
+	}
+	if q.jobs != nil {
+		if err := q.jobs.Destroy(); err != nil {
+			return err
+		}
+	}
 	return qm.config.SetQueues(qm.listUnsafe())
 }
 
@@ -144,7 +247,7 @@ func (qm *queueManager) Assign(queue string, targetLambda string) error {
 	q.worker.stop()
 	<-q.worker.done
 	q.Target = targetLambda
-	q.worker = startWorker(qm.ctx, q.queue, q.Queue, qm.platform, &qm.wg)
+	q.worker = qm.startWorker(q)
 	return qm.config.SetQueues(qm.listUnsafe())
 }
 
@@ -190,10 +293,163 @@ func (qm *queueManager) Wait() {
 	qm.wg.Wait()
 }
 
+// Observe processing of tasks in all queues. Nil observer disables notifications
+func (qm *queueManager) Observe(observer Observer) {
+	qm.observerLock.Lock()
+	defer qm.observerLock.Unlock()
+	qm.observer = observer
+}
+
+// Depths of all queues (number of stored requests) by name
+func (qm *queueManager) Depths() map[string]int64 {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	var ans = make(map[string]int64, len(qm.queues))
+	for name, q := range qm.queues {
+		ans[name] = q.queue.Len()
+	}
+	return ans
+}
+
+func (qm *queueManager) DeadLetters(queue string) ([]types.DeadLetter, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", queue)
+	}
+	return q.dead.List()
+}
+
+func (qm *queueManager) ReplayDeadLetters(queue string, ids []string) (int, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("queue %s does not exist", queue)
+	}
+	ids, err := letterIDs(q.dead, ids)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		letter, body, err := q.dead.Get(id)
+		if err != nil {
+			return i, fmt.Errorf("get dead letter %s: %w", id, err)
+		}
+		err = q.queue.Put(qm.ctx, letter.Request.WithBody(body))
+		if err != nil {
+			return i, fmt.Errorf("replay dead letter %s: %w", id, err)
+		}
+		err = q.dead.Remove(id)
+		if err != nil {
+			return i + 1, fmt.Errorf("remove replayed dead letter %s: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+func (qm *queueManager) PurgeDeadLetters(queue string, ids []string) (int, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[queue]
+	if !ok {
+		return 0, fmt.Errorf("queue %s does not exist", queue)
+	}
+	ids, err := letterIDs(q.dead, ids)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := q.dead.Remove(id); err != nil {
+			return i, fmt.Errorf("remove dead letter %s: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+func (qm *queueManager) Stats(queue string) (*application.QueueStats, error) {
+	q, inspector, err := qm.inspector(queue)
+	if err != nil {
+		return nil, err
+	}
+	return &application.QueueStats{
+		Name:        queue,
+		Depth:       q.queue.Len(),
+		InFlight:    inspector.InFlight(),
+		Size:        inspector.Size(),
+		DeadLetters: q.dead.Len(),
+	}, nil
+}
+
+func (qm *queueManager) Peek(queue string, limit int) ([]types.QueueItem, error) {
+	_, inspector, err := qm.inspector(queue)
+	if err != nil {
+		return nil, err
+	}
+	return inspector.List(limit)
+}
+
+func (qm *queueManager) DeleteMessage(queue string, id uint64) error {
+	_, inspector, err := qm.inspector(queue)
+	if err != nil {
+		return err
+	}
+	return inspector.Delete(id)
+}
+
+func (qm *queueManager) Purge(queue string) (int, error) {
+	_, inspector, err := qm.inspector(queue)
+	if err != nil {
+		return 0, err
+	}
+	return inspector.Purge()
+}
+
+func (qm *queueManager) inspector(name string) (*queueDefinition, queue.Inspector, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("queue %s does not exist", name)
+	}
+	inspector, ok := q.queue.(queue.Inspector)
+	if !ok {
+		return nil, nil, fmt.Errorf("queue %s does not support inspection", name)
+	}
+	return q, inspector, nil
+}
+
+// all IDs of letters if list is empty
+func letterIDs(dead queue.DeadLetters, ids []string) ([]string, error) {
+	if len(ids) > 0 {
+		return ids, nil
+	}
+	list, err := dead.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, letter := range list {
+		ids = append(ids, letter.ID)
+	}
+	return ids, nil
+}
+
+func (qm *queueManager) processed(queue string, attempts int, err error) {
+	qm.observerLock.RLock()
+	observer := qm.observer
+	qm.observerLock.RUnlock()
+	if observer != nil {
+		observer.Processed(queue, attempts, err)
+	}
+}
+
 type queueDefinition struct {
 	application.Queue
 	worker *worker
 	queue  queue.Queue
+	dead   queue.DeadLetters
+	jobs   queue.Jobs // only for store result mode
 }
 
 type worker struct {
@@ -201,66 +457,445 @@ type worker struct {
 	done chan struct{}
 }
 
-func startWorker(gctx context.Context, queue queue.Queue, definition application.Queue, plt Platform, wg *sync.WaitGroup) *worker {
-	ctx, cancel := context.WithCancel(gctx)
+// start workers (consumers) for the queue. Definition is copied, so workers should be restarted after changes.
+func (qm *queueManager) startWorker(q *queueDefinition) *worker {
+	ctx, cancel := context.WithCancel(qm.ctx)
 	w := &worker{
 		stop: cancel,
 		done: make(chan struct{}),
 	}
-	wg.Add(1)
+	definition := q.Queue
+	consumers := definition.Workers
+	if consumers < 1 {
+		consumers = 1
+	}
+	var wg sync.WaitGroup
+	qm.wg.Add(1)
+	if q.jobs != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			qm.expireJobs(ctx, definition, q.jobs)
+		}()
+	}
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			qm.consume(ctx, definition, q)
+		}()
+	}
 	go func() {
-		defer wg.Done()
+		defer qm.wg.Done()
 		defer close(w.done)
-		for {
-			err := doTask(ctx, plt, definition, queue)
-			if err != nil {
-				log.Println("queues: queue", definition.Name, "failed process task:", err)
+		wg.Wait()
+	}()
+	return w
+}
+
+// lease and process tasks till context canceled
+func (qm *queueManager) consume(ctx context.Context, definition application.Queue, q *queueDefinition) {
+	for {
+		begin := time.Now()
+		id, err := q.queue.Lease(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
+			log.Println("queues: failed lease", definition.Name, ":", err)
 			select {
+			case <-time.After(leaseFailedDelay):
+				continue
 			case <-ctx.Done():
 				return
-			default:
 			}
-			err = queue.Commit(ctx)
-			if err != nil {
-				log.Println("queues: failed commit - waiting", commitFailedDelay)
-				select {
-				case <-time.After(commitFailedDelay):
-				case <-ctx.Done():
-					return
-				}
+		}
+		var head *types.Request
+		var result *resultBuffer
+		if definition.Result != "" {
+			head, result = qm.startJob(definition, q, id)
+		}
+		attempts, traceparent, err := doTask(ctx, qm.platform, definition, q.queue, id, begin, result)
+		if err != nil {
+			log.Println("queues: queue", definition.Name, "failed process task:", err)
+		}
+		if ctx.Err() != nil {
+			// stopped before finish - task should be processed again by next worker
+			if err := q.queue.Release(context.Background(), id); err != nil {
+				log.Println("queues: queue", definition.Name, "failed release task:", err)
 			}
+			return
 		}
-	}()
+		qm.processed(definition.Name, attempts, err)
+		var forwardErr error
+		if err != nil {
+			forwardErr = qm.deadLetter(ctx, definition, q, id, attempts, err)
+		}
+		if head != nil && forwardErr == nil {
+			forwardErr = qm.deliverResult(ctx, definition, q, head, attempts, result, err)
+		}
+		if forwardErr != nil {
+			// failed task or result is not saved anywhere - task should be processed again
+			if err := q.queue.Release(context.Background(), id); err != nil {
+				log.Println("queues: queue", definition.Name, "failed release task:", err)
+			}
+			select {
+			case <-time.After(leaseFailedDelay):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		_, span := tracing.Start(tracing.WithRemote(ctx, traceparent), "queue commit")
+		span.SetAttribute("queue.name", definition.Name)
+		err = q.queue.Ack(ctx, id)
+		span.End(err)
+		if err != nil {
+			log.Println("queues: queue", definition.Name, "failed ack task:", err)
+		}
+	}
+}
 
-	return w
+// move failed task to dead-letter queue or to dead-letter storage. Returns error if task was not saved
+func (qm *queueManager) deadLetter(ctx context.Context, definition application.Queue, q *queueDefinition, id uint64, attempts int, reason error) error {
+	if definition.DeadLetterQueue == "" && !definition.DeadLetter {
+		return nil
+	}
+	req, err := q.queue.Open(id)
+	if err != nil {
+		log.Println("queues: queue", definition.Name, "failed open task for dead letter:", err)
+		return err
+	}
+	defer req.Body.Close()
+	letter := types.DeadLetter{
+		Queue:    definition.Name,
+		Reason:   lastError(reason),
+		Attempts: attempts,
+		Failed:   time.Now(),
+		Request:  *req.WithBody(nil),
+	}
+	if definition.DeadLetterQueue != "" {
+		err = qm.forward(ctx, definition.DeadLetterQueue, &letter, req.Body)
+		if err == nil {
+			return nil
+		}
+		log.Println("queues: queue", definition.Name, "failed forward task to dead-letter queue", definition.DeadLetterQueue, ":", err)
+		// body could be partially consumed
+		if req, err = q.queue.Open(id); err != nil {
+			log.Println("queues: queue", definition.Name, "failed open task for dead letter:", err)
+			return err
+		}
+		defer req.Body.Close()
+	}
+	if err := q.dead.Put(&letter, req.Body); err != nil {
+		log.Println("queues: queue", definition.Name, "failed save dead letter:", err)
+		return err
+	}
+	return nil
 }
 
-func doTask(ctx context.Context, plt Platform, definition application.Queue, queue queue.Queue) error {
-	for i := 0; i <= definition.Retry; i++ {
-		req, err := queue.Peek(ctx)
+// put failed request to another queue with failure details in headers
+func (qm *queueManager) forward(ctx context.Context, target string, letter *types.DeadLetter, body io.Reader) error {
+	req := letter.Request
+	req.SetHeader("X-Dead-Letter-Queue", letter.Queue)
+	req.SetHeader("X-Dead-Letter-Reason", letter.Reason)
+	req.SetHeader("X-Dead-Letter-Attempts", strconv.Itoa(letter.Attempts))
+	req.Body = ioutil.NopCloser(body)
+	return qm.putTo(ctx, target, &req)
+}
 
+// put request to another queue from worker. Main lock can't be used here, since Remove and Assign hold it while
+// waiting for the worker
+func (qm *queueManager) putTo(ctx context.Context, target string, req *types.Request) error {
+	qm.targetsLock.RLock()
+	dst, ok := qm.targets[target]
+	qm.targetsLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("queue %s does not exist", target)
+	}
+	return dst.Put(ctx, req)
+}
+
+// set (or remove if backend is nil) queue backend available for forwarding
+func (qm *queueManager) setTarget(name string, backend queue.Queue) {
+	qm.targetsLock.Lock()
+	defer qm.targetsLock.Unlock()
+	if backend == nil {
+		delete(qm.targets, name)
+		return
+	}
+	if qm.targets == nil {
+		qm.targets = make(map[string]queue.Queue)
+	}
+	qm.targets[name] = backend
+}
+
+// read headers of task and mark job as running (in store mode). Returns nil headers if task could not be read
+func (qm *queueManager) startJob(definition application.Queue, q *queueDefinition, id uint64) (*types.Request, *resultBuffer) {
+	req, err := q.queue.Open(id)
+	if err != nil {
+		log.Println("queues: queue", definition.Name, "failed open task for job:", err)
+		return nil, nil
+	}
+	_ = req.Body.Close()
+	head := req.WithBody(nil)
+	limit := definition.MaxResultSize
+	if limit <= 0 {
+		limit = defaultMaxResultSize
+	}
+	if q.jobs != nil {
+		job, err := q.jobs.Get(head.Headers[application.JobHeader])
+		if err != nil {
+			log.Println("queues: queue", definition.Name, "failed get job:", err)
+		} else {
+			job.Status = types.JobRunning
+			job.Updated = time.Now()
+			if err := q.jobs.Save(job); err != nil {
+				log.Println("queues: queue", definition.Name, "failed save job:", err)
+			}
+		}
+	}
+	return head, &resultBuffer{limit: limit}
+}
+
+// deliver output of processed task according to result mode of queue. Returns error if result was not put to
+// reply queue; other failures are only logged
+func (qm *queueManager) deliverResult(ctx context.Context, definition application.Queue, q *queueDefinition, head *types.Request, attempts int, result *resultBuffer, taskErr error) error {
+	jobID := head.Headers[application.JobHeader]
+	status := types.JobDone
+	var reason string
+	if taskErr != nil {
+		status = types.JobFailed
+		reason = lastError(taskErr)
+	}
+	var err error
+	switch definition.Result {
+	case application.ResultStore:
+		var job *types.Job
+		job, err = q.jobs.Get(jobID)
+		if err != nil {
+			break
+		}
+		job.Status = status
+		job.Attempts = attempts
+		job.Error = reason
+		job.Result = result.data.String()
+		job.Truncated = result.truncated
+		job.Updated = time.Now()
+		err = q.jobs.Save(job)
+	case application.ResultCallback:
+		callback := head.Headers[application.CallbackHeader]
+		if callback == "" {
+			return nil
+		}
+		if !definition.CallbackAllowed(callback) {
+			// allowed URLs could be changed after the task was queued
+			err = application.ErrCallbackNotAllowed
+			break
+		}
+		err = postResult(ctx, callback, definition.Name, jobID, status, attempts, reason, result)
+	case application.ResultReply:
+		reply := &types.Request{
+			Method:      http.MethodPost,
+			Path:        "/" + definition.ReplyQueue,
+			Traceparent: head.Traceparent,
+			Body:        ioutil.NopCloser(bytes.NewReader(result.data.Bytes())),
+		}
+		for name, value := range resultHeaders(definition.Name, jobID, status, attempts, reason, result) {
+			reply.SetHeader(name, value)
+		}
+		if err = qm.putTo(ctx, definition.ReplyQueue, reply); err != nil {
+			log.Println("queues: queue", definition.Name, "failed deliver result of job", jobID, ":", err)
+			return err
+		}
+	}
+	if err != nil {
+		log.Println("queues: queue", definition.Name, "failed deliver result of job", jobID, ":", err)
+	}
+	return nil
+}
+
+// client for callbacks: redirects are not followed, since target URL is checked only once
+var callbackClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// post output to callback URL
+func postResult(ctx context.Context, url string, queue, jobID, status string, attempts int, reason string, result *resultBuffer) error {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(result.data.Bytes()))
+	if err != nil {
+		return err
+	}
+	for name, value := range resultHeaders(queue, jobID, status, attempts, reason, result) {
+		req.Header.Set(name, value)
+	}
+	res, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("callback returned status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// headers with job details for reply queue and callback
+func resultHeaders(queue, jobID, status string, attempts int, reason string, result *resultBuffer) map[string]string {
+	var headers = map[string]string{
+		application.JobHeader: jobID,
+		"X-Job-Queue":         queue,
+		"X-Job-Status":        status,
+		"X-Job-Attempts":      strconv.Itoa(attempts),
+	}
+	if reason != "" {
+		headers["X-Job-Error"] = reason
+	}
+	if result.truncated {
+		headers["X-Job-Truncated"] = "true"
+	}
+	return headers
+}
+
+// remove expired jobs till context canceled
+func (qm *queueManager) expireJobs(ctx context.Context, definition application.Queue, jobs queue.Jobs) {
+	ttl := time.Duration(definition.ResultTTL)
+	if ttl <= 0 {
+		ttl = defaultResultTTL
+	}
+	ticker := time.NewTicker(jobsExpireInterval)
+	defer ticker.Stop()
+	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		default:
+			return
+		case <-ticker.C:
+		}
+		if _, err := jobs.Expire(time.Now().Add(-ttl)); err != nil {
+			log.Println("queues: queue", definition.Name, "failed expire jobs:", err)
 		}
+	}
+}
+
+func (qm *queueManager) Job(queue string, id string) (*types.Job, error) {
+	qm.lock.RLock()
+	defer qm.lock.RUnlock()
+	q, ok := qm.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %s does not exist", queue)
+	}
+	if q.jobs == nil {
+		return nil, fmt.Errorf("queue %s does not store jobs", queue)
+	}
+	return q.jobs.Get(id)
+}
 
+// error of the last attempt
+func lastError(err error) string {
+	if cause := errors.Unwrap(err); cause != nil {
+		err = cause
+	}
+	return err.Error()
+}
+
+// captures output up to limit, the rest is dropped
+type resultBuffer struct {
+	limit     int64
+	data      bytes.Buffer
+	truncated bool
+}
+
+func (rb *resultBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if free := rb.limit - int64(rb.data.Len()); int64(n) > free {
+		p = p[:free]
+		rb.truncated = true
+	}
+	rb.data.Write(p)
+	return n, nil
+}
+
+func (rb *resultBuffer) Reset() {
+	rb.data.Reset()
+	rb.truncated = false
+}
+
+// process leased task with retries. Returns number of used attempts, traceparent of the task and error of the last attempt
+func doTask(ctx context.Context, plt Platform, definition application.Queue, queue queue.Queue, id uint64, leased time.Time, result *resultBuffer) (int, string, error) {
+	var traceparent string
+	var lastErr error
+	for i := 0; i <= definition.Retry; i++ {
+		req, err := queue.Open(id)
 		if err != nil {
-			log.Println("queues: failed peek", definition.Name, ":", err)
-		} else if err = plt.InvokeByUID(ctx, definition.Target, *req, os.Stderr); err != nil {
-			log.Println("queues: failed invoke by uid", definition.Target, "from queue", definition.Name, ":", err)
+			log.Println("queues: failed open task", id, "from", definition.Name, ":", err)
 		} else {
-			return nil
+			if i == 0 {
+				traceparent = req.Traceparent
+				_, peek := tracing.StartAt(tracing.WithRemote(ctx, traceparent), "queue peek", leased)
+				peek.SetKind(tracing.KindConsumer)
+				peek.SetAttribute("queue.name", definition.Name)
+				peek.End(nil)
+			}
+			var out io.Writer = io.Discard
+			if result != nil {
+				result.Reset()
+				out = result
+			}
+			err = invokeTask(tracing.WithRemote(ctx, traceparent), plt, definition, *req, i+1, out)
+			if err == nil {
+				return i + 1, traceparent, nil
+			}
+			log.Println("queues: failed invoke by uid", definition.Target, "from queue", definition.Name, ":", err)
+		}
+		lastErr = err
+		if i == definition.Retry {
+			break
 		}
 
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(time.Duration(definition.Interval)):
+			return i + 1, traceparent, ctx.Err()
+		case <-time.After(backoff(definition, i)):
 		}
 	}
-	return fmt.Errorf("failed to process task for queue %s after all attempts", definition.Name)
+	return definition.Retry + 1, traceparent, fmt.Errorf("failed to process task for queue %s after all attempts: %w", definition.Name, lastErr)
 }
 
-const commitFailedDelay = 3 * time.Second
+// delay after failed attempt (starting from 0): exponential growth from interval limited by max interval with jitter
+func backoff(definition application.Queue, attempt int) time.Duration {
+	delay := float64(definition.Interval)
+	if definition.Multiplier > 1 {
+		delay *= math.Pow(definition.Multiplier, float64(attempt))
+	}
+	if limit := float64(definition.MaxInterval); limit > 0 && delay > limit {
+		delay = limit
+	}
+	if jitter := math.Min(definition.Jitter, 1); jitter > 0 {
+		delay += delay * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// invoke target lambda within trace of the task
+func invokeTask(ctx context.Context, plt Platform, definition application.Queue, req types.Request, attempt int, out io.Writer) error {
+	ctx, span := tracing.Start(ctx, "queue task")
+	span.SetAttribute("queue.name", definition.Name)
+	span.SetAttribute("lambda.uid", definition.Target)
+	span.SetAttribute("queue.attempt", attempt)
+	err := plt.InvokeByUID(ctx, definition.Target, req, out)
+	span.End(err)
+	return err
+}
+
+const (
+	leaseFailedDelay     = 3 * time.Second
+	callbackTimeout      = 30 * time.Second
+	jobsExpireInterval   = time.Minute
+	defaultResultTTL     = 24 * time.Hour
+	defaultMaxResultSize = 1024 * 1024
+)
@@ -3,16 +3,21 @@ package queuemanager_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
+	"github.com/reddec/trusted-cgi/tracing"
 	"github.com/reddec/trusted-cgi/types"
 )
 
@@ -49,6 +54,7 @@ func TestNew(t *testing.T) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	qm, err := queuemanager.New(ctx,
 		queuemanager.Mock(application.Queue{
@@ -59,7 +65,7 @@ func TestNew(t *testing.T) {
 			Target: "greeter",
 		}), platform, func(name string) (queue.Queue, error) {
 			return inmemory.New(10), nil
-		})
+		}, nil, nil)
 	if err != nil {
 		t.Error(err)
 		return
@@ -132,6 +138,483 @@ func TestNew(t *testing.T) {
 	qm.Wait()
 }
 
+type processedTask struct {
+	queue    string
+	attempts int
+	err      error
+}
+
+type observerFunc func(queue string, attempts int, err error)
+
+func (of observerFunc) Processed(queue string, attempts int, err error) { of(queue, attempts, err) }
+
+func TestQueueManager_Observe(t *testing.T) {
+	var calls int
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"flaky": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				calls++
+				if calls < 2 {
+					return errors.New("try again")
+				}
+				return nil
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan processedTask, 1)
+	qm.Observe(observerFunc(func(queue string, attempts int, err error) {
+		processed <- processedTask{queue: queue, attempts: attempts, err: err}
+	}))
+
+	err = qm.Add(application.Queue{Name: "queue-1", Target: "flaky", Retry: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth := qm.Depths()["queue-1"]; depth != 0 {
+		t.Error("should be empty queue but", depth)
+	}
+	err = qm.Put("queue-1", mockRequest("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := <-processed
+	if task.queue != "queue-1" || task.attempts != 2 || task.err != nil {
+		t.Errorf("unexpected result: %+v", task)
+	}
+
+	cancel()
+	qm.Wait()
+}
+
+func TestQueueManager_Workers(t *testing.T) {
+	const workers = 3
+	var started = make(chan struct{}, workers)
+	var release = make(chan struct{})
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"slow": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				started <- struct{}{}
+				<-release
+				return nil
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(application.Queue{Name: "queue-1", Target: "slow", Workers: workers}), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan processedTask, workers)
+	qm.Observe(observerFunc(func(queue string, attempts int, err error) {
+		processed <- processedTask{queue: queue, attempts: attempts, err: err}
+	}))
+	for i := 0; i < workers; i++ {
+		if err := qm.Put("queue-1", mockRequest("hello world")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// all tasks should be in progress at the same time
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("tasks are not processed concurrently")
+		}
+	}
+	close(release)
+	for i := 0; i < workers; i++ {
+		if task := <-processed; task.err != nil {
+			t.Error(task.err)
+		}
+	}
+	for i := 0; i < 100 && qm.Depths()["queue-1"] != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if depth := qm.Depths()["queue-1"]; depth != 0 {
+		t.Error("all tasks should be acknowledged but", depth, "left")
+	}
+	cancel()
+	qm.Wait()
+}
+
+type platformFunc func(ctx context.Context, uid string, request types.Request, out io.Writer) error
+
+func (pf platformFunc) InvokeByUID(ctx context.Context, uid string, request types.Request, out io.Writer) error {
+	return pf(ctx, uid, request, out)
+}
+
+type spansRecorder []tracing.SpanData
+
+func (sr *spansRecorder) Export(spans []tracing.SpanData) error {
+	*sr = append(*sr, spans...)
+	return nil
+}
+
+func (sr *spansRecorder) Close() error { return nil }
+
+func TestQueueManager_tracing(t *testing.T) {
+	var traceparents = make(chan string, 2)
+	platform := platformFunc(func(ctx context.Context, uid string, request types.Request, out io.Writer) error {
+		defer request.Body.Close()
+		traceparents <- tracing.Traceparent(ctx)
+		return nil
+	})
+	var spans spansRecorder
+	tracer := tracing.New(&spans)
+	ctx, cancel := context.WithCancel(tracing.WithTracer(context.Background(), tracer))
+	defer cancel()
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(application.Queue{Name: "queue-1", Target: "echo"}), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const producer = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := mockRequest("hello world")
+	req.Traceparent = producer
+	if err := qm.Put("queue-1", req); err != nil {
+		t.Fatal(err)
+	}
+	first := <-traceparents
+	// second task guarantees that the first one is committed
+	if err := qm.Put("queue-1", mockRequest("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	<-traceparents
+	cancel()
+	qm.Wait()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names = make(map[string]tracing.SpanData)
+	for _, span := range spans {
+		if span.TraceID.String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			names[span.Name] = span
+		}
+	}
+	for _, name := range []string{"queue peek", "queue task", "queue commit"} {
+		span, ok := names[name]
+		if !ok {
+			t.Fatal("no span", name, "in trace of producer")
+		}
+		if span.ParentSpanID.String() != "00f067aa0ba902b7" {
+			t.Error("span", name, "is not child of producer")
+		}
+	}
+	if expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + names["queue task"].SpanID.String() + "-01"; first != expected {
+		t.Error("lambda context", first, "should be", expected)
+	}
+}
+
+func TestQueueManager_DeadLetters(t *testing.T) {
+	var received = make(chan types.Request, 1)
+	var fail = true
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"broken": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, _ := ioutil.ReadAll(request.Body)
+				if fail {
+					return errors.New("broken")
+				}
+				received <- *request.WithBody(ioutil.NopCloser(bytes.NewReader(data)))
+				return nil
+			},
+			"fallback": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, _ := ioutil.ReadAll(request.Body)
+				received <- *request.WithBody(ioutil.NopCloser(bytes.NewReader(data)))
+				return nil
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "stored", Target: "broken", Retry: 1, DeadLetter: true},
+		application.Queue{Name: "forwarded", Target: "broken", DeadLetterQueue: "fallback"},
+		application.Queue{Name: "fallback", Target: "fallback"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan processedTask, 2)
+	qm.Observe(observerFunc(func(queue string, attempts int, err error) {
+		processed <- processedTask{queue: queue, attempts: attempts, err: err}
+	}))
+
+	// forwarded to another queue with failure details
+	if err := qm.Put("forwarded", mockRequest("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	forwarded := <-received
+	if forwarded.Headers["X-Dead-Letter-Queue"] != "forwarded" || forwarded.Headers["X-Dead-Letter-Reason"] != "broken" || forwarded.Headers["X-Dead-Letter-Attempts"] != "1" {
+		t.Errorf("unexpected headers of dead letter: %v", forwarded.Headers)
+	}
+	if data, _ := ioutil.ReadAll(forwarded.Body); string(data) != "hello world" {
+		t.Error("corrupted message:", string(data))
+	}
+	<-processed
+	<-processed
+
+	// stored in dead-letter storage
+	if err := qm.Put("stored", mockRequest("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	if task := <-processed; task.attempts != 2 || task.err == nil {
+		t.Errorf("unexpected result: %+v", task)
+	}
+	var letters []types.DeadLetter
+	for i := 0; i < 100 && len(letters) == 0; i++ {
+		letters, err = qm.DeadLetters("stored")
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(letters) != 1 {
+		t.Fatal("dead letter not stored")
+	}
+	letter := letters[0]
+	if letter.Queue != "stored" || letter.Attempts != 2 || letter.Reason != "broken" || letter.Size != 11 || letter.Request.Path != "/sample/hello world" {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+
+	// replay
+	fail = false
+	if n, err := qm.ReplayDeadLetters("stored", nil); err != nil || n != 1 {
+		t.Fatal("replay failed:", n, err)
+	}
+	if data, _ := ioutil.ReadAll((<-received).Body); string(data) != "hello world" {
+		t.Error("corrupted message:", string(data))
+	}
+	if letters, _ := qm.DeadLetters("stored"); len(letters) != 0 {
+		t.Error("replayed letter should be removed")
+	}
+
+	// purge
+	if n, err := qm.PurgeDeadLetters("stored", []string{"unknown"}); err == nil || n != 0 {
+		t.Error("purge of unknown letter should fail")
+	}
+	if n, err := qm.PurgeDeadLetters("stored", nil); err != nil || n != 0 {
+		t.Error("purge of empty storage failed:", n, err)
+	}
+
+	if err := qm.Add(application.Queue{Name: "self", Target: "broken", DeadLetterQueue: "self"}); err == nil {
+		t.Error("queue should not be dead-letter queue for itself")
+	}
+	cancel()
+	qm.Wait()
+}
+
+func TestQueueManager_Inspect(t *testing.T) {
+	release := make(chan struct{})
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"slow": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				<-release
+				return nil
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer close(release)
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "inspected", Target: "slow"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"first", "second", "third"} {
+		if err := qm.Put("inspected", mockRequest(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stats *application.QueueStats
+	for i := 0; i < 100; i++ {
+		stats, err = qm.Stats("inspected")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.InFlight == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats.Depth != 3 || stats.InFlight != 1 || stats.Size == 0 || stats.DeadLetters != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	items, err := qm.Peek("inspected", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || !items[0].Leased || items[1].Leased || items[1].Request.Path != "/sample/second" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+	if err := qm.DeleteMessage("inspected", items[0].ID); err == nil {
+		t.Error("in-flight request should not be deleted")
+	}
+	if err := qm.DeleteMessage("inspected", items[1].ID); err != nil {
+		t.Error(err)
+	}
+	if n, err := qm.Purge("inspected"); err != nil || n != 1 {
+		t.Error("purge failed:", n, err)
+	}
+	if _, err := qm.Stats("unknown"); err == nil {
+		t.Error("stats of unknown queue should fail")
+	}
+}
+
+func TestQueueManager_Results(t *testing.T) {
+	replies := make(chan types.Request, 1)
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"echo": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				_, err := io.Copy(out, request.Body)
+				return err
+			},
+			"broken": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				_, _ = out.Write([]byte("partial"))
+				return errors.New("broken")
+			},
+			"collector": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, err := ioutil.ReadAll(request.Body)
+				replies <- *request.WithBody(ioutil.NopCloser(bytes.NewReader(data)))
+				return err
+			},
+		},
+	}
+	callbacks := make(chan *http.Request, 1)
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		data, _ := ioutil.ReadAll(request.Body)
+		request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		callbacks <- request
+	}))
+	defer callbackServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "stored", Target: "echo", Result: application.ResultStore, MaxResultSize: 5},
+		application.Queue{Name: "failed", Target: "broken", Result: application.ResultStore},
+		application.Queue{Name: "replied", Target: "echo", Result: application.ResultReply, ReplyQueue: "replies"},
+		application.Queue{Name: "replies", Target: "collector"},
+		application.Queue{Name: "called", Target: "echo", Result: application.ResultCallback, CallbackURLs: types.StringSet(callbackServer.URL + "/hooks")},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := qm.Add(application.Queue{Name: "unknown-mode", Target: "echo", Result: "print"}); err == nil {
+		t.Error("queue with unknown result mode should not be added")
+	}
+	if err := qm.Add(application.Queue{Name: "no-reply", Target: "echo", Result: application.ResultReply}); err == nil {
+		t.Error("queue in reply mode without reply queue should not be added")
+	}
+	if err := qm.Add(application.Queue{Name: "bad-callback", Target: "echo", Result: application.ResultCallback, CallbackURLs: types.StringSet("file:///etc")}); err == nil {
+		t.Error("queue with invalid callback URL should not be added")
+	}
+
+	// stored result
+	waitJob := func(queue string, req *types.Request) *types.Job {
+		if err := qm.Put(queue, req); err != nil {
+			t.Fatal(err)
+		}
+		jobID := req.Headers[application.JobHeader]
+		if jobID == "" {
+			t.Fatal("job ID not assigned")
+		}
+		for i := 0; i < 100; i++ {
+			job, err := qm.Job(queue, jobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job.Finished() {
+				return job
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("job not finished")
+		return nil
+	}
+	job := waitJob("stored", mockRequest("hello world"))
+	if job.Status != types.JobDone || job.Result != "hello" || !job.Truncated || job.Attempts != 1 {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	job = waitJob("failed", mockRequest("hello world"))
+	if job.Status != types.JobFailed || job.Error != "broken" || job.Result != "partial" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if _, err := qm.Job("replied", job.ID); err == nil {
+		t.Error("queue without store mode should not have jobs")
+	}
+
+	// reply queue
+	req := mockRequest("hello world")
+	if err := qm.Put("replied", req); err != nil {
+		t.Fatal(err)
+	}
+	reply := <-replies
+	if reply.Headers[application.JobHeader] != req.Headers[application.JobHeader] || reply.Headers["X-Job-Status"] != types.JobDone || reply.Headers["X-Job-Queue"] != "replied" {
+		t.Errorf("unexpected reply headers: %v", reply.Headers)
+	}
+	if data, _ := ioutil.ReadAll(reply.Body); string(data) != "hello world" {
+		t.Error("unexpected reply:", string(data))
+	}
+
+	// callback
+	req = mockRequest("hello world")
+	req.Headers[application.CallbackHeader] = callbackServer.URL + "/other"
+	if err := qm.Put("called", req); !errors.Is(err, application.ErrCallbackNotAllowed) {
+		t.Error("callback URL out of allowed prefixes should be rejected:", err)
+	}
+	req = mockRequest("hello world")
+	req.Headers[application.CallbackHeader] = callbackServer.URL + "/hooks/done"
+	if err := qm.Put("called", req); err != nil {
+		t.Fatal(err)
+	}
+	callback := <-callbacks
+	if callback.Header.Get(application.JobHeader) != req.Headers[application.JobHeader] || callback.Header.Get("X-Job-Status") != types.JobDone {
+		t.Errorf("unexpected callback headers: %v", callback.Header)
+	}
+	if data, _ := ioutil.ReadAll(callback.Body); string(data) != "hello world" {
+		t.Error("unexpected callback:", string(data))
+	}
+}
+
 func mockRequest(payload string) *types.Request {
 	return &types.Request{
 		Method:        "POST",
@@ -147,3 +630,141 @@ func mockRequest(payload string) *types.Request {
 		Body: ioutil.NopCloser(bytes.NewBufferString(payload)),
 	}
 }
+
+func TestQueueManager_failedForward(t *testing.T) {
+	replies := make(chan types.Request, 1)
+	platform := &mockPlatform{
+		handlers: map[string]hf{
+			"echo": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				_, err := io.Copy(out, request.Body)
+				return err
+			},
+			"collector": func(request types.Request, out io.Writer) error {
+				defer request.Body.Close()
+				data, err := ioutil.ReadAll(request.Body)
+				replies <- *request.WithBody(ioutil.NopCloser(bytes.NewReader(data)))
+				return err
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "replied", Target: "echo", Result: application.ResultReply, ReplyQueue: "late"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan processedTask, 10)
+	qm.Observe(observerFunc(func(queue string, attempts int, err error) {
+		processed <- processedTask{queue: queue, attempts: attempts, err: err}
+	}))
+
+	if err := qm.Put("replied", mockRequest("hello world")); err != nil {
+		t.Fatal(err)
+	}
+	<-processed
+	// reply queue doesn't exist - task should be kept
+	time.Sleep(100 * time.Millisecond)
+	if stats, err := qm.Stats("replied"); err != nil || stats.Depth != 1 {
+		t.Fatalf("task should be released, not acknowledged: %+v %v", stats, err)
+	}
+
+	if err := qm.Add(application.Queue{Name: "late", Target: "collector"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reply := <-replies:
+		if data, _ := ioutil.ReadAll(reply.Body); string(data) != "hello world" {
+			t.Error("unexpected reply:", string(data))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reply not delivered after reply queue created")
+	}
+	cancel()
+	qm.Wait()
+}
+
+// queue which blocks Put of requests with X-Block header till unblocked
+type blockingQueue struct {
+	queue.Queue
+	unblock chan struct{}
+}
+
+func (bq *blockingQueue) Put(ctx context.Context, request *types.Request) error {
+	if request.Headers["X-Block"] != "" {
+		<-bq.unblock
+	}
+	return bq.Queue.Put(ctx, request)
+}
+
+func TestQueueManager_changeWhileFinishing(t *testing.T) {
+	for name, change := range map[string]func(qm application.Queues) error{
+		"remove": func(qm application.Queues) error { return qm.Remove("queue-1") },
+		"assign": func(qm application.Queues) error { return qm.Assign("queue-1", "other") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			platform := &mockPlatform{
+				handlers: map[string]hf{
+					"slow": func(request types.Request, out io.Writer) error {
+						defer request.Body.Close()
+						started <- struct{}{}
+						<-release
+						return nil
+					},
+				},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			backend := &blockingQueue{Queue: inmemory.New(10), unblock: make(chan struct{})}
+			qm, err := queuemanager.New(ctx, queuemanager.Mock(application.Queue{Name: "queue-1", Target: "slow"}), platform, func(name string) (queue.Queue, error) {
+				return backend, nil
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			processed := make(chan processedTask, 1)
+			qm.Observe(observerFunc(func(queue string, attempts int, err error) {
+				processed <- processedTask{queue: queue, attempts: attempts, err: err}
+			}))
+			if err := qm.Put("queue-1", mockRequest("hello world")); err != nil {
+				t.Fatal(err)
+			}
+			<-started
+
+			// blocked put holds manager for reading, so change waits for exclusive access
+			blocker := mockRequest("blocker")
+			blocker.Headers["X-Block"] = "1"
+			go func() { _ = qm.Put("queue-1", blocker) }()
+			time.Sleep(50 * time.Millisecond)
+			changed := make(chan error, 1)
+			go func() { changed <- change(qm) }()
+			time.Sleep(50 * time.Millisecond)
+
+			// task finishes while change is pending
+			close(release)
+			select {
+			case <-processed:
+			case <-time.After(5 * time.Second):
+				t.Error("finished task is not reported while queue change is pending")
+			}
+			close(backend.unblock)
+			select {
+			case err := <-changed:
+				if err != nil {
+					t.Error(err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("queue change is not completed")
+			}
+			cancel()
+			qm.Wait()
+		})
+	}
+}
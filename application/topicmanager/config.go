@@ -0,0 +1,67 @@
+package topicmanager
+
+import (
+	"os"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/internal"
+)
+
+type naiveFileStorePayload struct {
+	Topics []application.Topic `json:"topics"`
+}
+
+func FileConfig(filename string) *naiveFileStore {
+	return &naiveFileStore{file: filename}
+}
+
+type naiveFileStore struct {
+	file string
+	lock sync.RWMutex
+}
+
+func (nfs *naiveFileStore) SetTopics(topics []application.Topic) error {
+	nfs.lock.Lock()
+	defer nfs.lock.Unlock()
+	return internal.AtomicWriteJson(nfs.file, &naiveFileStorePayload{Topics: topics})
+}
+
+func (nfs *naiveFileStore) GetTopics() ([]application.Topic, error) {
+	nfs.lock.RLock()
+	defer nfs.lock.RUnlock()
+	var pd naiveFileStorePayload
+	err := internal.ReadJson(nfs.file, &pd)
+	if err == nil {
+		return pd.Topics, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return nil, err
+}
+
+func Mock(topics ...application.Topic) *mockStore {
+	return &mockStore{topics: topics}
+}
+
+type mockStore struct {
+	lock   sync.RWMutex
+	topics []application.Topic
+}
+
+func (msc *mockStore) SetTopics(topics []application.Topic) error {
+	msc.lock.Lock()
+	defer msc.lock.Unlock()
+	msc.topics = make([]application.Topic, len(topics))
+	copy(msc.topics, topics)
+	return nil
+}
+
+func (msc *mockStore) GetTopics() ([]application.Topic, error) {
+	msc.lock.RLock()
+	defer msc.lock.RUnlock()
+	out := make([]application.Topic, len(msc.topics))
+	copy(out, msc.topics)
+	return out, nil
+}
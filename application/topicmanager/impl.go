@@ -0,0 +1,192 @@
+package topicmanager
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Store contains topics configuration for reload
+type Store interface {
+	// Save topics list
+	SetTopics(topics []application.Topic) error
+	// Load topics list
+	GetTopics() ([]application.Topic, error)
+}
+
+// New topic manager. Published requests are delivered to subscribed queues of the queues manager.
+func New(config Store, queues application.Queues) (*topicManager, error) {
+	tm := &topicManager{
+		config: config,
+		queues: queues,
+		topics: make(map[string]*application.Topic),
+	}
+	return tm, tm.init()
+}
+
+type topicManager struct {
+	lock   sync.RWMutex
+	config Store
+	queues application.Queues
+	topics map[string]*application.Topic
+}
+
+func (tm *topicManager) init() error {
+	list, err := tm.config.GetTopics()
+	if err != nil {
+		return err
+	}
+	for _, topic := range list {
+		topic := topic
+		if topic.Queues == nil {
+			topic.Queues = types.StringSet()
+		}
+		tm.topics[topic.Name] = &topic
+	}
+	return nil
+}
+
+// Publish request to all subscribed queues. Body is saved to temporary file once and piped to each queue.
+// Removed queues are skipped. Error is returned if request was not put to at least one of queues.
+func (tm *topicManager) Publish(topic string, request *types.Request) error {
+	defer request.Body.Close()
+	subscribers, err := tm.subscribers(topic)
+	if err != nil {
+		return err
+	}
+	if len(subscribers) == 0 {
+		return nil
+	}
+	body, err := ioutil.TempFile("", "topic-*")
+	if err != nil {
+		return fmt.Errorf("publish to topic %s - create temp file: %w", topic, err)
+	}
+	defer os.Remove(body.Name())
+	defer body.Close()
+	size, err := io.Copy(body, request.Body)
+	if err != nil {
+		return fmt.Errorf("publish to topic %s - save body: %w", topic, err)
+	}
+
+	var failed []string
+	for _, name := range subscribers {
+		if _, err := tm.queues.Get(name); err != nil {
+			log.Println("topics: topic", topic, "skips removed queue", name)
+			continue
+		}
+		cp := *request
+		cp.Body = ioutil.NopCloser(io.NewSectionReader(body, 0, size))
+		if err := tm.queues.Put(name, &cp); err != nil {
+			log.Println("topics: topic", topic, "failed put request to queue", name, ":", err)
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("publish to topic %s - failed put request to queues: %s", topic, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (tm *topicManager) Add(topic string) error {
+	if !application.TopicNameReg.MatchString(topic) {
+		return fmt.Errorf("invalid topic name: should be %v", application.TopicNameReg)
+	}
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	if _, ok := tm.topics[topic]; ok {
+		return fmt.Errorf("topic %s already exists", topic)
+	}
+	tm.topics[topic] = &application.Topic{Name: topic, Queues: types.StringSet()}
+	return tm.config.SetTopics(tm.listUnsafe())
+}
+
+func (tm *topicManager) Remove(topic string) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	if _, ok := tm.topics[topic]; !ok {
+		return nil
+	}
+	delete(tm.topics, topic)
+	return tm.config.SetTopics(tm.listUnsafe())
+}
+
+func (tm *topicManager) Subscribe(topic string, queue string) error {
+	if _, err := tm.queues.Get(queue); err != nil {
+		return fmt.Errorf("subscribe to topic %s: %w", topic, err)
+	}
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	t, ok := tm.topics[topic]
+	if !ok {
+		return fmt.Errorf("topic %s does not exist", topic)
+	}
+	t.Queues.Set(queue)
+	return tm.config.SetTopics(tm.listUnsafe())
+}
+
+func (tm *topicManager) Unsubscribe(topic string, queue string) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	t, ok := tm.topics[topic]
+	if !ok {
+		return fmt.Errorf("topic %s does not exist", topic)
+	}
+	t.Queues.Del(queue)
+	return tm.config.SetTopics(tm.listUnsafe())
+}
+
+func (tm *topicManager) List() []application.Topic {
+	tm.lock.RLock()
+	var ans = tm.listUnsafe()
+	tm.lock.RUnlock()
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Name < ans[j].Name
+	})
+	return ans
+}
+
+func (tm *topicManager) Get(topic string) (*application.Topic, error) {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	t, ok := tm.topics[topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %s does not exist", topic)
+	}
+	cp := *t
+	cp.Queues = t.Queues.Dup()
+	return &cp, nil
+}
+
+// names of subscribed queues ordered by name
+func (tm *topicManager) subscribers(topic string) ([]string, error) {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	t, ok := tm.topics[topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %s does not exist", topic)
+	}
+	var names = make([]string, 0, len(t.Queues))
+	for name := range t.Queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (tm *topicManager) listUnsafe() []application.Topic {
+	var ans = make([]application.Topic, 0, len(tm.topics))
+	for _, t := range tm.topics {
+		cp := *t
+		cp.Queues = t.Queues.Dup()
+		ans = append(ans, cp)
+	}
+	return ans
+}
@@ -0,0 +1,134 @@
+package topicmanager_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/topicmanager"
+	"github.com/reddec/trusted-cgi/queue"
+	"github.com/reddec/trusted-cgi/queue/inmemory"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type delivery struct {
+	uid  string
+	body string
+}
+
+type platformFunc func(ctx context.Context, uid string, request types.Request, out io.Writer) error
+
+func (pf platformFunc) InvokeByUID(ctx context.Context, uid string, request types.Request, out io.Writer) error {
+	return pf(ctx, uid, request, out)
+}
+
+func TestTopicManager_Publish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan delivery, 10)
+	platform := platformFunc(func(ctx context.Context, uid string, request types.Request, out io.Writer) error {
+		defer request.Body.Close()
+		data, err := ioutil.ReadAll(request.Body)
+		received <- delivery{uid: uid, body: string(data)}
+		return err
+	})
+	qm, err := queuemanager.New(ctx, queuemanager.Mock(
+		application.Queue{Name: "first", Target: "lambda-1"},
+		application.Queue{Name: "second", Target: "lambda-2"},
+	), platform, func(name string) (queue.Queue, error) {
+		return inmemory.New(10), nil
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := topicmanager.Mock()
+	tm, err := topicmanager.New(store, qm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Add("events"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Add("events"); err == nil {
+		t.Error("duplicated topic should not be added")
+	}
+	if err := tm.Add("x"); err == nil {
+		t.Error("topic with invalid name should not be added")
+	}
+	if err := tm.Subscribe("events", "unknown"); err == nil {
+		t.Error("not existent queue should not be subscribed")
+	}
+	for _, name := range []string{"first", "second"} {
+		if err := tm.Subscribe("events", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each subscriber gets own copy
+	if err := tm.Publish("events", request("hello")); err != nil {
+		t.Fatal(err)
+	}
+	var got = make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-received:
+			got[d.uid] = d.body
+		case <-time.After(time.Second):
+			t.Fatal("request not delivered")
+		}
+	}
+	if got["lambda-1"] != "hello" || got["lambda-2"] != "hello" {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+	if err := tm.Publish("unknown", request("hello")); err == nil {
+		t.Error("publish to unknown topic should fail")
+	}
+
+	// unsubscribed and removed queues are skipped
+	if err := tm.Unsubscribe("events", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := qm.Remove("second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.Publish("events", request("world")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-received:
+		t.Errorf("unexpected delivery: %+v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// configuration is restored
+	restored, err := topicmanager.New(store, qm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, err := restored.Get("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topic.Queues) != 1 || !topic.Queues.Has("second") {
+		t.Errorf("unexpected subscribers: %v", topic.Queues)
+	}
+	if err := restored.Remove("events"); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.List()) != 0 {
+		t.Error("topic should be removed")
+	}
+}
+
+func request(payload string) *types.Request {
+	return &types.Request{
+		Method: "POST",
+		Path:   "/events",
+		Body:   ioutil.NopCloser(bytes.NewBufferString(payload)),
+	}
+}
@@ -3,6 +3,7 @@ package application
 import (
 	"encoding/json"
 	"os"
+	"time"
 
 	"github.com/reddec/trusted-cgi/types"
 )
@@ -15,9 +16,12 @@ type Definition struct {
 }
 
 type Config struct {
-	User        string            `json:"user"`                  // user that will be used for jobs
-	Environment map[string]string `json:"environment,omitempty"` // global environment
-	Links       map[string]string `json:"links,omitempty"`       // links (alias -> uid)
+	User           string             `json:"user"`                      // user that will be used for jobs
+	Environment    map[string]string  `json:"environment,omitempty"`     // global environment
+	Links          map[string]string  `json:"links,omitempty"`           // links (alias -> uid)
+	MaxConcurrency int                `json:"max_concurrency,omitempty"` // default maximum parallel invocations per lambda (zero is unlimited)
+	MaxWaiting     int                `json:"max_waiting,omitempty"`     // default maximum waiting requests per lambda (zero is unlimited, negative is no waiting)
+	WaitTimeout    types.JsonDuration `json:"wait_timeout,omitempty"`    // default maximum time to wait for free slot (zero is unlimited)
 }
 
 func (cfg Config) WithEnv(env map[string]string) Config {
@@ -51,22 +55,165 @@ func (cfg *Config) ReadFile(file string) error {
 }
 
 type Queue struct {
-	Name           string             `json:"name"`
-	Target         string             `json:"target"`
-	Retry          int                `json:"retry"`            // number of additional attempts
-	MaxElementSize int64              `json:"max_element_size"` // max request size
-	Interval       types.JsonDuration `json:"interval"`         // delay between attempts
+	Name            string              `json:"name"`
+	Target          string              `json:"target"`
+	Retry           int                 `json:"retry"`                       // number of additional attempts
+	MaxElementSize  int64               `json:"max_element_size"`            // max request size
+	Interval        types.JsonDuration  `json:"interval"`                    // delay before first retry
+	Multiplier      float64             `json:"multiplier,omitempty"`        // growth of delay for each next retry (exponential backoff), values less than 1 means constant delay
+	MaxInterval     types.JsonDuration  `json:"max_interval,omitempty"`      // maximum delay between attempts (0 means unlimited)
+	Jitter          float64             `json:"jitter,omitempty"`            // random deviation of delay as fraction (0..1) of delay
+	DeadLetter      bool                `json:"dead_letter,omitempty"`       // keep failed requests in dead-letter storage of the queue
+	DeadLetterQueue string              `json:"dead_letter_queue,omitempty"` // forward failed requests to another queue (storage is used if forward failed)
+	Workers         int                 `json:"workers,omitempty"`           // number of concurrent consumers (at least one), order of processing is not guaranteed for more than one
+	Result          string              `json:"result,omitempty"`            // handling of lambda output (see Result* constants), empty means discard
+	ReplyQueue      string              `json:"reply_queue,omitempty"`       // queue for lambda output in reply mode
+	MaxResultSize   int64               `json:"max_result_size,omitempty"`   // limit of captured output (1MiB if not set)
+	ResultTTL       types.JsonDuration  `json:"result_ttl,omitempty"`        // how long finished jobs are kept in store mode (24h if not set)
+	CallbackURLs    types.JsonStringSet `json:"callback_urls,omitempty"`     // allowed prefixes of callback URL in callback mode (callbacks are rejected if empty)
+}
+
+// Result handling modes of queue
+const (
+	ResultCallback = "callback" // post output to URL from X-Callback-Url header of request (limited by callback URLs of queue)
+	ResultReply    = "reply"    // put output to reply queue
+	ResultStore    = "store"    // keep output as job result
+)
+
+// Headers of queued requests with result handling
+const (
+	JobHeader      = "X-Job-Id"       // assigned by queue
+	CallbackHeader = "X-Callback-Url" // set by client for callback mode
+)
+
+type Topic struct {
+	Name   string              `json:"name"`
+	Queues types.JsonStringSet `json:"queues"` // subscribed queues, each of them gets own copy of published request
+}
+
+type QueueStats struct {
+	Name        string `json:"name"`
+	Depth       int64  `json:"depth"`        // number of stored requests (including in-flight)
+	InFlight    int64  `json:"in_flight"`    // number of requests processing by workers right now
+	Size        int64  `json:"size"`         // size of stored requests in bytes
+	DeadLetters int64  `json:"dead_letters"` // number of failed requests in dead-letter storage
 }
 
 type PolicyDefinition struct {
-	AllowedIP     types.JsonStringSet `json:"allowed_ip,omitempty"`     // limit incoming connections from list of IP
+	AllowedIP     types.JsonStringSet `json:"allowed_ip,omitempty"`     // limit incoming connections from list of IP or CIDR
+	DeniedIP      types.JsonStringSet `json:"denied_ip,omitempty"`      // reject incoming connections from list of IP or CIDR (precedes allowed)
 	AllowedOrigin types.JsonStringSet `json:"allowed_origin,omitempty"` // limit incoming connections by origin header
 	Public        bool                `json:"public"`                   // if public, tokens are ignores
-	Tokens        map[string]string   `json:"tokens,omitempty"`         // limit request by value in Authorization header (token => title)
+	Tokens        map[string]string   `json:"tokens,omitempty"`         // (legacy) plain tokens (token => title), converted to hashed policy tokens on save
+	JWT           *JWTPolicy          `json:"jwt,omitempty"`            // require bearer JWT in Authorization header (public and tokens are ignored)
+	HMAC          *HMACPolicy         `json:"hmac,omitempty"`           // require HMAC signature of body
+	RateLimit     *RateLimit          `json:"rate_limit,omitempty"`     // limit rate of requests (shared by all linked lambdas)
+	Rules         []PolicyRule        `json:"rules,omitempty"`          // ordered rules for access; first matched rule is applied
+}
+
+const (
+	RuleAllow = "allow" // matched request is allowed if passes rule checks
+	RuleDeny  = "deny"  // matched request is rejected
+)
+
+// Rule is matched if all defined matchers are matched.
+type PolicyRule struct {
+	Methods     []string            `json:"methods,omitempty"`      // HTTP methods (case insensitive)
+	Path        string              `json:"path,omitempty"`         // glob of sub-path after resource name (ex: /users/*); /** suffix matches any depth
+	Headers     map[string]string   `json:"headers,omitempty"`      // header name => regular expression of value (missing header is empty value)
+	ContentType string              `json:"content_type,omitempty"` // glob of media type without parameters (ex: application/json, text/*)
+	Action      string              `json:"action"`                 // allow or deny
+	Public      bool                `json:"public"`                 // allowed request doesn't need token
+	TokenIDs    types.JsonStringSet `json:"token_ids,omitempty"`    // IDs of policy tokens for allowed request; policy authorization is used if empty and not public
+	AllowedIP   types.JsonStringSet `json:"allowed_ip,omitempty"`   // additional limit by list of IP or CIDR
+	DeniedIP    types.JsonStringSet `json:"denied_ip,omitempty"`    // additional reject by list of IP or CIDR
+}
+
+const (
+	RateLimitByIP     = "ip"     // separate limit for each remote IP
+	RateLimitByToken  = "token"  // separate limit for each value of Authorization header
+	RateLimitByGlobal = "global" // one limit for all requests
+)
+
+type RateLimit struct {
+	Requests int                `json:"requests"`           // number of requests per interval
+	Interval types.JsonDuration `json:"interval,omitempty"` // interval of requests (1s if not set)
+	Burst    int                `json:"burst,omitempty"`    // maximum number of requests at once (same as requests if not set)
+	Key      string             `json:"key,omitempty"`      // ip (default), token or global
+}
+
+const (
+	HMACHex    = "hex"    // hex-encoded signature with optional prefix (ex: X-Hub-Signature-256: sha256=...)
+	HMACStripe = "stripe" // Stripe-like signature of timestamp and body (ex: Stripe-Signature: t=...,v1=...)
+)
+
+// Replacement of JWT and HMAC secrets in API responses. Definition with masked secret keeps saved secret on update.
+const MaskedSecret = "********"
+
+type JWTPolicy struct {
+	Secret   string            `json:"secret,omitempty"`   // shared secret for HS256
+	JWKS     string            `json:"jwks,omitempty"`     // path to JWKS file with public keys for RS256 and ES256
+	Issuer   string            `json:"issuer,omitempty"`   // required issuer (iss)
+	Audience string            `json:"audience,omitempty"` // required audience (aud)
+	Claims   map[string]string `json:"claims,omitempty"`   // required claims (name => value); empty value means any value
+}
+
+type HMACPolicy struct {
+	Secret    string             `json:"secret"`              // shared secret
+	Header    string             `json:"header,omitempty"`    // header with signature, default X-Hub-Signature-256 (or Stripe-Signature for stripe format)
+	Algorithm string             `json:"algorithm,omitempty"` // hash function: sha256 (default), sha1 or sha512
+	Format    string             `json:"format,omitempty"`    // signature format: hex (default) or stripe
+	Prefix    string             `json:"prefix,omitempty"`    // prefix of signature in hex format (ex: sha256=)
+	Tolerance types.JsonDuration `json:"tolerance,omitempty"` // maximum age of timestamp in stripe format, default 5 minutes
+}
+
+// MarshalJSON masks secret. Policy store keeps secret separately.
+func (jp JWTPolicy) MarshalJSON() ([]byte, error) {
+	type plain JWTPolicy
+	cp := plain(jp)
+	if cp.Secret != "" {
+		cp.Secret = MaskedSecret
+	}
+	return json.Marshal(cp)
+}
+
+// MarshalJSON masks secret. Policy store keeps secret separately.
+func (hp HMACPolicy) MarshalJSON() ([]byte, error) {
+	type plain HMACPolicy
+	cp := plain(hp)
+	if cp.Secret != "" {
+		cp.Secret = MaskedSecret
+	}
+	return json.Marshal(cp)
 }
 
 type Policy struct {
 	ID         string              `json:"id"`
 	Definition PolicyDefinition    `json:"definition"`
 	Lambdas    types.JsonStringSet `json:"lambdas"`
+	Tokens     []PolicyToken       `json:"tokens,omitempty"` // issued tokens for Authorization header
+}
+
+// Token of policy. Only salted hash of the secret is stored. Salt and hash are never exposed by API (see policy store).
+type PolicyToken struct {
+	ID       string     `json:"id"`
+	Title    string     `json:"title,omitempty"`
+	Salt     string     `json:"-"`
+	Hash     string     `json:"-"` // hex-encoded SHA-256 of salt and secret
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires,omitempty"`   // token is not valid after the time
+	LastUsed *time.Time `json:"last_used,omitempty"` // last successful authorization (precision is one minute)
+	Enabled  bool       `json:"enabled"`
+}
+
+// Valid (enabled and not expired) at the time.
+func (pt *PolicyToken) Valid(now time.Time) bool {
+	return pt.Enabled && (pt.Expires == nil || now.Before(*pt.Expires))
+}
+
+// Issued or rotated token. Secret is returned only once and can not be restored.
+type IssuedToken struct {
+	ID      string     `json:"id"`
+	Secret  string     `json:"secret"`
+	Expires *time.Time `json:"expires,omitempty"`
 }
@@ -164,6 +164,43 @@ export class LambdaAPI {
         }));
     }
 
+    /**
+    Search stats records of the app from newest to oldest (UID in query is ignored)
+    **/
+    async queryStats(token, uid, query){
+        return (await this.__call('QueryStats', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.QueryStats",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query]
+        }));
+    }
+
+    /**
+    Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+Zero window means maximum available
+    **/
+    async summary(token, uid, window){
+        return (await this.__call('Summary', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Summary",
+            "id" : this.__next_id(),
+            "params" : [token, uid, window]
+        }));
+    }
+
+    /**
+    Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+    **/
+    async logs(token, uid, after, limit){
+        return (await this.__call('Logs', {
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Logs",
+            "id" : this.__next_id(),
+            "params" : [token, uid, after, limit]
+        }));
+    }
+
     /**
     Actions available for the app
     **/
@@ -92,6 +92,54 @@ export class PoliciesAPI {
         }));
     }
 
+    /**
+    Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+    **/
+    async issueToken(token, policy, title, ttl){
+        return (await this.__call('IssueToken', {
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.IssueToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, title, ttl]
+        }));
+    }
+
+    /**
+    Rotate secret of the policy token. Secret is returned only once
+    **/
+    async rotateToken(token, policy, tokenID){
+        return (await this.__call('RotateToken', {
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.RotateToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID]
+        }));
+    }
+
+    /**
+    Revoke (remove) policy token
+    **/
+    async revokeToken(token, policy, tokenID){
+        return (await this.__call('RevokeToken', {
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.RevokeToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID]
+        }));
+    }
+
+    /**
+    Enable or disable policy token
+    **/
+    async enableToken(token, policy, tokenID, enabled){
+        return (await this.__call('EnableToken', {
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.EnableToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID, enabled]
+        }));
+    }
+
 
 
     __next_id() {
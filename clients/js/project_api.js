@@ -104,6 +104,30 @@ export class ProjectAPI {
         }));
     }
 
+    /**
+    Search global stats records from newest to oldest
+    **/
+    async queryStats(token, query){
+        return (await this.__call('QueryStats', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.QueryStats",
+            "id" : this.__next_id(),
+            "params" : [token, query]
+        }));
+    }
+
+    /**
+    Aggregated stats of the whole project and each app over window till now. Zero window means maximum available
+    **/
+    async summary(token, window){
+        return (await this.__call('Summary', {
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Summary",
+            "id" : this.__next_id(),
+            "params" : [token, window]
+        }));
+    }
+
     /**
     Create new app (lambda)
     **/
@@ -80,6 +80,90 @@ export class QueuesAPI {
         }));
     }
 
+    /**
+    Failed requests (dead letters) of the queue
+    **/
+    async deadLetters(token, name){
+        return (await this.__call('DeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+    **/
+    async replayDeadLetters(token, name, ids){
+        return (await this.__call('ReplayDeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.ReplayDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name, ids]
+        }));
+    }
+
+    /**
+    Remove failed requests (all if IDs not set). Returns number of removed requests
+    **/
+    async purgeDeadLetters(token, name, ids){
+        return (await this.__call('PurgeDeadLetters', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name, ids]
+        }));
+    }
+
+    /**
+    Depth, size in bytes and number of in-flight requests of the queue
+    **/
+    async stats(token, name){
+        return (await this.__call('Stats', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Stats",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+    **/
+    async peek(token, name, limit){
+        return (await this.__call('Peek', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Peek",
+            "id" : this.__next_id(),
+            "params" : [token, name, limit]
+        }));
+    }
+
+    /**
+    Delete request from the queue by ID. In-flight requests can not be deleted
+    **/
+    async deleteMessage(token, name, id){
+        return (await this.__call('DeleteMessage', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeleteMessage",
+            "id" : this.__next_id(),
+            "params" : [token, name, id]
+        }));
+    }
+
+    /**
+    Delete all not in-flight requests from the queue. Returns number of deleted requests
+    **/
+    async purge(token, name){
+        return (await this.__call('Purge', {
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Purge",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
 
 
     __next_id() {
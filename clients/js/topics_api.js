@@ -0,0 +1,114 @@
+export class TopicsAPIError extends Error {
+    constructor(message, code, details) {
+        super(code + ': ' + message);
+        this.code = code;
+        this.details = details;
+    }
+}
+
+export class TopicsAPI {
+    /**
+    API for managing topics
+    **/
+
+    // Create new API handler to TopicsAPI.
+    // preflightHandler (if defined) can return promise
+    constructor(base_url = 'https://127.0.0.1:3434/u/', preflightHandler = null) {
+        this.__url = base_url;
+        this.__id = 1;
+        this.__preflightHandler = preflightHandler;
+    }
+
+
+    /**
+    Create topic without subscribers
+    **/
+    async create(token, name){
+        return (await this.__call('Create', {
+            "jsonrpc" : "2.0",
+            "method" : "TopicsAPI.Create",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    Remove topic. Subscribed queues are not affected
+    **/
+    async remove(token, name){
+        return (await this.__call('Remove', {
+            "jsonrpc" : "2.0",
+            "method" : "TopicsAPI.Remove",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        }));
+    }
+
+    /**
+    List of all topics
+    **/
+    async list(token){
+        return (await this.__call('List', {
+            "jsonrpc" : "2.0",
+            "method" : "TopicsAPI.List",
+            "id" : this.__next_id(),
+            "params" : [token]
+        }));
+    }
+
+    /**
+    Subscribe queue to topic: each published request will be copied to the queue
+    **/
+    async subscribe(token, name, queue){
+        return (await this.__call('Subscribe', {
+            "jsonrpc" : "2.0",
+            "method" : "TopicsAPI.Subscribe",
+            "id" : this.__next_id(),
+            "params" : [token, name, queue]
+        }));
+    }
+
+    /**
+    Unsubscribe queue from topic
+    **/
+    async unsubscribe(token, name, queue){
+        return (await this.__call('Unsubscribe', {
+            "jsonrpc" : "2.0",
+            "method" : "TopicsAPI.Unsubscribe",
+            "id" : this.__next_id(),
+            "params" : [token, name, queue]
+        }));
+    }
+
+
+
+    __next_id() {
+        this.__id += 1;
+        return this.__id
+    }
+
+    async __call(method, req) {
+        const fetchParams = {
+            method: "POST",
+            headers: {
+                'Content-Type' : 'application/json',
+            },
+            body: JSON.stringify(req)
+        };
+        if (this.__preflightHandler) {
+            await Promise.resolve(this.__preflightHandler(method, fetchParams));
+        }
+        const res = await fetch(this.__url, fetchParams);
+        if (!res.ok) {
+            throw new Error(res.status + ' ' + res.statusText);
+        }
+
+        const data = await res.json();
+
+        if ('error' in data) {
+            throw new TopicsAPIError(data.error.message, data.error.code, data.error.data);
+        }
+
+        return data.result;
+    }
+}
\ No newline at end of file
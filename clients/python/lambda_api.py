@@ -64,6 +64,16 @@ class Manifest:
     maximum_payload: 'Optional[int]'
     cron: 'Optional[List[Schedule]]'
     static: 'Optional[str]'
+    parse_headers: 'Optional[bool]'
+    cgi: 'Optional[bool]'
+    sandbox: 'Optional[Sandbox]'
+    max_concurrency: 'Optional[int]'
+    max_waiting: 'Optional[int]'
+    wait_timeout: 'Optional[Any]'
+    workers: 'Optional[Workers]'
+    proxy: 'Optional[Proxy]'
+    stream: 'Optional[bool]'
+    web_socket: 'Optional[WebSocket]'
 
     def to_json(self) -> dict:
         return {
@@ -79,8 +89,18 @@ class Manifest:
             "path_env": self.path_env,
             "time_limit": self.time_limit,
             "maximum_payload": self.maximum_payload,
-            "cron": [x.to_json() for x in self.cron],
+            "cron": [x.to_json() for x in self.cron] if self.cron is not None else None,
             "static": self.static,
+            "parse_headers": self.parse_headers,
+            "cgi": self.cgi,
+            "sandbox": self.sandbox.to_json() if self.sandbox is not None else None,
+            "max_concurrency": self.max_concurrency,
+            "max_waiting": self.max_waiting,
+            "wait_timeout": self.wait_timeout,
+            "workers": self.workers.to_json() if self.workers is not None else None,
+            "proxy": self.proxy.to_json() if self.proxy is not None else None,
+            "stream": self.stream,
+            "websocket": self.web_socket.to_json() if self.web_socket is not None else None,
         }
 
     @staticmethod
@@ -100,6 +120,16 @@ class Manifest:
                 maximum_payload=payload['maximum_payload'],
                 cron=[Schedule.from_json(x) for x in (payload['cron'] or [])],
                 static=payload['static'],
+                parse_headers=payload['parse_headers'],
+                cgi=payload['cgi'],
+                sandbox=Sandbox.from_json(payload['sandbox']) if payload.get('sandbox') is not None else None,
+                max_concurrency=payload['max_concurrency'],
+                max_waiting=payload['max_waiting'],
+                wait_timeout=payload['wait_timeout'],
+                workers=Workers.from_json(payload['workers']) if payload.get('workers') is not None else None,
+                proxy=Proxy.from_json(payload['proxy']) if payload.get('proxy') is not None else None,
+                stream=payload['stream'],
+                web_socket=WebSocket.from_json(payload['websocket']) if payload.get('websocket') is not None else None,
         )
 
 
@@ -125,6 +155,97 @@ class Schedule:
         )
 
 
+@dataclass
+class Sandbox:
+    network: 'Optional[bool]'
+    memory: 'Optional[int]'
+    cpu: 'Optional[float]'
+    pids: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "network": self.network,
+            "memory": self.memory,
+            "cpu": self.cpu,
+            "pids": self.pids,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Sandbox':
+        return Sandbox(
+                network=payload['network'],
+                memory=payload['memory'],
+                cpu=payload['cpu'],
+                pids=payload['pids'],
+        )
+
+
+@dataclass
+class Workers:
+    size: 'Optional[int]'
+    max_requests: 'Optional[int]'
+    idle_timeout: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "size": self.size,
+            "max_requests": self.max_requests,
+            "idle_timeout": self.idle_timeout,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Workers':
+        return Workers(
+                size=payload['size'],
+                max_requests=payload['max_requests'],
+                idle_timeout=payload['idle_timeout'],
+        )
+
+
+@dataclass
+class Proxy:
+    socket: 'Optional[str]'
+    address: 'Optional[str]'
+    start_timeout: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "socket": self.socket,
+            "address": self.address,
+            "start_timeout": self.start_timeout,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Proxy':
+        return Proxy(
+                socket=payload['socket'],
+                address=payload['address'],
+                start_timeout=payload['start_timeout'],
+        )
+
+
+@dataclass
+class WebSocket:
+    framing: 'Optional[str]'
+    binary: 'Optional[bool]'
+    max_message: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "framing": self.framing,
+            "binary": self.binary,
+            "max_message": self.max_message,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'WebSocket':
+        return WebSocket(
+                framing=payload['framing'],
+                binary=payload['binary'],
+                max_message=payload['max_message'],
+        )
+
+
 @dataclass
 class Record:
     uid: 'str'
@@ -132,6 +253,12 @@ class Record:
     request: 'Request'
     begin: 'Any'
     end: 'Any'
+    alias: 'Optional[str]'
+    status: 'Optional[int]'
+    exit_code: 'Optional[int]'
+    bytes_in: 'Optional[int]'
+    bytes_out: 'Optional[int]'
+    kill_reason: 'Optional[str]'
 
     def to_json(self) -> dict:
         return {
@@ -140,6 +267,12 @@ class Record:
             "request": self.request.to_json(),
             "begin": self.begin,
             "end": self.end,
+            "alias": self.alias,
+            "status": self.status,
+            "exit_code": self.exit_code,
+            "bytes_in": self.bytes_in,
+            "bytes_out": self.bytes_out,
+            "kill_reason": self.kill_reason,
         }
 
     @staticmethod
@@ -150,6 +283,12 @@ class Record:
                 request=Request.from_json(payload['request']),
                 begin=payload['begin'],
                 end=payload['end'],
+                alias=payload['alias'],
+                status=payload['status'],
+                exit_code=payload['exit_code'],
+                bytes_in=payload['bytes_in'],
+                bytes_out=payload['bytes_out'],
+                kill_reason=payload['kill_reason'],
         )
 
 
@@ -161,6 +300,11 @@ class Request:
     remote_address: 'str'
     form: 'Any'
     headers: 'Any'
+    form_values: 'Optional[Any]'
+    header_values: 'Optional[Any]'
+    traceparent: 'Optional[str]'
+    deliver_at: 'Optional[Any]'
+    claims: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
@@ -170,6 +314,11 @@ class Request:
             "remote_address": self.remote_address,
             "form": self.form,
             "headers": self.headers,
+            "form_values": self.form_values,
+            "header_values": self.header_values,
+            "traceparent": self.traceparent,
+            "deliver_at": self.deliver_at,
+            "claims": self.claims,
         }
 
     @staticmethod
@@ -181,6 +330,144 @@ class Request:
                 remote_address=payload['remote_address'],
                 form=payload['form'],
                 headers=payload['headers'],
+                form_values=payload['form_values'],
+                header_values=payload['header_values'],
+                traceparent=payload['traceparent'],
+                deliver_at=payload['deliver_at'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class Query:
+    uid: 'Optional[str]'
+    _from: 'Optional[Any]'
+    to: 'Optional[Any]'
+    errors_only: 'Optional[bool]'
+    method: 'Optional[str]'
+    limit: 'Optional[int]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "from": self._from,
+            "to": self.to,
+            "errors_only": self.errors_only,
+            "method": self.method,
+            "limit": self.limit,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Query':
+        return Query(
+                uid=payload['uid'],
+                _from=payload['from'],
+                to=payload['to'],
+                errors_only=payload['errors_only'],
+                method=payload['method'],
+                limit=payload['limit'],
+        )
+
+
+@dataclass
+class Summary:
+    uid: 'Optional[str]'
+    _from: 'Any'
+    to: 'Any'
+    requests: 'int'
+    errors: 'int'
+    error_ratio: 'float'
+    rate_per_minute: 'float'
+    p50: 'float'
+    p95: 'float'
+    p99: 'float'
+    top_callers: 'List[Caller]'
+
+    def to_json(self) -> dict:
+        return {
+            "uid": self.uid,
+            "from": self._from,
+            "to": self.to,
+            "requests": self.requests,
+            "errors": self.errors,
+            "error_ratio": self.error_ratio,
+            "rate_per_minute": self.rate_per_minute,
+            "p50_ms": self.p50,
+            "p95_ms": self.p95,
+            "p99_ms": self.p99,
+            "top_callers": [x.to_json() for x in self.top_callers],
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Summary':
+        return Summary(
+                uid=payload['uid'],
+                _from=payload['from'],
+                to=payload['to'],
+                requests=payload['requests'],
+                errors=payload['errors'],
+                error_ratio=payload['error_ratio'],
+                rate_per_minute=payload['rate_per_minute'],
+                p50=payload['p50_ms'],
+                p95=payload['p95_ms'],
+                p99=payload['p99_ms'],
+                top_callers=[Caller.from_json(x) for x in (payload['top_callers'] or [])],
+        )
+
+
+@dataclass
+class Caller:
+    address: 'str'
+    requests: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "address": self.address,
+            "requests": self.requests,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Caller':
+        return Caller(
+                address=payload['address'],
+                requests=payload['requests'],
+        )
+
+
+@dataclass
+class LogEntry:
+    id: 'int'
+    kind: 'str'
+    name: 'Optional[str]'
+    begin: 'Any'
+    end: 'Any'
+    output: 'str'
+    truncated: 'Optional[bool]'
+    err: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "kind": self.kind,
+            "name": self.name,
+            "begin": self.begin,
+            "end": self.end,
+            "output": self.output,
+            "truncated": self.truncated,
+            "error": self.err,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'LogEntry':
+        return LogEntry(
+                id=payload['id'],
+                kind=payload['kind'],
+                name=payload['name'],
+                begin=payload['begin'],
+                end=payload['end'],
+                output=payload['output'],
+                truncated=payload['truncated'],
+                err=payload['error'],
         )
 
 
@@ -407,6 +694,55 @@ class LambdaAPIClient:
             raise LambdaAPIError.from_json('stats', payload['error'])
         return [Record.from_json(x) for x in (payload['result'] or [])]
 
+    async def query_stats(self, token: Any, uid: str, query: Query) -> List[Record]:
+        """
+        Search stats records of the app from newest to oldest (UID in query is ignored)
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.QueryStats",
+            "id": self.__next_id(),
+            "params": [token, uid, query.to_json(), ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('query_stats', payload['error'])
+        return [Record.from_json(x) for x in (payload['result'] or [])]
+
+    async def summary(self, token: Any, uid: str, window: Any) -> Summary:
+        """
+        Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+Zero window means maximum available
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Summary",
+            "id": self.__next_id(),
+            "params": [token, uid, window, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('summary', payload['error'])
+        return Summary.from_json(payload['result'])
+
+    async def logs(self, token: Any, uid: str, after: int, limit: int) -> List[LogEntry]:
+        """
+        Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "LambdaAPI.Logs",
+            "id": self.__next_id(),
+            "params": [token, uid, after, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise LambdaAPIError.from_json('logs', payload['error'])
+        return [LogEntry.from_json(x) for x in (payload['result'] or [])]
+
     async def actions(self, token: Any, uid: str) -> List[str]:
         """
         Actions available for the app
@@ -587,6 +923,31 @@ class LambdaAPIBatch:
         method = "LambdaAPI.Stats"
         self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
 
+    def query_stats(self, token: Any, uid: str, query: Query):
+        """
+        Search stats records of the app from newest to oldest (UID in query is ignored)
+        """
+        params = [token, uid, query.to_json(), ]
+        method = "LambdaAPI.QueryStats"
+        self.__add_request(method, params, lambda payload: [Record.from_json(x) for x in (payload or [])])
+
+    def summary(self, token: Any, uid: str, window: Any):
+        """
+        Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+Zero window means maximum available
+        """
+        params = [token, uid, window, ]
+        method = "LambdaAPI.Summary"
+        self.__add_request(method, params, lambda payload: Summary.from_json(payload))
+
+    def logs(self, token: Any, uid: str, after: int, limit: int):
+        """
+        Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+        """
+        params = [token, uid, after, limit, ]
+        method = "LambdaAPI.Logs"
+        self.__add_request(method, params, lambda payload: [LogEntry.from_json(x) for x in (payload or [])])
+
     def actions(self, token: Any, uid: str):
         """
         Actions available for the app
@@ -11,12 +11,14 @@ class Policy:
     id: 'str'
     definition: 'PolicyDefinition'
     lambdas: 'Any'
+    tokens: 'Optional[List[PolicyToken]]'
 
     def to_json(self) -> dict:
         return {
             "id": self.id,
             "definition": self.definition.to_json(),
             "lambdas": self.lambdas,
+            "tokens": [x.to_json() for x in self.tokens] if self.tokens is not None else None,
         }
 
     @staticmethod
@@ -25,31 +27,224 @@ class Policy:
                 id=payload['id'],
                 definition=PolicyDefinition.from_json(payload['definition']),
                 lambdas=payload['lambdas'],
+                tokens=[PolicyToken.from_json(x) for x in (payload['tokens'] or [])],
         )
 
 
 @dataclass
 class PolicyDefinition:
     allowed_ip: 'Optional[Any]'
+    denied_ip: 'Optional[Any]'
     allowed_origin: 'Optional[Any]'
     public: 'bool'
     tokens: 'Optional[Any]'
+    jwt: 'Optional[JWTPolicy]'
+    hmac: 'Optional[HMACPolicy]'
+    rate_limit: 'Optional[RateLimit]'
+    rules: 'Optional[List[PolicyRule]]'
 
     def to_json(self) -> dict:
         return {
             "allowed_ip": self.allowed_ip,
+            "denied_ip": self.denied_ip,
             "allowed_origin": self.allowed_origin,
             "public": self.public,
             "tokens": self.tokens,
+            "jwt": self.jwt.to_json() if self.jwt is not None else None,
+            "hmac": self.hmac.to_json() if self.hmac is not None else None,
+            "rate_limit": self.rate_limit.to_json() if self.rate_limit is not None else None,
+            "rules": [x.to_json() for x in self.rules] if self.rules is not None else None,
         }
 
     @staticmethod
     def from_json(payload: dict) -> 'PolicyDefinition':
         return PolicyDefinition(
                 allowed_ip=payload['allowed_ip'],
+                denied_ip=payload['denied_ip'],
                 allowed_origin=payload['allowed_origin'],
                 public=payload['public'],
                 tokens=payload['tokens'],
+                jwt=JWTPolicy.from_json(payload['jwt']) if payload.get('jwt') is not None else None,
+                hmac=HMACPolicy.from_json(payload['hmac']) if payload.get('hmac') is not None else None,
+                rate_limit=RateLimit.from_json(payload['rate_limit']) if payload.get('rate_limit') is not None else None,
+                rules=[PolicyRule.from_json(x) for x in (payload['rules'] or [])],
+        )
+
+
+@dataclass
+class JWTPolicy:
+    secret: 'Optional[str]'
+    jwks: 'Optional[str]'
+    issuer: 'Optional[str]'
+    audience: 'Optional[str]'
+    claims: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "secret": self.secret,
+            "jwks": self.jwks,
+            "issuer": self.issuer,
+            "audience": self.audience,
+            "claims": self.claims,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'JWTPolicy':
+        return JWTPolicy(
+                secret=payload['secret'],
+                jwks=payload['jwks'],
+                issuer=payload['issuer'],
+                audience=payload['audience'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class HMACPolicy:
+    secret: 'str'
+    header: 'Optional[str]'
+    algorithm: 'Optional[str]'
+    format: 'Optional[str]'
+    prefix: 'Optional[str]'
+    tolerance: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "secret": self.secret,
+            "header": self.header,
+            "algorithm": self.algorithm,
+            "format": self.format,
+            "prefix": self.prefix,
+            "tolerance": self.tolerance,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'HMACPolicy':
+        return HMACPolicy(
+                secret=payload['secret'],
+                header=payload['header'],
+                algorithm=payload['algorithm'],
+                format=payload['format'],
+                prefix=payload['prefix'],
+                tolerance=payload['tolerance'],
+        )
+
+
+@dataclass
+class RateLimit:
+    requests: 'int'
+    interval: 'Optional[Any]'
+    burst: 'Optional[int]'
+    key: 'Optional[str]'
+
+    def to_json(self) -> dict:
+        return {
+            "requests": self.requests,
+            "interval": self.interval,
+            "burst": self.burst,
+            "key": self.key,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'RateLimit':
+        return RateLimit(
+                requests=payload['requests'],
+                interval=payload['interval'],
+                burst=payload['burst'],
+                key=payload['key'],
+        )
+
+
+@dataclass
+class PolicyRule:
+    methods: 'Optional[List[str]]'
+    path: 'Optional[str]'
+    headers: 'Optional[Any]'
+    content_type: 'Optional[str]'
+    action: 'str'
+    public: 'bool'
+    token_i_dss: 'Optional[Any]'
+    allowed_ip: 'Optional[Any]'
+    denied_ip: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "methods": self.methods,
+            "path": self.path,
+            "headers": self.headers,
+            "content_type": self.content_type,
+            "action": self.action,
+            "public": self.public,
+            "token_ids": self.token_i_dss,
+            "allowed_ip": self.allowed_ip,
+            "denied_ip": self.denied_ip,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'PolicyRule':
+        return PolicyRule(
+                methods=payload['methods'] or [],
+                path=payload['path'],
+                headers=payload['headers'],
+                content_type=payload['content_type'],
+                action=payload['action'],
+                public=payload['public'],
+                token_i_dss=payload['token_ids'],
+                allowed_ip=payload['allowed_ip'],
+                denied_ip=payload['denied_ip'],
+        )
+
+
+@dataclass
+class PolicyToken:
+    id: 'str'
+    title: 'Optional[str]'
+    created: 'Any'
+    expires: 'Optional[Any]'
+    last_used: 'Optional[Any]'
+    enabled: 'bool'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "title": self.title,
+            "created": self.created,
+            "expires": self.expires,
+            "last_used": self.last_used,
+            "enabled": self.enabled,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'PolicyToken':
+        return PolicyToken(
+                id=payload['id'],
+                title=payload['title'],
+                created=payload['created'],
+                expires=payload['expires'],
+                last_used=payload['last_used'],
+                enabled=payload['enabled'],
+        )
+
+
+@dataclass
+class IssuedToken:
+    id: 'str'
+    secret: 'str'
+    expires: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "secret": self.secret,
+            "expires": self.expires,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'IssuedToken':
+        return IssuedToken(
+                id=payload['id'],
+                secret=payload['secret'],
+                expires=payload['expires'],
         )
 
 
@@ -180,6 +375,70 @@ class PoliciesAPIClient:
             raise PoliciesAPIError.from_json('clear', payload['error'])
         return payload['result']
 
+    async def issue_token(self, token: Any, policy: str, title: str, ttl: Any) -> IssuedToken:
+        """
+        Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "PoliciesAPI.IssueToken",
+            "id": self.__next_id(),
+            "params": [token, policy, title, ttl, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise PoliciesAPIError.from_json('issue_token', payload['error'])
+        return IssuedToken.from_json(payload['result'])
+
+    async def rotate_token(self, token: Any, policy: str, token_id: str) -> IssuedToken:
+        """
+        Rotate secret of the policy token. Secret is returned only once
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "PoliciesAPI.RotateToken",
+            "id": self.__next_id(),
+            "params": [token, policy, token_id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise PoliciesAPIError.from_json('rotate_token', payload['error'])
+        return IssuedToken.from_json(payload['result'])
+
+    async def revoke_token(self, token: Any, policy: str, token_id: str) -> bool:
+        """
+        Revoke (remove) policy token
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "PoliciesAPI.RevokeToken",
+            "id": self.__next_id(),
+            "params": [token, policy, token_id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise PoliciesAPIError.from_json('revoke_token', payload['error'])
+        return payload['result']
+
+    async def enable_token(self, token: Any, policy: str, token_id: str, enabled: bool) -> bool:
+        """
+        Enable or disable policy token
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "PoliciesAPI.EnableToken",
+            "id": self.__next_id(),
+            "params": [token, policy, token_id, enabled, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise PoliciesAPIError.from_json('enable_token', payload['error'])
+        return payload['result']
+
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
 
@@ -248,6 +507,38 @@ class PoliciesAPIBatch:
         method = "PoliciesAPI.Clear"
         self.__add_request(method, params, lambda payload: payload)
 
+    def issue_token(self, token: Any, policy: str, title: str, ttl: Any):
+        """
+        Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+        """
+        params = [token, policy, title, ttl, ]
+        method = "PoliciesAPI.IssueToken"
+        self.__add_request(method, params, lambda payload: IssuedToken.from_json(payload))
+
+    def rotate_token(self, token: Any, policy: str, token_id: str):
+        """
+        Rotate secret of the policy token. Secret is returned only once
+        """
+        params = [token, policy, token_id, ]
+        method = "PoliciesAPI.RotateToken"
+        self.__add_request(method, params, lambda payload: IssuedToken.from_json(payload))
+
+    def revoke_token(self, token: Any, policy: str, token_id: str):
+        """
+        Revoke (remove) policy token
+        """
+        params = [token, policy, token_id, ]
+        method = "PoliciesAPI.RevokeToken"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def enable_token(self, token: Any, policy: str, token_id: str, enabled: bool):
+        """
+        Enable or disable policy token
+        """
+        params = [token, policy, token_id, enabled, ]
+        method = "PoliciesAPI.EnableToken"
+        self.__add_request(method, params, lambda payload: payload)
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
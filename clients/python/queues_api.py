@@ -13,6 +13,17 @@ class Queue:
     retry: 'int'
     max_element_size: 'int'
     interval: 'Any'
+    multiplier: 'Optional[float]'
+    max_interval: 'Optional[Any]'
+    jitter: 'Optional[float]'
+    dead_letter: 'Optional[bool]'
+    dead_letter_queue: 'Optional[str]'
+    workers: 'Optional[int]'
+    result: 'Optional[str]'
+    reply_queue: 'Optional[str]'
+    max_result_size: 'Optional[int]'
+    result_ttl: 'Optional[Any]'
+    callback_ur_lss: 'Optional[Any]'
 
     def to_json(self) -> dict:
         return {
@@ -21,6 +32,17 @@ class Queue:
             "retry": self.retry,
             "max_element_size": self.max_element_size,
             "interval": self.interval,
+            "multiplier": self.multiplier,
+            "max_interval": self.max_interval,
+            "jitter": self.jitter,
+            "dead_letter": self.dead_letter,
+            "dead_letter_queue": self.dead_letter_queue,
+            "workers": self.workers,
+            "result": self.result,
+            "reply_queue": self.reply_queue,
+            "max_result_size": self.max_result_size,
+            "result_ttl": self.result_ttl,
+            "callback_urls": self.callback_ur_lss,
         }
 
     @staticmethod
@@ -31,6 +53,150 @@ class Queue:
                 retry=payload['retry'],
                 max_element_size=payload['max_element_size'],
                 interval=payload['interval'],
+                multiplier=payload['multiplier'],
+                max_interval=payload['max_interval'],
+                jitter=payload['jitter'],
+                dead_letter=payload['dead_letter'],
+                dead_letter_queue=payload['dead_letter_queue'],
+                workers=payload['workers'],
+                result=payload['result'],
+                reply_queue=payload['reply_queue'],
+                max_result_size=payload['max_result_size'],
+                result_ttl=payload['result_ttl'],
+                callback_ur_lss=payload['callback_urls'],
+        )
+
+
+@dataclass
+class DeadLetter:
+    id: 'str'
+    queue: 'str'
+    reason: 'str'
+    attempts: 'int'
+    failed: 'Any'
+    size: 'int'
+    request: 'Request'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "queue": self.queue,
+            "reason": self.reason,
+            "attempts": self.attempts,
+            "failed": self.failed,
+            "size": self.size,
+            "request": self.request.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'DeadLetter':
+        return DeadLetter(
+                id=payload['id'],
+                queue=payload['queue'],
+                reason=payload['reason'],
+                attempts=payload['attempts'],
+                failed=payload['failed'],
+                size=payload['size'],
+                request=Request.from_json(payload['request']),
+        )
+
+
+@dataclass
+class Request:
+    method: 'str'
+    url: 'str'
+    path: 'str'
+    remote_address: 'str'
+    form: 'Any'
+    headers: 'Any'
+    form_values: 'Optional[Any]'
+    header_values: 'Optional[Any]'
+    traceparent: 'Optional[str]'
+    deliver_at: 'Optional[Any]'
+    claims: 'Optional[Any]'
+
+    def to_json(self) -> dict:
+        return {
+            "method": self.method,
+            "url": self.url,
+            "path": self.path,
+            "remote_address": self.remote_address,
+            "form": self.form,
+            "headers": self.headers,
+            "form_values": self.form_values,
+            "header_values": self.header_values,
+            "traceparent": self.traceparent,
+            "deliver_at": self.deliver_at,
+            "claims": self.claims,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Request':
+        return Request(
+                method=payload['method'],
+                url=payload['url'],
+                path=payload['path'],
+                remote_address=payload['remote_address'],
+                form=payload['form'],
+                headers=payload['headers'],
+                form_values=payload['form_values'],
+                header_values=payload['header_values'],
+                traceparent=payload['traceparent'],
+                deliver_at=payload['deliver_at'],
+                claims=payload['claims'],
+        )
+
+
+@dataclass
+class QueueStats:
+    name: 'str'
+    depth: 'int'
+    in_flight: 'int'
+    size: 'int'
+    dead_letters: 'int'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "depth": self.depth,
+            "in_flight": self.in_flight,
+            "size": self.size,
+            "dead_letters": self.dead_letters,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'QueueStats':
+        return QueueStats(
+                name=payload['name'],
+                depth=payload['depth'],
+                in_flight=payload['in_flight'],
+                size=payload['size'],
+                dead_letters=payload['dead_letters'],
+        )
+
+
+@dataclass
+class QueueItem:
+    id: 'int'
+    leased: 'bool'
+    size: 'int'
+    request: 'Request'
+
+    def to_json(self) -> dict:
+        return {
+            "id": self.id,
+            "leased": self.leased,
+            "size": self.size,
+            "request": self.request.to_json(),
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'QueueItem':
+        return QueueItem(
+                id=payload['id'],
+                leased=payload['leased'],
+                size=payload['size'],
+                request=Request.from_json(payload['request']),
         )
 
 
@@ -145,6 +311,118 @@ class QueuesAPIClient:
             raise QueuesAPIError.from_json('assign', payload['error'])
         return payload['result']
 
+    async def dead_letters(self, token: Any, name: str) -> List[DeadLetter]:
+        """
+        Failed requests (dead letters) of the queue
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.DeadLetters",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('dead_letters', payload['error'])
+        return [DeadLetter.from_json(x) for x in (payload['result'] or [])]
+
+    async def replay_dead_letters(self, token: Any, name: str, ids: List[str]) -> int:
+        """
+        Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.ReplayDeadLetters",
+            "id": self.__next_id(),
+            "params": [token, name, ids, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('replay_dead_letters', payload['error'])
+        return payload['result']
+
+    async def purge_dead_letters(self, token: Any, name: str, ids: List[str]) -> int:
+        """
+        Remove failed requests (all if IDs not set). Returns number of removed requests
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.PurgeDeadLetters",
+            "id": self.__next_id(),
+            "params": [token, name, ids, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('purge_dead_letters', payload['error'])
+        return payload['result']
+
+    async def stats(self, token: Any, name: str) -> QueueStats:
+        """
+        Depth, size in bytes and number of in-flight requests of the queue
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.Stats",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('stats', payload['error'])
+        return QueueStats.from_json(payload['result'])
+
+    async def peek(self, token: Any, name: str, limit: int) -> List[QueueItem]:
+        """
+        The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.Peek",
+            "id": self.__next_id(),
+            "params": [token, name, limit, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('peek', payload['error'])
+        return [QueueItem.from_json(x) for x in (payload['result'] or [])]
+
+    async def delete_message(self, token: Any, name: str, id: int) -> bool:
+        """
+        Delete request from the queue by ID. In-flight requests can not be deleted
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.DeleteMessage",
+            "id": self.__next_id(),
+            "params": [token, name, id, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('delete_message', payload['error'])
+        return payload['result']
+
+    async def purge(self, token: Any, name: str) -> int:
+        """
+        Delete all not in-flight requests from the queue. Returns number of deleted requests
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "QueuesAPI.Purge",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise QueuesAPIError.from_json('purge', payload['error'])
+        return payload['result']
+
     async def _invoke(self, request):
         return await self.__request('POST', self.__url, json=request)
 
@@ -205,6 +483,62 @@ class QueuesAPIBatch:
         method = "QueuesAPI.Assign"
         self.__add_request(method, params, lambda payload: payload)
 
+    def dead_letters(self, token: Any, name: str):
+        """
+        Failed requests (dead letters) of the queue
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.DeadLetters"
+        self.__add_request(method, params, lambda payload: [DeadLetter.from_json(x) for x in (payload or [])])
+
+    def replay_dead_letters(self, token: Any, name: str, ids: List[str]):
+        """
+        Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+        """
+        params = [token, name, ids, ]
+        method = "QueuesAPI.ReplayDeadLetters"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def purge_dead_letters(self, token: Any, name: str, ids: List[str]):
+        """
+        Remove failed requests (all if IDs not set). Returns number of removed requests
+        """
+        params = [token, name, ids, ]
+        method = "QueuesAPI.PurgeDeadLetters"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def stats(self, token: Any, name: str):
+        """
+        Depth, size in bytes and number of in-flight requests of the queue
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.Stats"
+        self.__add_request(method, params, lambda payload: QueueStats.from_json(payload))
+
+    def peek(self, token: Any, name: str, limit: int):
+        """
+        The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+        """
+        params = [token, name, limit, ]
+        method = "QueuesAPI.Peek"
+        self.__add_request(method, params, lambda payload: [QueueItem.from_json(x) for x in (payload or [])])
+
+    def delete_message(self, token: Any, name: str, id: int):
+        """
+        Delete request from the queue by ID. In-flight requests can not be deleted
+        """
+        params = [token, name, id, ]
+        method = "QueuesAPI.DeleteMessage"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def purge(self, token: Any, name: str):
+        """
+        Delete all not in-flight requests from the queue. Returns number of deleted requests
+        """
+        params = [token, name, ]
+        method = "QueuesAPI.Purge"
+        self.__add_request(method, params, lambda payload: payload)
+
     def __add_request(self, method: str, params, factory):
         request_id = self.__next_id()
         request = {
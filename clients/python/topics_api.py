@@ -0,0 +1,244 @@
+from aiohttp import client
+
+from dataclasses import dataclass
+
+from typing import Any, List, Optional
+
+
+
+@dataclass
+class Topic:
+    name: 'str'
+    queues: 'Any'
+
+    def to_json(self) -> dict:
+        return {
+            "name": self.name,
+            "queues": self.queues,
+        }
+
+    @staticmethod
+    def from_json(payload: dict) -> 'Topic':
+        return Topic(
+                name=payload['name'],
+                queues=payload['queues'],
+        )
+
+
+class TopicsAPIError(RuntimeError):
+    def __init__(self, method: str, code: int, message: str, data: Any):
+        super().__init__('{}: {}: {} - {}'.format(method, code, message, data))
+        self.code = code
+        self.message = message
+        self.data = data
+
+    @staticmethod
+    def from_json(method: str, payload: dict) -> 'TopicsAPIError':
+        return TopicsAPIError(
+            method=method,
+            code=payload['code'],
+            message=payload['message'],
+            data=payload.get('data')
+        )
+
+
+class TopicsAPIClient:
+    """
+    API for managing topics
+    """
+
+    def __init__(self, base_url: str = 'https://127.0.0.1:3434/u/', session: Optional[client.ClientSession] = None):
+        self.__url = base_url
+        self.__id = 1
+        self.__request = session.request if session is not None else client.request
+
+    def __next_id(self):
+        self.__id += 1
+        return self.__id
+
+    async def create(self, token: Any, name: str) -> Topic:
+        """
+        Create topic without subscribers
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "TopicsAPI.Create",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise TopicsAPIError.from_json('create', payload['error'])
+        return Topic.from_json(payload['result'])
+
+    async def remove(self, token: Any, name: str) -> bool:
+        """
+        Remove topic. Subscribed queues are not affected
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "TopicsAPI.Remove",
+            "id": self.__next_id(),
+            "params": [token, name, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise TopicsAPIError.from_json('remove', payload['error'])
+        return payload['result']
+
+    async def list(self, token: Any) -> List[Topic]:
+        """
+        List of all topics
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "TopicsAPI.List",
+            "id": self.__next_id(),
+            "params": [token, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise TopicsAPIError.from_json('list', payload['error'])
+        return [Topic.from_json(x) for x in (payload['result'] or [])]
+
+    async def subscribe(self, token: Any, name: str, queue: str) -> bool:
+        """
+        Subscribe queue to topic: each published request will be copied to the queue
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "TopicsAPI.Subscribe",
+            "id": self.__next_id(),
+            "params": [token, name, queue, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise TopicsAPIError.from_json('subscribe', payload['error'])
+        return payload['result']
+
+    async def unsubscribe(self, token: Any, name: str, queue: str) -> bool:
+        """
+        Unsubscribe queue from topic
+        """
+        response = await self._invoke({
+            "jsonrpc": "2.0",
+            "method": "TopicsAPI.Unsubscribe",
+            "id": self.__next_id(),
+            "params": [token, name, queue, ]
+        })
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        payload = await response.json()
+        if 'error' in payload:
+            raise TopicsAPIError.from_json('unsubscribe', payload['error'])
+        return payload['result']
+
+    async def _invoke(self, request):
+        return await self.__request('POST', self.__url, json=request)
+
+
+class TopicsAPIBatch:
+    """
+    API for managing topics
+    """
+
+    def __init__(self, client: TopicsAPIClient, size: int = 10):
+        self.__id = 1
+        self.__client = client
+        self.__requests = []
+        self.__batch = {}
+        self.__batch_size = size
+
+    def __next_id(self):
+        self.__id += 1
+        return self.__id
+
+    def create(self, token: Any, name: str):
+        """
+        Create topic without subscribers
+        """
+        params = [token, name, ]
+        method = "TopicsAPI.Create"
+        self.__add_request(method, params, lambda payload: Topic.from_json(payload))
+
+    def remove(self, token: Any, name: str):
+        """
+        Remove topic. Subscribed queues are not affected
+        """
+        params = [token, name, ]
+        method = "TopicsAPI.Remove"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def list(self, token: Any):
+        """
+        List of all topics
+        """
+        params = [token, ]
+        method = "TopicsAPI.List"
+        self.__add_request(method, params, lambda payload: [Topic.from_json(x) for x in (payload or [])])
+
+    def subscribe(self, token: Any, name: str, queue: str):
+        """
+        Subscribe queue to topic: each published request will be copied to the queue
+        """
+        params = [token, name, queue, ]
+        method = "TopicsAPI.Subscribe"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def unsubscribe(self, token: Any, name: str, queue: str):
+        """
+        Unsubscribe queue from topic
+        """
+        params = [token, name, queue, ]
+        method = "TopicsAPI.Unsubscribe"
+        self.__add_request(method, params, lambda payload: payload)
+
+    def __add_request(self, method: str, params, factory):
+        request_id = self.__next_id()
+        request = {
+            "jsonrpc": "2.0",
+            "method": method,
+            "id": request_id,
+            "params": params
+        }
+        self.__requests.append(request)
+        self.__batch[request_id] = (request, factory)
+
+    async def __aenter__(self):
+        self.__batch = {}
+        return self
+
+    async def __aexit__(self, exc_type, exc_val, exc_tb):
+        await self()
+
+    async def __call__(self) -> list:
+        offset = 0
+        num = len(self.__requests)
+        results = []
+        while offset < num:
+            next_offset = offset + self.__batch_size
+            batch = self.__requests[offset:min(num, next_offset)]
+            offset = next_offset
+
+            responses = await self.__post_batch(batch)
+            results = results + responses
+
+        self.__batch = {}
+        self.__requests = []
+        return results
+
+    async def __post_batch(self, batch: list) -> list:
+        response = await self.__client._invoke(batch)
+        assert response.status // 100 == 2, str(response.status) + " " + str(response.reason)
+        results = await response.json()
+        ans = []
+        for payload in results:
+            request, factory = self.__batch[payload['id']]
+            if 'error' in payload:
+                raise TopicsAPIError.from_json(request['method'], payload['error'])
+            else:
+                ans.append(factory(payload['result']))
+        return ans
\ No newline at end of file
@@ -41,6 +41,16 @@ export interface Manifest {
     maximum_payload: number | null
     cron: Array<Schedule> | null
     static: string | null
+    parse_headers: boolean | null
+    cgi: boolean | null
+    sandbox: Sandbox | null
+    max_concurrency: number | null
+    max_waiting: number | null
+    wait_timeout: JsonDuration | null
+    workers: Workers | null
+    proxy: Proxy | null
+    stream: boolean | null
+    websocket: WebSocket | null
 }
 
 export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
@@ -51,12 +61,43 @@ export interface Schedule {
     time_limit: JsonDuration
 }
 
+export interface Sandbox {
+    network: boolean | null
+    memory: number | null
+    cpu: number | null
+    pids: number | null
+}
+
+export interface Workers {
+    size: number | null
+    max_requests: number | null
+    idle_timeout: JsonDuration | null
+}
+
+export interface Proxy {
+    socket: string | null
+    address: string | null
+    start_timeout: JsonDuration | null
+}
+
+export interface WebSocket {
+    framing: string | null
+    binary: boolean | null
+    max_message: number | null
+}
+
 export interface Record {
     uid: string
     error: string | null
     request: Request
     begin: Time
     end: Time
+    alias: string | null
+    status: number | null
+    exit_code: number | null
+    bytes_in: number | null
+    bytes_out: number | null
+    kill_reason: string | null
 }
 
 export interface Request {
@@ -66,10 +107,54 @@ export interface Request {
     remote_address: string
     form: any
     headers: any
+    form_values: any | null
+    header_values: any | null
+    traceparent: string | null
+    deliver_at: Time | null
+    claims: any | null
 }
 
 export type Time = string; // RFC3339
 
+export interface Query {
+    uid: string | null
+    from: Time | null
+    to: Time | null
+    errors_only: boolean | null
+    method: string | null
+    limit: number | null
+}
+
+export interface Summary {
+    uid: string | null
+    from: Time
+    to: Time
+    requests: number
+    errors: number
+    error_ratio: number
+    rate_per_minute: number
+    p50_ms: number
+    p95_ms: number
+    p99_ms: number
+    top_callers: Array<Caller>
+}
+
+export interface Caller {
+    address: string
+    requests: number
+}
+
+export interface LogEntry {
+    id: number
+    kind: string
+    name: string | null
+    begin: Time
+    end: Time
+    output: string
+    truncated: boolean | null
+    error: string | null
+}
+
 
 
 
@@ -377,6 +462,43 @@ export class LambdaAPI {
         })) as Array<Record>;
     }
 
+    /**
+    Search stats records of the app from newest to oldest (UID in query is ignored)
+    **/
+    async queryStats(token: Token, uid: string, query: Query): Promise<Array<Record>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.QueryStats",
+            "id" : this.__next_id(),
+            "params" : [token, uid, query]
+        })) as Array<Record>;
+    }
+
+    /**
+    Aggregated stats of the app (rate, latency percentiles, error ratio, top callers) over window till now.
+Zero window means maximum available
+    **/
+    async summary(token: Token, uid: string, window: JsonDuration): Promise<Summary> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Summary",
+            "id" : this.__next_id(),
+            "params" : [token, uid, window]
+        })) as Summary;
+    }
+
+    /**
+    Captured output (stderr) of recent invocations and actions with ID greater than after. Positive limit keeps only last entries
+    **/
+    async logs(token: Token, uid: string, after: number, limit: number): Promise<Array<LogEntry>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "LambdaAPI.Logs",
+            "id" : this.__next_id(),
+            "params" : [token, uid, after, limit]
+        })) as Array<LogEntry>;
+    }
+
     /**
     Actions available for the app
     **/
@@ -14,20 +14,81 @@ export interface Policy {
     id: string
     definition: PolicyDefinition
     lambdas: JsonStringSet
+    tokens: Array<PolicyToken> | null
 }
 
 export interface PolicyDefinition {
     allowed_ip: JsonStringSet | null
+    denied_ip: JsonStringSet | null
     allowed_origin: JsonStringSet | null
     public: boolean
     tokens: any | null
+    jwt: JWTPolicy | null
+    hmac: HMACPolicy | null
+    rate_limit: RateLimit | null
+    rules: Array<PolicyRule> | null
 }
 
 export interface JsonStringSet {
 }
 
+export interface JWTPolicy {
+    secret: string | null
+    jwks: string | null
+    issuer: string | null
+    audience: string | null
+    claims: any | null
+}
+
+export interface HMACPolicy {
+    secret: string
+    header: string | null
+    algorithm: string | null
+    format: string | null
+    prefix: string | null
+    tolerance: JsonDuration | null
+}
+
+export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
+
+export interface RateLimit {
+    requests: number
+    interval: JsonDuration | null
+    burst: number | null
+    key: string | null
+}
+
+export interface PolicyRule {
+    methods: Array<string> | null
+    path: string | null
+    headers: any | null
+    content_type: string | null
+    action: string
+    public: boolean
+    token_ids: JsonStringSet | null
+    allowed_ip: JsonStringSet | null
+    denied_ip: JsonStringSet | null
+}
+
+export interface PolicyToken {
+    id: string
+    title: string | null
+    created: Time
+    expires: Time | null
+    last_used: Time | null
+    enabled: boolean
+}
+
+export type Time = string; // RFC3339
+
 export type Token = string;
 
+export interface IssuedToken {
+    id: string
+    secret: string
+    expires: Time | null
+}
+
 
 
 
@@ -263,6 +324,54 @@ export class PoliciesAPI {
         })) as boolean;
     }
 
+    /**
+    Issue new token for the policy. Secret is returned only once. Zero TTL means token without expiration
+    **/
+    async issueToken(token: Token, policy: string, title: string, ttl: JsonDuration): Promise<IssuedToken> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.IssueToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, title, ttl]
+        })) as IssuedToken;
+    }
+
+    /**
+    Rotate secret of the policy token. Secret is returned only once
+    **/
+    async rotateToken(token: Token, policy: string, tokenID: string): Promise<IssuedToken> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.RotateToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID]
+        })) as IssuedToken;
+    }
+
+    /**
+    Revoke (remove) policy token
+    **/
+    async revokeToken(token: Token, policy: string, tokenID: string): Promise<boolean> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.RevokeToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID]
+        })) as boolean;
+    }
+
+    /**
+    Enable or disable policy token
+    **/
+    async enableToken(token: Token, policy: string, tokenID: string, enabled: boolean): Promise<boolean> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "PoliciesAPI.EnableToken",
+            "id" : this.__next_id(),
+            "params" : [token, policy, tokenID, enabled]
+        })) as boolean;
+    }
+
 
     private __next_id() {
         this.__id += 1;
@@ -52,6 +52,16 @@ export interface Manifest {
     maximum_payload: number | null
     cron: Array<Schedule> | null
     static: string | null
+    parse_headers: boolean | null
+    cgi: boolean | null
+    sandbox: Sandbox | null
+    max_concurrency: number | null
+    max_waiting: number | null
+    wait_timeout: JsonDuration | null
+    workers: Workers | null
+    proxy: Proxy | null
+    stream: boolean | null
+    websocket: WebSocket | null
 }
 
 export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
@@ -62,6 +72,31 @@ export interface Schedule {
     time_limit: JsonDuration
 }
 
+export interface Sandbox {
+    network: boolean | null
+    memory: number | null
+    cpu: number | null
+    pids: number | null
+}
+
+export interface Workers {
+    size: number | null
+    max_requests: number | null
+    idle_timeout: JsonDuration | null
+}
+
+export interface Proxy {
+    socket: string | null
+    address: string | null
+    start_timeout: JsonDuration | null
+}
+
+export interface WebSocket {
+    framing: string | null
+    binary: boolean | null
+    max_message: number | null
+}
+
 export interface Template {
     name: string
     description: string
@@ -73,6 +108,12 @@ export interface Record {
     request: Request
     begin: Time
     end: Time
+    alias: string | null
+    status: number | null
+    exit_code: number | null
+    bytes_in: number | null
+    bytes_out: number | null
+    kill_reason: string | null
 }
 
 export interface Request {
@@ -82,10 +123,48 @@ export interface Request {
     remote_address: string
     form: any
     headers: any
+    form_values: any | null
+    header_values: any | null
+    traceparent: string | null
+    deliver_at: Time | null
+    claims: any | null
 }
 
 export type Time = string; // RFC3339
 
+export interface Query {
+    uid: string | null
+    from: Time | null
+    to: Time | null
+    errors_only: boolean | null
+    method: string | null
+    limit: number | null
+}
+
+export interface ProjectSummary {
+    total: Summary
+    lambdas: Array<Summary>
+}
+
+export interface Summary {
+    uid: string | null
+    from: Time
+    to: Time
+    requests: number
+    errors: number
+    error_ratio: number
+    rate_per_minute: number
+    p50_ms: number
+    p95_ms: number
+    p99_ms: number
+    top_callers: Array<Caller>
+}
+
+export interface Caller {
+    address: string
+    requests: number
+}
+
 
 
 
@@ -333,6 +412,30 @@ export class ProjectAPI {
         })) as Array<Record>;
     }
 
+    /**
+    Search global stats records from newest to oldest
+    **/
+    async queryStats(token: Token, query: Query): Promise<Array<Record>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.QueryStats",
+            "id" : this.__next_id(),
+            "params" : [token, query]
+        })) as Array<Record>;
+    }
+
+    /**
+    Aggregated stats of the whole project and each app over window till now. Zero window means maximum available
+    **/
+    async summary(token: Token, window: JsonDuration): Promise<ProjectSummary> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "ProjectAPI.Summary",
+            "id" : this.__next_id(),
+            "params" : [token, window]
+        })) as ProjectSummary;
+    }
+
     /**
     Create new app (lambda)
     **/
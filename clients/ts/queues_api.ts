@@ -16,12 +16,67 @@ export interface Queue {
     retry: number
     max_element_size: number
     interval: JsonDuration
+    multiplier: number | null
+    max_interval: JsonDuration | null
+    jitter: number | null
+    dead_letter: boolean | null
+    dead_letter_queue: string | null
+    workers: number | null
+    result: string | null
+    reply_queue: string | null
+    max_result_size: number | null
+    result_ttl: JsonDuration | null
+    callback_urls: JsonStringSet | null
 }
 
 export type JsonDuration = string; // suffixes: ns, us, ms, s, m, h
 
+export interface JsonStringSet {
+}
+
 export type Token = string;
 
+export interface DeadLetter {
+    id: string
+    queue: string
+    reason: string
+    attempts: number
+    failed: Time
+    size: number
+    request: Request
+}
+
+export type Time = string; // RFC3339
+
+export interface Request {
+    method: string
+    url: string
+    path: string
+    remote_address: string
+    form: any
+    headers: any
+    form_values: any | null
+    header_values: any | null
+    traceparent: string | null
+    deliver_at: Time | null
+    claims: any | null
+}
+
+export interface QueueStats {
+    name: string
+    depth: number
+    in_flight: number
+    size: number
+    dead_letters: number
+}
+
+export interface QueueItem {
+    id: number
+    leased: boolean
+    size: number
+    request: Request
+}
+
 
 
 
@@ -245,6 +300,90 @@ export class QueuesAPI {
         })) as boolean;
     }
 
+    /**
+    Failed requests (dead letters) of the queue
+    **/
+    async deadLetters(token: Token, name: string): Promise<Array<DeadLetter>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as Array<DeadLetter>;
+    }
+
+    /**
+    Put failed requests back to the queue (all if IDs not set). Returns number of replayed requests
+    **/
+    async replayDeadLetters(token: Token, name: string, ids: Array<string>): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.ReplayDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name, ids]
+        })) as number;
+    }
+
+    /**
+    Remove failed requests (all if IDs not set). Returns number of removed requests
+    **/
+    async purgeDeadLetters(token: Token, name: string, ids: Array<string>): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.PurgeDeadLetters",
+            "id" : this.__next_id(),
+            "params" : [token, name, ids]
+        })) as number;
+    }
+
+    /**
+    Depth, size in bytes and number of in-flight requests of the queue
+    **/
+    async stats(token: Token, name: string): Promise<QueueStats> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Stats",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as QueueStats;
+    }
+
+    /**
+    The oldest requests (without body) of the queue, including in-flight. Non-positive limit means all requests
+    **/
+    async peek(token: Token, name: string, limit: number): Promise<Array<QueueItem>> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Peek",
+            "id" : this.__next_id(),
+            "params" : [token, name, limit]
+        })) as Array<QueueItem>;
+    }
+
+    /**
+    Delete request from the queue by ID. In-flight requests can not be deleted
+    **/
+    async deleteMessage(token: Token, name: string, id: number): Promise<boolean> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.DeleteMessage",
+            "id" : this.__next_id(),
+            "params" : [token, name, id]
+        })) as boolean;
+    }
+
+    /**
+    Delete all not in-flight requests from the queue. Returns number of deleted requests
+    **/
+    async purge(token: Token, name: string): Promise<number> {
+        return (await this.__call({
+            "jsonrpc" : "2.0",
+            "method" : "QueuesAPI.Purge",
+            "id" : this.__next_id(),
+            "params" : [token, name]
+        })) as number;
+    }
+
 
     private __next_id() {
         this.__id += 1;
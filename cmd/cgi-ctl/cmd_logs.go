@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type logs struct {
+	remoteLink
+	uidLocator
+	Limit    int           `short:"n" long:"limit" env:"LIMIT" description:"number of last entries to show" default:"10"`
+	Follow   bool          `short:"f" long:"follow" env:"FOLLOW" description:"wait and print new entries"`
+	Interval time.Duration `long:"interval" env:"INTERVAL" description:"polling interval in follow mode" default:"1s"`
+}
+
+func (cmd *logs) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	if err := cmd.parseUID(); err != nil {
+		return err
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	log.Println("lambda", cmd.UID)
+
+	var after uint64
+	limit := cmd.Limit
+	for {
+		entries, err := cmd.Lambdas().Logs(ctx, token, cmd.UID, after, limit)
+		if err != nil {
+			return fmt.Errorf("get logs: %w", err)
+		}
+		for _, entry := range entries {
+			printLogEntry(entry)
+			after = entry.ID
+		}
+		if !cmd.Follow {
+			return nil
+		}
+		limit = 0 // all new entries
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(cmd.Interval):
+		}
+	}
+}
+
+func printLogEntry(entry types.LogEntry) {
+	header := entry.Begin.Format(time.RFC3339) + " " + entry.Kind
+	if entry.Name != "" {
+		header += " " + entry.Name
+	}
+	if entry.Kind != types.LogProcess {
+		header += " (" + entry.End.Sub(entry.Begin).String() + ")"
+	}
+	if entry.Err != "" {
+		header += " error: " + entry.Err
+	}
+	fmt.Println("---", header)
+	fmt.Print(entry.Output)
+	if len(entry.Output) > 0 && entry.Output[len(entry.Output)-1] != '\n' {
+		fmt.Println()
+	}
+	if entry.Truncated {
+		fmt.Println("... (truncated)")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type queueName struct {
+	Args struct {
+		Queue string `name:"queue" positional-arg:"queue" description:"queue name" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type queueStats struct {
+	remoteLink
+	queueName
+	JSON bool `long:"json" env:"JSON" description:"print stats as JSON"`
+}
+
+func (cmd *queueStats) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	info, err := cmd.Queues().Stats(ctx, token, cmd.Args.Queue)
+	if err != nil {
+		return fmt.Errorf("get queue stats: %w", err)
+	}
+	if cmd.JSON {
+		return json.NewEncoder(os.Stdout).Encode(info)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "QUEUE\tDEPTH\tIN-FLIGHT\tSIZE\tDEAD LETTERS")
+	fmt.Fprintln(out, info.Name+"\t"+
+		strconv.FormatInt(info.Depth, 10)+"\t"+
+		strconv.FormatInt(info.InFlight, 10)+"\t"+
+		strconv.FormatInt(info.Size, 10)+"\t"+
+		strconv.FormatInt(info.DeadLetters, 10))
+	return nil
+}
+
+type queuePeek struct {
+	remoteLink
+	queueName
+	Limit   int  `short:"n" long:"limit" env:"LIMIT" description:"maximum number of requests (0 means all)" default:"10"`
+	Headers bool `short:"H" long:"headers" env:"HEADERS" description:"print headers of requests"`
+	JSON    bool `long:"json" env:"JSON" description:"print requests as JSON (one per line)"`
+}
+
+func (cmd *queuePeek) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	items, err := cmd.Queues().Peek(ctx, token, cmd.Args.Queue, cmd.Limit)
+	if err != nil {
+		return fmt.Errorf("peek queue: %w", err)
+	}
+	if cmd.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "ID\tSTATE\tSIZE\tMETHOD\tPATH\tREMOTE")
+	for _, item := range items {
+		state := "pending"
+		if item.Leased {
+			state = "in-flight"
+		}
+		fmt.Fprintln(out, strconv.FormatUint(item.ID, 10)+"\t"+
+			state+"\t"+
+			strconv.FormatInt(item.Size, 10)+"\t"+
+			item.Request.Method+"\t"+
+			item.Request.Path+"\t"+
+			item.Request.RemoteAddress)
+		if cmd.Headers {
+			var names = make([]string, 0, len(item.Request.Headers))
+			for name := range item.Request.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintln(out, "\t\t\t"+name+": "+strings.ReplaceAll(item.Request.Headers[name], "\t", " "))
+			}
+		}
+	}
+	return nil
+}
+
+type queueDelete struct {
+	remoteLink
+	Args struct {
+		Queue string   `name:"queue" positional-arg:"queue" description:"queue name" required:"yes"`
+		IDs   []uint64 `name:"id" positional-arg:"id" description:"requests IDs" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *queueDelete) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, id := range cmd.Args.IDs {
+		log.Println("deleting request", id)
+		_, err := cmd.Queues().DeleteMessage(ctx, token, cmd.Args.Queue, id)
+		if err != nil {
+			return fmt.Errorf("delete request %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+type queuePurge struct {
+	remoteLink
+	queueName
+}
+
+func (cmd *queuePurge) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	count, err := cmd.Queues().Purge(ctx, token, cmd.Args.Queue)
+	if err != nil {
+		return fmt.Errorf("purge queue: %w", err)
+	}
+	log.Println("deleted", count, "requests")
+	return nil
+}
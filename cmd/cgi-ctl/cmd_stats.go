@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+type statsCmd struct {
+	remoteLink
+	uidLocator
+	All    bool          `short:"a" long:"all" env:"ALL" description:"show records of all lambdas in project"`
+	Limit  int           `short:"n" long:"limit" env:"LIMIT" description:"maximum number of records (0 means unlimited)" default:"20"`
+	Since  time.Duration `long:"since" env:"SINCE" description:"show records not older than duration"`
+	From   string        `long:"from" env:"FROM" description:"show records since time (RFC3339)"`
+	To     string        `long:"to" env:"TO" description:"show records before time (RFC3339)"`
+	Errors bool          `short:"e" long:"errors" env:"ERRORS" description:"show only failed requests"`
+	Method string        `short:"m" long:"method" env:"METHOD" description:"filter by HTTP method"`
+	JSON   bool          `long:"json" env:"JSON" description:"print records as JSON (one per line)"`
+}
+
+func (cmd *statsCmd) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	query := stats.Query{
+		ErrorsOnly: cmd.Errors,
+		Method:     cmd.Method,
+		Limit:      cmd.Limit,
+	}
+	if cmd.Since > 0 {
+		query.From = time.Now().Add(-cmd.Since)
+	}
+	if cmd.From != "" {
+		from, err := time.Parse(time.RFC3339, cmd.From)
+		if err != nil {
+			return fmt.Errorf("parse from: %w", err)
+		}
+		query.From = from
+	}
+	if cmd.To != "" {
+		to, err := time.Parse(time.RFC3339, cmd.To)
+		if err != nil {
+			return fmt.Errorf("parse to: %w", err)
+		}
+		query.To = to
+	}
+	if !cmd.All {
+		if err := cmd.parseUID(); err != nil {
+			return err
+		}
+		log.Println("lambda", cmd.UID)
+	}
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	var records []stats.Record
+	if cmd.All {
+		records, err = cmd.Project().QueryStats(ctx, token, query)
+	} else {
+		records, err = cmd.Lambdas().QueryStats(ctx, token, cmd.UID, query)
+	}
+	if err != nil {
+		return fmt.Errorf("get stats: %w", err)
+	}
+	if cmd.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	printRecords(records, cmd.All)
+	return nil
+}
+
+func printRecords(records []stats.Record, withUID bool) {
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+	if withUID {
+		fmt.Fprint(out, "UID\t")
+	}
+	fmt.Fprintln(out, "BEGIN\tDURATION\tMETHOD\tPATH\tSTATUS\tIN\tOUT\tERROR")
+	for _, record := range records {
+		if withUID {
+			fmt.Fprint(out, record.UID, "\t")
+		}
+		status := ""
+		if record.Status != 0 {
+			status = strconv.Itoa(record.Status)
+		}
+		errText := record.Err
+		if record.KillReason != "" {
+			errText = record.KillReason + ": " + errText
+		}
+		fmt.Fprintln(out, record.Begin.Format(time.RFC3339)+"\t"+
+			record.End.Sub(record.Begin).Round(time.Millisecond).String()+"\t"+
+			record.Request.Method+"\t"+
+			record.Request.Path+"\t"+
+			status+"\t"+
+			strconv.FormatInt(record.BytesIn, 10)+"\t"+
+			strconv.FormatInt(record.BytesOut, 10)+"\t"+
+			errText)
+	}
+}
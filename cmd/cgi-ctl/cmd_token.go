@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/cmd/internal"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type tokenList struct {
+	remoteLink
+	Args struct {
+		Policy string `name:"policy" positional-arg:"policy" description:"policy name" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *tokenList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	policies, err := cmd.Policies().List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list policies: %w", err)
+	}
+	for _, policy := range policies {
+		if policy.ID != cmd.Args.Policy {
+			continue
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer out.Flush()
+		fmt.Fprintln(out, "ID\tTITLE\tENABLED\tCREATED\tEXPIRES\tLAST USED")
+		for _, t := range policy.Tokens {
+			fmt.Fprintln(out, t.ID+"\t"+t.Title+"\t"+strconv.FormatBool(t.Enabled)+"\t"+
+				t.Created.Format(time.RFC3339)+"\t"+formatTime(t.Expires)+"\t"+formatTime(t.LastUsed))
+		}
+		return nil
+	}
+	return fmt.Errorf("policy %s does not exist", cmd.Args.Policy)
+}
+
+type tokenIssue struct {
+	remoteLink
+	TTL  time.Duration `short:"t" long:"ttl" env:"TTL" description:"token lifetime (0 means no expiration)"`
+	Args struct {
+		Policy string `name:"policy" positional-arg:"policy" description:"policy name" required:"yes"`
+		Title  string `name:"title" positional-arg:"title" description:"token title"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *tokenIssue) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	issued, err := cmd.Policies().IssueToken(ctx, token, cmd.Args.Policy, cmd.Args.Title, types.JsonDuration(cmd.TTL))
+	if err != nil {
+		return fmt.Errorf("issue token: %w", err)
+	}
+	printIssued(issued)
+	return nil
+}
+
+type tokenRef struct {
+	remoteLink
+	Args struct {
+		Policy string   `name:"policy" positional-arg:"policy" description:"policy name" required:"yes"`
+		IDs    []string `name:"id" positional-arg:"id" description:"tokens IDs" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type tokenRotate struct {
+	tokenRef
+}
+
+func (cmd *tokenRotate) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, id := range cmd.Args.IDs {
+		log.Println("rotating token", id)
+		issued, err := cmd.Policies().RotateToken(ctx, token, cmd.Args.Policy, id)
+		if err != nil {
+			return fmt.Errorf("rotate token %s: %w", id, err)
+		}
+		printIssued(issued)
+	}
+	return nil
+}
+
+type tokenRevoke struct {
+	tokenRef
+}
+
+func (cmd *tokenRevoke) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, id := range cmd.Args.IDs {
+		log.Println("revoking token", id)
+		_, err := cmd.Policies().RevokeToken(ctx, token, cmd.Args.Policy, id)
+		if err != nil {
+			return fmt.Errorf("revoke token %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+type tokenEnable struct {
+	tokenRef
+}
+
+func (cmd *tokenEnable) Execute(args []string) error {
+	return cmd.setEnabled(true)
+}
+
+type tokenDisable struct {
+	tokenRef
+}
+
+func (cmd *tokenDisable) Execute(args []string) error {
+	return cmd.setEnabled(false)
+}
+
+func (cmd *tokenRef) setEnabled(enabled bool) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, id := range cmd.Args.IDs {
+		_, err := cmd.Policies().EnableToken(ctx, token, cmd.Args.Policy, id, enabled)
+		if err != nil {
+			return fmt.Errorf("update token %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// secret is printed to stdout, the rest to stderr
+func printIssued(issued *application.IssuedToken) {
+	log.Println("token", issued.ID, "expires:", formatTime(issued.Expires))
+	fmt.Println(issued.Secret)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
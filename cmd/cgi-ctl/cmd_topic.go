@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reddec/trusted-cgi/cmd/internal"
+)
+
+type topicList struct {
+	remoteLink
+}
+
+func (cmd *topicList) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	topics, err := cmd.Topics().List(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list topics: %w", err)
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintln(out, "TOPIC\tQUEUES")
+	for _, topic := range topics {
+		var queues = make([]string, 0, len(topic.Queues))
+		for name := range topic.Queues {
+			queues = append(queues, name)
+		}
+		sort.Strings(queues)
+		fmt.Fprintln(out, topic.Name+"\t"+strings.Join(queues, ","))
+	}
+	return nil
+}
+
+type topicCreate struct {
+	remoteLink
+	Args struct {
+		Topic string `name:"topic" positional-arg:"topic" description:"topic name" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *topicCreate) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	_, err = cmd.Topics().Create(ctx, token, cmd.Args.Topic)
+	if err != nil {
+		return fmt.Errorf("create topic: %w", err)
+	}
+	return nil
+}
+
+type topicRemove struct {
+	remoteLink
+	Args struct {
+		Topic string `name:"topic" positional-arg:"topic" description:"topic name" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (cmd *topicRemove) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	_, err = cmd.Topics().Remove(ctx, token, cmd.Args.Topic)
+	if err != nil {
+		return fmt.Errorf("remove topic: %w", err)
+	}
+	return nil
+}
+
+type topicSubscription struct {
+	remoteLink
+	Args struct {
+		Topic  string   `name:"topic" positional-arg:"topic" description:"topic name" required:"yes"`
+		Queues []string `name:"queue" positional-arg:"queue" description:"queues names" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type topicSubscribe struct {
+	topicSubscription
+}
+
+func (cmd *topicSubscribe) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, queue := range cmd.Args.Queues {
+		log.Println("subscribing queue", queue)
+		_, err := cmd.Topics().Subscribe(ctx, token, cmd.Args.Topic, queue)
+		if err != nil {
+			return fmt.Errorf("subscribe queue %s: %w", queue, err)
+		}
+	}
+	return nil
+}
+
+type topicUnsubscribe struct {
+	topicSubscription
+}
+
+func (cmd *topicUnsubscribe) Execute(args []string) error {
+	ctx, closer := internal.SignalContext()
+	defer closer()
+	log.Println("login...")
+	token, err := cmd.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, queue := range cmd.Args.Queues {
+		log.Println("unsubscribing queue", queue)
+		_, err := cmd.Topics().Unsubscribe(ctx, token, cmd.Args.Topic, queue)
+		if err != nil {
+			return fmt.Errorf("unsubscribe queue %s: %w", queue, err)
+		}
+	}
+	return nil
+}
@@ -41,6 +41,18 @@ func (rl *remoteLink) Project() *client.ProjectAPIClient {
 	return &client.ProjectAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
 }
 
+func (rl *remoteLink) Queues() *client.QueuesAPIClient {
+	return &client.QueuesAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
+}
+
+func (rl *remoteLink) Topics() *client.TopicsAPIClient {
+	return &client.TopicsAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
+}
+
+func (rl *remoteLink) Policies() *client.PoliciesAPIClient {
+	return &client.PoliciesAPIClient{BaseURL: urlJoin(rl.URL, "u", "")}
+}
+
 func (rl *remoteLink) Token(ctx context.Context) (*api.Token, error) {
 	if !rl.Independent {
 		var cf controlFile
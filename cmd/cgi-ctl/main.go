@@ -19,7 +19,30 @@ type Config struct {
 	Create   create   `command:"create" description:"create new lambda on the remote platform and initialize local environment"`
 	Alias    alias    `command:"alias" description:"list, created or remove alias for the lambda"`
 	Invoke   invoke   `command:"invoke" description:"invoke remote lambda"`
-	Update   struct {
+	Logs     logs     `command:"logs" description:"print captured output (stderr) of recent invocations and actions"`
+	Stats    statsCmd `command:"stats" description:"search requests statistics of the lambda or whole project"`
+	Queue    struct {
+		Stats  queueStats  `command:"stats" description:"show depth, size and number of in-flight requests of queue"`
+		Peek   queuePeek   `command:"peek" description:"list the oldest requests of queue with headers"`
+		Delete queueDelete `command:"delete" description:"delete requests from queue by ID"`
+		Purge  queuePurge  `command:"purge" description:"delete all not in-flight requests from queue"`
+	} `command:"queue" description:"inspect and clean queues"`
+	Topic struct {
+		List        topicList        `command:"list" description:"list topics and subscribed queues"`
+		Create      topicCreate      `command:"create" description:"create topic"`
+		Remove      topicRemove      `command:"remove" description:"remove topic (subscribed queues are not affected)"`
+		Subscribe   topicSubscribe   `command:"subscribe" description:"subscribe queues to topic"`
+		Unsubscribe topicUnsubscribe `command:"unsubscribe" description:"unsubscribe queues from topic"`
+	} `command:"topic" description:"manage pub/sub topics"`
+	Token struct {
+		List    tokenList    `command:"list" description:"list tokens of policy"`
+		Issue   tokenIssue   `command:"issue" description:"issue new token for policy and print secret"`
+		Rotate  tokenRotate  `command:"rotate" description:"replace secret of token and print new secret"`
+		Revoke  tokenRevoke  `command:"revoke" description:"remove tokens from policy"`
+		Enable  tokenEnable  `command:"enable" description:"enable tokens"`
+		Disable tokenDisable `command:"disable" description:"disable tokens without removing"`
+	} `command:"token" description:"manage tokens of policies"`
+	Update struct {
 		Manifest updateManifest `command:"manifest" description:"pull and save remote manifest file"`
 	} `command:"update" description:"update parts of the lambda"`
 	Apply apply `command:"apply" description:"push manifest to the remote platform"`
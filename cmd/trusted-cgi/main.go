@@ -17,13 +17,20 @@ import (
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/topicmanager"
 	"github.com/reddec/trusted-cgi/cmd/internal"
 	internal2 "github.com/reddec/trusted-cgi/internal"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
 	"github.com/reddec/trusted-cgi/server"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/stats/impl/metrics"
+	"github.com/reddec/trusted-cgi/stats/impl/rollup"
+	"github.com/reddec/trusted-cgi/stats/impl/seglog"
+	"github.com/reddec/trusted-cgi/tracing"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 const version = "dev"
@@ -34,6 +41,7 @@ type Config struct {
 	Dir       string   `short:"d" long:"dir" env:"DIR" description:"Project directory" default:"."`
 	Templates string   `long:"templates" env:"TEMPLATES" description:"Templates directory" default:".templates"`
 	Queues    Queues   `group:"queues" namespace:"queues" env-namespace:"QUEUES"`
+	Topics    Topics   `group:"topics" namespace:"topics" env-namespace:"TOPICS"`
 	Policies  Policies `group:"policies" namespace:"policies" env-namespace:"POLICIES"`
 	//
 	InitialAdminPassword string        `long:"initial-admin-password" env:"INITIAL_ADMIN_PASSWORD" description:"Initial admin password" default:"admin"`
@@ -41,11 +49,21 @@ type Config struct {
 	DisableChroot        bool          `long:"disable-chroot" env:"DISABLE_CHROOT" description:"Disable use different user for spawn"`
 	SSHKey               string        `long:"ssh-key" env:"SSH_KEY" description:"Path to ssh key. If not empty and not exists - it will be generated" default:".id_rsa"`
 	Dev                  bool          `long:"dev" env:"DEV" description:"Enabled dev mode (disables chroot)"`
-	BehindProxy          bool          `long:"behind-proxy" env:"BEHIND_PROXY" description:"Respect X-Real-Ip and X-Forwarded-For"`
-	StatsCache           uint          `long:"stats-cache" env:"STATS_CACHE" description:"Maximum cache for stats" default:"8192"`
-	StatsFile            string        `long:"stats-file" env:"STATS_FILE" description:"Binary file for statistics dump" default:".stats"`
-	StatsInterval        time.Duration `long:"stats-interval" env:"STATS_INTERVAL" description:"Interval for dumping stats to file" default:"30s"`
+	BehindProxy          bool          `long:"behind-proxy" env:"BEHIND_PROXY" description:"Respect X-Real-Ip and X-Forwarded-For from any address (deprecated: use --trusted-proxy)"`
+	TrustedProxies       []string      `long:"trusted-proxy" env:"TRUSTED_PROXIES" env-delim:"," description:"IP or CIDR of proxy which X-Real-Ip and X-Forwarded-For are respected"`
+	StatsCache           uint          `long:"stats-cache" env:"STATS_CACHE" description:"Maximum cache for stats in legacy dump" default:"8192"`
+	StatsFile            string        `long:"stats-file" env:"STATS_FILE" description:"Legacy binary file for statistics dump (imported once to stats directory)" default:".stats"`
+	StatsDir             string        `long:"stats-dir" env:"STATS_DIR" description:"Directory for statistics log" default:".stats.d"`
+	StatsMaxAge          time.Duration `long:"stats-max-age" env:"STATS_MAX_AGE" description:"Maximum age of statistics records (0 means unlimited)" default:"720h"`
+	StatsMaxSize         int64         `long:"stats-max-size" env:"STATS_MAX_SIZE" description:"Maximum size of statistics log in MiB (0 means unlimited)" default:"256"`
+	StatsSummaryWindow   time.Duration `long:"stats-summary-window" env:"STATS_SUMMARY_WINDOW" description:"Maximum window for aggregated statistics" default:"24h"`
+	StatsInterval        time.Duration `long:"stats-interval" env:"STATS_INTERVAL" description:"Interval for flushing stats to disk and applying retention" default:"30s"`
 	SchedulerInterval    time.Duration `long:"scheduler-interval" env:"SCHEDULER_INTERVAL" description:"Interval to check cron records" default:"30s"`
+	Metrics              bool          `long:"metrics" env:"METRICS" description:"Expose Prometheus metrics on /metrics"`
+	MetricsToken         string        `long:"metrics-token" env:"METRICS_TOKEN" description:"Bearer token required to read metrics (if set)"`
+	TraceOTLP            string        `long:"trace-otlp" env:"TRACE_OTLP" description:"OTLP/HTTP collector URL for traces (ex: http://localhost:4318)"`
+	TraceFile            string        `long:"trace-file" env:"TRACE_FILE" description:"File for traces in JSON lines (used if OTLP collector not set)"`
+	TraceService         string        `long:"trace-service" env:"TRACE_SERVICE" description:"Service name in traces" default:"trusted-cgi"`
 }
 
 type HttpServer struct {
@@ -63,10 +81,34 @@ type Queues struct {
 	Depth     int    `long:"depth" env:"DEPTH" description:"Depth for in-memory queue" default:"100"`
 }
 
+type Topics struct {
+	Config string `long:"config" env:"CONFIG" description:"Path to topics configuration file" default:"topics.json"`
+}
+
 type Policies struct {
 	Config string `long:"config" env:"CONFIG" description:"Path to policies configuration file" default:"policies.json"`
 }
 
+// DeadLetters factory for failed requests: directory-based for directory queues and in-memory (nil) for others.
+func (q *Queues) DeadLetters() queuemanager.DeadLetterFactory {
+	if q.Kind != "directory" {
+		return nil
+	}
+	return func(name string) (queue.DeadLetters, error) {
+		return indir.NewDeadLetters(filepath.Join(q.Directory, ".dead-letters", name))
+	}
+}
+
+// Jobs factory for results of queues in store mode: directory-based for directory queues and in-memory (nil) for others.
+func (q *Queues) Jobs() queuemanager.JobsFactory {
+	if q.Kind != "directory" {
+		return nil
+	}
+	return func(name string) (queue.Jobs, error) {
+		return indir.NewJobs(filepath.Join(q.Directory, ".jobs", name))
+	}
+}
+
 func (q *Queues) Factory() (queuemanager.QueueFactory, error) {
 	switch q.Kind {
 	case "directory":
@@ -119,11 +161,54 @@ func main() {
 	}
 }
 
+func (cfg *Config) Tracer() (*tracing.Tracer, error) {
+	switch {
+	case cfg.TraceOTLP != "":
+		return tracing.New(tracing.NewOTLPExporter(cfg.TraceOTLP, cfg.TraceService, nil)), nil
+	case cfg.TraceFile != "":
+		exporter, err := tracing.NewFileExporter(cfg.TraceFile)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.New(exporter), nil
+	default:
+		return nil, nil
+	}
+}
+
+func (cfg *Config) Proxies() (types.Networks, error) {
+	if cfg.BehindProxy {
+		log.Println("--behind-proxy is deprecated and trusts forwarded headers from any address, use --trusted-proxy")
+		return types.ParseNetworks("0.0.0.0/0", "::/0")
+	}
+	proxies, err := types.ParseNetworks(cfg.TrustedProxies...)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxies: %w", err)
+	}
+	return proxies, nil
+}
+
 func run(ctx context.Context, config Config) error {
-	tracker, err := memlog.NewDumped(config.StatsFile, config.StatsCache)
+	tracer, err := config.Tracer()
+	if err != nil {
+		return err
+	}
+	proxies, err := config.Proxies()
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		defer tracer.Close()
+		ctx = tracing.WithTracer(ctx, tracer)
+	}
+	tracker, err := seglog.New(config.StatsDir, config.StatsMaxAge, config.StatsMaxSize*1024*1024)
 	if err != nil {
 		return err
 	}
+	defer tracker.Close()
+	if err := importStats(tracker, config.StatsFile, config.StatsCache); err != nil {
+		return err
+	}
 
 	var defCfg application.Config
 	defCfg.User = config.InitialChrootUser
@@ -149,11 +234,21 @@ func run(ctx context.Context, config Config) error {
 		return err
 	}
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(config.Queues.Config), basePlatform, queueFactory)
+	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(config.Queues.Config), basePlatform, queueFactory, config.Queues.DeadLetters(), config.Queues.Jobs())
+	if err != nil {
+		return err
+	}
+
+	topicManager, err := topicmanager.New(topicmanager.FileConfig(config.Topics.Config), queueManager)
 	if err != nil {
 		return err
 	}
 
+	collector := metrics.New()
+	collector.Queues(queueManager)
+	collector.Lambdas(basePlatform)
+	queueManager.Observe(collector)
+
 	useCases, err := cases.New(basePlatform, queueManager, policies, config.Dir, config.Templates)
 	if err != nil {
 		return err
@@ -166,34 +261,48 @@ func run(ctx context.Context, config Config) error {
 		}
 	}
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
+	aggregates := rollup.New(config.StatsSummaryWindow)
+	aggregates.Lambdas(basePlatform)
+	if err := aggregates.Import(tracker); err != nil {
+		return fmt.Errorf("restore stats aggregates: %w", err)
+	}
+
+	projectApi := services.NewProjectSrv(useCases, tracker, aggregates)
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, aggregates)
 	queuesApi := services.NewQueuesSrv(queueManager)
+	topicsApi := services.NewTopicsSrv(topicManager)
 	policiesApi := services.NewPoliciesSrv(policies)
 	userApi, err := services.CreateUserSrv(config.Config, config.InitialAdminPassword)
 	if err != nil {
 		return err
 	}
 
-	go runScheduler(ctx, config.SchedulerInterval, useCases)
+	go runScheduler(ctx, config.SchedulerInterval, useCases, collector.Scheduled)
 
 	defer tracker.Dump()
 	go dumpTracker(ctx, config.StatsInterval, tracker)
+	defer policies.Dump()
+	go dumpTracker(ctx, config.StatsInterval, policies)
 
 	srv := &server.Server{
-		Policies:     policies,
-		Platform:     basePlatform,
-		Cases:        useCases,
-		Queues:       queueManager,
-		Dev:          config.Dev,
-		BehindProxy:  config.BehindProxy,
-		Tracker:      tracker,
-		TokenHandler: userApi,
-		ProjectAPI:   projectApi,
-		LambdaAPI:    lambdaApi,
-		UserAPI:      userApi,
-		QueuesAPI:    queuesApi,
-		PoliciesAPI:  policiesApi,
+		Policies:       policies,
+		Platform:       basePlatform,
+		Cases:          useCases,
+		Queues:         queueManager,
+		Topics:         topicManager,
+		Dev:            config.Dev,
+		TrustedProxies: proxies,
+		Tracker:        stats.Multi{tracker, collector, aggregates},
+		TokenHandler:   userApi,
+		ProjectAPI:     projectApi,
+		LambdaAPI:      lambdaApi,
+		UserAPI:        userApi,
+		QueuesAPI:      queuesApi,
+		TopicsAPI:      topicsApi,
+		PoliciesAPI:    policiesApi,
+	}
+	if config.Metrics {
+		srv.Metrics = collector.Handler(config.MetricsToken)
 	}
 
 	handler := srv.Handler(ctx)
@@ -214,12 +323,27 @@ func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
 		}
 		err := tracker.Dump()
 		if err != nil {
-			log.Println("[ERROR] failed to dump statistics:", err)
+			log.Println("[ERROR] failed to dump:", err)
 		}
 	}
 }
 
-func runScheduler(ctx context.Context, each time.Duration, runner application.Cases) {
+// import records from legacy dump (if exists) and rename dump to prevent repeated import
+func importStats(tracker interface{ Import(stats.Reader) error }, dumpFile string, depth uint) error {
+	if _, err := os.Stat(dumpFile); os.IsNotExist(err) {
+		return nil
+	}
+	dump, err := memlog.NewDumped(dumpFile, depth)
+	if err != nil {
+		return fmt.Errorf("read legacy stats: %w", err)
+	}
+	if err := tracker.Import(dump); err != nil {
+		return fmt.Errorf("import legacy stats: %w", err)
+	}
+	return os.Rename(dumpFile, dumpFile+".imported")
+}
+
+func runScheduler(ctx context.Context, each time.Duration, runner application.Cases, report func([]types.ScheduleResult)) {
 	t := time.NewTicker(each)
 	defer t.Stop()
 	for {
@@ -228,6 +352,6 @@ func runScheduler(ctx context.Context, each time.Duration, runner application.Ca
 		case <-ctx.Done():
 			return
 		}
-		runner.RunScheduledActions(ctx)
+		report(runner.RunScheduledActions(ctx))
 	}
 }
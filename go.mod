@@ -6,8 +6,8 @@ require (
 	github.com/alecthomas/units v0.0.0-20231202071711-9a357b53e9c9
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/google/uuid v1.5.0
+	github.com/gorilla/websocket v1.5.1
 	github.com/jessevdk/go-flags v1.5.0
-	github.com/reddec/dfq v0.0.0-20200905054932-718696ac508f
 	github.com/reddec/jsonrpc2 v0.1.21
 	github.com/robfig/cron v1.2.0
 	github.com/stretchr/testify v1.5.1
@@ -17,7 +17,6 @@ require (
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/gorilla/websocket v1.5.1 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/net v0.19.0 // indirect
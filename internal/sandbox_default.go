@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func SetSandbox(cmd *exec.Cmd, sandbox *types.Sandbox, dir string) (func(), error) {
+	if sandbox == nil {
+		return func() {}, nil
+	}
+	return nil, fmt.Errorf("sandbox is supported only on linux")
+}
@@ -0,0 +1,381 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const sandboxInitName = "trusted-cgi-sandbox-init" // argv[0] for re-executed binary to initialize sandbox
+
+// Root of cgroup (v2) hierarchy for sandboxed lambdas. Should be writable and delegated to the server.
+var CgroupRoot = "/sys/fs/cgroup/trusted-cgi"
+
+// Sandbox configuration passed to the re-executed binary
+type sandboxSpec struct {
+	Root    string   `json:"root"`              // empty directory for new root
+	Dir     string   `json:"dir"`               // lambda directory (writable)
+	Hide    []string `json:"hide,omitempty"`    // directories to replace by empty tmpfs
+	Network bool     `json:"network,omitempty"` // host network is available
+	Creds   bool     `json:"creds,omitempty"`   // drop privileges to UID and GID
+	UID     int      `json:"uid,omitempty"`
+	GID     int      `json:"gid,omitempty"`
+	Path    string   `json:"path"` // executable
+}
+
+func init() {
+	if len(os.Args) < 2 || os.Args[0] != sandboxInitName {
+		return
+	}
+	var spec sandboxSpec
+	err := json.Unmarshal([]byte(os.Args[1]), &spec)
+	if err == nil {
+		err = spec.enter(os.Args[2:])
+	}
+	// exec never returns on success
+	_, _ = fmt.Fprintln(os.Stderr, "sandbox:", err)
+	os.Exit(126)
+}
+
+// Run command in isolated mount, PID, IPC, UTS and (optionally) network namespaces with read-only root file system.
+// Lambda directory is writable, the parent directory (project) is hidden, /tmp is private.
+// All capabilities are dropped and no_new_privs is set before exec, so mounts can't be changed by lambda
+// even if it runs as root.
+// Resources are limited by cgroup v2 (if limits are set). Should be called after all other command preparations.
+// Returned function should be called after process finish to release resources.
+func SetSandbox(cmd *exec.Cmd, sandbox *types.Sandbox, dir string) (func(), error) {
+	if sandbox == nil {
+		return func() {}, nil
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve lambda dir: %w", err)
+	}
+	root, err := os.MkdirTemp("", "trusted-cgi-sandbox-*")
+	if err != nil {
+		return nil, fmt.Errorf("create sandbox root: %w", err)
+	}
+	spec := sandboxSpec{
+		Root:    root,
+		Dir:     dir,
+		Network: sandbox.Network,
+		Path:    cmd.Path,
+	}
+	if project := filepath.Dir(dir); project != "/" {
+		spec.Hide = append(spec.Hide, project) // other lambdas and platform configuration
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// mounts require privileges - credentials will be dropped after sandbox initialization
+	if creds := cmd.SysProcAttr.Credential; creds != nil {
+		spec.Creds = true
+		spec.UID = int(creds.Uid)
+		spec.GID = int(creds.Gid)
+		cmd.SysProcAttr.Credential = nil
+	}
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		_ = os.Remove(root)
+		return nil, err
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !sandbox.Network {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	cmd.Args = append([]string{sandboxInitName, string(encoded)}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+
+	if !sandbox.Limits() {
+		return func() { _ = os.Remove(root) }, nil
+	}
+
+	group, err := createCgroup(sandbox)
+	if err != nil {
+		_ = os.Remove(root)
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(group.Fd())
+	return func() {
+		_ = os.Remove(root)
+		removeCgroup(group)
+	}, nil
+}
+
+func createCgroup(sandbox *types.Sandbox) (*os.File, error) {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available: %w", err)
+	}
+	if err := os.MkdirAll(CgroupRoot, 0755); err != nil {
+		return nil, err
+	}
+	// best-effort: controllers could be already enabled by delegation
+	for _, parent := range []string{filepath.Dir(CgroupRoot), CgroupRoot} {
+		for _, controller := range []string{"+memory", "+cpu", "+pids"} {
+			_ = os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(controller), 0)
+		}
+	}
+	dir, err := os.MkdirTemp(CgroupRoot, "lambda-")
+	if err != nil {
+		return nil, err
+	}
+	limits := map[string]string{}
+	if sandbox.Memory > 0 {
+		limits["memory.max"] = strconv.FormatInt(sandbox.Memory, 10)
+		limits["memory.swap.max"] = "0"
+	}
+	if sandbox.CPU > 0 {
+		const period = 100000
+		limits["cpu.max"] = strconv.Itoa(int(sandbox.CPU*period)) + " " + strconv.Itoa(period)
+	}
+	if sandbox.Pids > 0 {
+		limits["pids.max"] = strconv.Itoa(sandbox.Pids)
+	}
+	for file, value := range limits {
+		err = os.WriteFile(filepath.Join(dir, file), []byte(value), 0)
+		if err != nil && file != "memory.swap.max" { // swap accounting could be disabled
+			_ = os.Remove(dir)
+			return nil, fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		_ = os.Remove(dir)
+		return nil, err
+	}
+	return f, nil
+}
+
+func removeCgroup(group *os.File) {
+	dir := group.Name()
+	_ = group.Close()
+	// kill all remaining processes (forked by lambda) and wait for release
+	_ = os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+	for i := 0; i < 50; i++ {
+		if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// prepare file system and exec the command; executed in the new namespaces as PID 1
+func (spec *sandboxSpec) enter(args []string) error {
+	// capabilities and no_new_privs are per-thread attributes, exec should be called from the same thread
+	runtime.LockOSThread()
+	root := spec.Root
+	// do not propagate anything to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := syscall.Mount("/", root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind root: %w", err)
+	}
+	if err := remountReadOnly(root); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", filepath.Join(root, "tmp"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount tmp: %w", err)
+	}
+	for _, dir := range spec.Hide {
+		_ = os.MkdirAll(filepath.Join(root, dir), 0755) // could be hidden by tmp
+		if err := syscall.Mount("tmpfs", filepath.Join(root, dir), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+			return fmt.Errorf("hide %s: %w", dir, err)
+		}
+	}
+	lambdaDir := filepath.Join(root, spec.Dir)
+	if err := os.MkdirAll(lambdaDir, 0755); err != nil {
+		return fmt.Errorf("create mount point for lambda: %w", err)
+	}
+	if err := syscall.Mount(spec.Dir, lambdaDir, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind lambda dir: %w", err)
+	}
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount proc: %w", err)
+	}
+	// switch root
+	if err := syscall.Chdir(root); err != nil {
+		return err
+	}
+	if err := syscall.Mount(root, "/", "", syscall.MS_MOVE, ""); err != nil {
+		return fmt.Errorf("move root: %w", err)
+	}
+	if err := syscall.Chroot("."); err != nil {
+		return fmt.Errorf("chroot: %w", err)
+	}
+	if !spec.Network {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("setup loopback: %w", err)
+		}
+	}
+	// even without credentials lambda should not be able to undo mounts: drop all capabilities before exec
+	if err := dropBoundingSet(); err != nil {
+		return fmt.Errorf("drop capabilities bounding set: %w", err)
+	}
+	if spec.Creds {
+		if err := syscall.Setgroups(nil); err != nil {
+			return fmt.Errorf("set groups: %w", err)
+		}
+		if err := syscall.Setgid(spec.GID); err != nil {
+			return fmt.Errorf("set gid: %w", err)
+		}
+		if err := syscall.Setuid(spec.UID); err != nil {
+			return fmt.Errorf("set uid: %w", err)
+		}
+	}
+	if err := syscall.Chdir(spec.Dir); err != nil {
+		return fmt.Errorf("change dir: %w", err)
+	}
+	if err := dropCapabilities(); err != nil {
+		return fmt.Errorf("drop capabilities: %w", err)
+	}
+	if err := prctl(prSetNoNewPrivs, 1); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	return syscall.Exec(spec.Path, args, os.Environ())
+}
+
+const (
+	prCapBSetDrop         = 24 // PR_CAPBSET_DROP
+	prSetNoNewPrivs       = 38 // PR_SET_NO_NEW_PRIVS
+	prCapAmbient          = 47 // PR_CAP_AMBIENT
+	prCapAmbientClearAll  = 4  // PR_CAP_AMBIENT_CLEAR_ALL
+	linuxCapabilityV3     = 0x20080522
+	defaultLastCapability = 40 // CAP_CHECKPOINT_RESTORE, used if /proc/sys/kernel/cap_last_cap is not readable
+)
+
+func prctl(option uintptr, arg uintptr) error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, option, arg, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// remove all capabilities from bounding set, so root (or set-user-ID binary) gets nothing after exec
+func dropBoundingSet() error {
+	last := defaultLastCapability
+	if data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+			last = v
+		}
+	}
+	for capability := 0; capability <= last; capability++ {
+		if err := prctl(prCapBSetDrop, uintptr(capability)); err != nil && err != syscall.EINVAL {
+			return fmt.Errorf("capability %d: %w", capability, err)
+		}
+	}
+	return nil
+}
+
+// clear effective, permitted, inheritable and ambient capabilities of the current thread
+func dropCapabilities() error {
+	if err := prctl(prCapAmbient, prCapAmbientClearAll); err != nil && err != syscall.EINVAL {
+		return fmt.Errorf("clear ambient: %w", err)
+	}
+	header := struct {
+		version uint32
+		pid     int32
+	}{version: linuxCapabilityV3}
+	var data [2]struct {
+		effective   uint32
+		permitted   uint32
+		inheritable uint32
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// remount all mounts under root as read-only except pseudo file systems (/dev, /proc)
+func remountReadOnly(root string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		point := unescapeMountPoint(fields[4])
+		rel, err := filepath.Rel(root, point)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rel == "dev" || strings.HasPrefix(rel, "dev/") || rel == "proc" || strings.HasPrefix(rel, "proc/") {
+			continue
+		}
+		mounts = append(mounts, point)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, point := range mounts {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(point, &stat); err != nil {
+			return fmt.Errorf("stat %s: %w", point, err)
+		}
+		// locked flags should be preserved
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		flags |= uintptr(stat.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME)
+		if err := syscall.Mount("", point, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s as read-only: %w", point, err)
+		}
+	}
+	return nil
+}
+
+func unescapeMountPoint(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) {
+			if v, err := strconv.ParseUint(value[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(value[i])
+	}
+	return out.String()
+}
+
+// bring up loopback interface in the new network namespace
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(req.name[:], "lo")
+	req.flags = syscall.IFF_UP | syscall.IFF_LOOPBACK | syscall.IFF_RUNNING
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package indir
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	letterExt = ".letter" // header of letter
+	bodyExt   = ".body"   // request body
+)
+
+// NewDeadLetters opens (or creates) directory-based storage of dead letters. Each letter is stored as two files:
+// header (in msgp) and request body. Header is written last, so letter without header is not visible.
+func NewDeadLetters(directory string) (*deadLetters, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	list, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	var count int64
+	for _, info := range list {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), letterExt) {
+			count++
+		}
+	}
+	return &deadLetters{directory: directory, count: count}, nil
+}
+
+type deadLetters struct {
+	directory string
+	count     int64
+}
+
+func (dl *deadLetters) Put(letter *types.DeadLetter, body io.Reader) error {
+	letter.ID = uuid.New().String()
+	size, err := dl.write(dl.file(letter.ID, bodyExt), func(out io.Writer) error {
+		_, err := io.Copy(out, body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("save dead letter body: %w", err)
+	}
+	letter.Size = size
+	_, err = dl.write(dl.file(letter.ID, letterExt), func(out io.Writer) error {
+		w := msgp.NewWriter(out)
+		if err := letter.EncodeMsg(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		_ = os.Remove(dl.file(letter.ID, bodyExt))
+		return fmt.Errorf("save dead letter: %w", err)
+	}
+	atomic.AddInt64(&dl.count, 1)
+	return nil
+}
+
+func (dl *deadLetters) List() ([]types.DeadLetter, error) {
+	list, err := ioutil.ReadDir(dl.directory)
+	if err != nil {
+		return nil, err
+	}
+	var ans = make([]types.DeadLetter, 0, len(list))
+	for _, info := range list {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), letterExt) {
+			continue
+		}
+		letter, err := dl.header(strings.TrimSuffix(info.Name(), letterExt))
+		if os.IsNotExist(err) {
+			continue // removed concurrently
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read dead letter %s: %w", info.Name(), err)
+		}
+		ans = append(ans, *letter)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Failed.Before(ans[j].Failed)
+	})
+	return ans, nil
+}
+
+func (dl *deadLetters) Get(id string) (*types.DeadLetter, io.ReadCloser, error) {
+	if !validID(id) {
+		return nil, nil, fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	letter, err := dl.header(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := os.Open(dl.file(id, bodyExt))
+	if err != nil {
+		return nil, nil, err
+	}
+	return letter, body, nil
+}
+
+func (dl *deadLetters) Remove(id string) error {
+	if !validID(id) {
+		return fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	if err := os.Remove(dl.file(id, letterExt)); err != nil {
+		return err
+	}
+	atomic.AddInt64(&dl.count, -1)
+	return os.Remove(dl.file(id, bodyExt))
+}
+
+func (dl *deadLetters) Len() int64 {
+	return atomic.LoadInt64(&dl.count)
+}
+
+func (dl *deadLetters) Destroy() error {
+	return os.RemoveAll(dl.directory)
+}
+
+func (dl *deadLetters) header(id string) (*types.DeadLetter, error) {
+	f, err := os.Open(dl.file(id, letterExt))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var letter types.DeadLetter
+	return &letter, letter.DecodeMsg(msgp.NewReader(f))
+}
+
+func (dl *deadLetters) write(filename string, handler func(out io.Writer) error) (int64, error) {
+	return writeAtomic(dl.directory, filename, handler)
+}
+
+// write file atomically through temporary file in directory and returns number of written bytes
+func writeAtomic(directory, filename string, handler func(out io.Writer) error) (int64, error) {
+	tmp, err := ioutil.TempFile(directory, ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	counter := &countingWriter{writer: tmp}
+	if err := handler(counter); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return counter.written, os.Rename(tmp.Name(), filename)
+}
+
+func (dl *deadLetters) file(id string, ext string) string {
+	return filepath.Join(dl.directory, id+ext)
+}
+
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.writer.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
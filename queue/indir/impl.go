@@ -2,42 +2,123 @@ package indir
 
 import (
 	"context"
-	"github.com/reddec/dfq"
-	"github.com/reddec/trusted-cgi/types"
-	"github.com/tinylib/msgp/msgp"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/reddec/trusted-cgi/types"
 )
 
+const (
+	dataSuffix      = ".data"
+	tempSuffix      = ".temp"
+	corruptedSuffix = ".corrupted" // files which could not be restored
+)
+
+// New directory-based queue, where one file is one request: header (in msgp) followed by body.
+// Files are named by sequence number, so order is kept after restart. Leases are not persisted: after restart
+// all not acknowledged requests are available again.
 func New(directory string) (*inDirQueue, error) {
-	back, err := dfq.Open(directory)
+	err := os.MkdirAll(directory, 0755)
 	if err != nil {
 		return nil, err
 	}
-	return &inDirQueue{backend: back}, nil
+	q := &inDirQueue{
+		directory: directory,
+		leased:    make(map[uint64]bool),
+		sizes:     make(map[uint64]int64),
+		delayed:   make(map[uint64]time.Time),
+		notify:    make(chan struct{}),
+	}
+	return q, q.restore()
 }
 
 type inDirQueue struct {
-	backend dfq.Queue
+	directory string
+	lock      sync.Mutex
+	next      uint64               // sequence number for the next request
+	pending   []uint64             // available requests ordered by sequence number
+	leased    map[uint64]bool      // leased but not yet acknowledged requests
+	sizes     map[uint64]int64     // file sizes of stored requests
+	size      int64                // total size of stored requests
+	delayed   map[uint64]time.Time // delivery time of pending delayed requests
+	notify    chan struct{}        // closed (and replaced) after new request available
 }
 
 func (queue *inDirQueue) Put(ctx context.Context, request *types.Request) error {
 	defer request.Body.Close()
-	return queue.backend.Stream(func(out io.Writer) error {
-		w := msgp.NewWriter(out)
-		err := request.EncodeMsg(w)
-		if err != nil {
-			return err
+	tmp, err := ioutil.TempFile(queue.directory, "*"+tempSuffix)
+	if err != nil {
+		return fmt.Errorf("put: create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	counter := &countingWriter{writer: tmp}
+	w := msgp.NewWriter(counter)
+	err = request.EncodeMsg(w)
+	if err != nil {
+		return fmt.Errorf("put: write header: %w", err)
+	}
+	_, err = io.Copy(w, request.Body)
+	if err != nil {
+		return fmt.Errorf("put: write body: %w", err)
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("put: write: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("put: close temp file: %w", err)
+	}
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	id := queue.next
+	err = os.Rename(tmp.Name(), queue.file(id))
+	if err != nil {
+		return fmt.Errorf("put: attach file to queue: %w", err)
+	}
+	queue.next++
+	queue.pending = append(queue.pending, id)
+	queue.sizes[id] = counter.written
+	queue.size += counter.written
+	if request.DeliverAt != nil {
+		queue.delayed[id] = *request.DeliverAt
+	}
+	queue.notifyUnsafe()
+	return nil
+}
+
+func (queue *inDirQueue) Lease(ctx context.Context) (uint64, error) {
+	for {
+		queue.lock.Lock()
+		id, ok, delay := queue.pickUnsafe(time.Now())
+		if ok {
+			queue.leased[id] = true
+			queue.lock.Unlock()
+			return id, nil
 		}
-		_, err = io.Copy(w, request.Body)
-		if err != nil {
-			return err
+		notify := queue.notify
+		queue.lock.Unlock()
+		if err := wait(ctx, notify, delay); err != nil {
+			return 0, err
 		}
-		return w.Flush()
-	})
+	}
 }
 
-func (queue *inDirQueue) Peek(ctx context.Context) (*types.Request, error) {
-	in, err := queue.backend.Wait(ctx)
+func (queue *inDirQueue) Open(id uint64) (*types.Request, error) {
+	in, err := os.Open(queue.file(id))
 	if err != nil {
 		return nil, err
 	}
@@ -51,12 +132,239 @@ func (queue *inDirQueue) Peek(ctx context.Context) (*types.Request, error) {
 	return head.WithBody(&readCloser{reader: reader.R, closer: in}), nil
 }
 
-func (queue *inDirQueue) Commit(ctx context.Context) error {
-	return queue.backend.Commit()
+func (queue *inDirQueue) Ack(ctx context.Context, id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if !queue.leased[id] {
+		return fmt.Errorf("ack: request %d is not leased", id)
+	}
+	delete(queue.leased, id)
+	return queue.removeUnsafe(id)
+}
+
+func (queue *inDirQueue) Release(ctx context.Context, id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if !queue.leased[id] {
+		return fmt.Errorf("release: request %d is not leased", id)
+	}
+	delete(queue.leased, id)
+	// keep order: released request is usually the oldest one
+	idx := sort.Search(len(queue.pending), func(i int) bool { return queue.pending[i] > id })
+	queue.pending = append(queue.pending, 0)
+	copy(queue.pending[idx+1:], queue.pending[idx:])
+	queue.pending[idx] = id
+	queue.notifyUnsafe()
+	return nil
+}
+
+func (queue *inDirQueue) Len() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return int64(len(queue.pending) + len(queue.leased))
+}
+
+func (queue *inDirQueue) InFlight() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return int64(len(queue.leased))
+}
+
+func (queue *inDirQueue) Size() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.size
+}
+
+func (queue *inDirQueue) List(limit int) ([]types.QueueItem, error) {
+	queue.lock.Lock()
+	var ids = make([]uint64, 0, len(queue.pending)+len(queue.leased))
+	for id := range queue.leased {
+		ids = append(ids, id)
+	}
+	ids = append(ids, queue.pending...)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	var ans = make([]types.QueueItem, 0, len(ids))
+	for _, id := range ids {
+		ans = append(ans, types.QueueItem{ID: id, Leased: queue.leased[id], Size: queue.sizes[id]})
+	}
+	queue.lock.Unlock()
+
+	var items = ans[:0]
+	for _, item := range ans {
+		req, err := queue.Open(item.ID)
+		if os.IsNotExist(err) {
+			continue // acknowledged concurrently
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list: open request %d: %w", item.ID, err)
+		}
+		_ = req.Body.Close()
+		req.Body = nil
+		item.Request = *req
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (queue *inDirQueue) Delete(id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.leased[id] {
+		return fmt.Errorf("delete: request %d is in-flight", id)
+	}
+	idx := sort.Search(len(queue.pending), func(i int) bool { return queue.pending[i] >= id })
+	if idx == len(queue.pending) || queue.pending[idx] != id {
+		return fmt.Errorf("delete: request %d: %w", id, os.ErrNotExist)
+	}
+	if err := queue.removeUnsafe(id); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	queue.pending = append(queue.pending[:idx], queue.pending[idx+1:]...)
+	return nil
+}
+
+func (queue *inDirQueue) Purge() (int, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for i, id := range queue.pending {
+		if err := queue.removeUnsafe(id); err != nil {
+			queue.pending = queue.pending[i:]
+			return i, fmt.Errorf("purge: %w", err)
+		}
+	}
+	count := len(queue.pending)
+	queue.pending = nil
+	return count, nil
 }
 
 func (queue *inDirQueue) Destroy() error {
-	return queue.backend.Destroy()
+	return os.RemoveAll(queue.directory)
+}
+
+// pick (and remove from pending list) the oldest request which delivery time has come. If there is no such request,
+// returns delay till the nearest delayed request or zero if queue is empty.
+func (queue *inDirQueue) pickUnsafe(now time.Time) (uint64, bool, time.Duration) {
+	var delay time.Duration
+	for i, id := range queue.pending {
+		at, delayed := queue.delayed[id]
+		if !delayed || !at.After(now) {
+			if i == 0 {
+				queue.pending = queue.pending[1:]
+			} else {
+				queue.pending = append(queue.pending[:i], queue.pending[i+1:]...)
+			}
+			delete(queue.delayed, id)
+			return id, true, 0
+		}
+		if d := at.Sub(now); delay == 0 || d < delay {
+			delay = d
+		}
+	}
+	return 0, false, delay
+}
+
+// remove file of request which is already detached from pending and leased lists
+func (queue *inDirQueue) removeUnsafe(id uint64) error {
+	err := os.Remove(queue.file(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	queue.size -= queue.sizes[id]
+	delete(queue.sizes, id)
+	delete(queue.delayed, id)
+	return nil
+}
+
+// wake up all waiting consumers
+func (queue *inDirQueue) notifyUnsafe() {
+	close(queue.notify)
+	queue.notify = make(chan struct{})
+}
+
+func (queue *inDirQueue) file(id uint64) string {
+	return filepath.Join(queue.directory, strconv.FormatUint(id, 10)+dataSuffix)
+}
+
+// restore state from directory and remove not finished writes
+func (queue *inDirQueue) restore() error {
+	list, err := ioutil.ReadDir(queue.directory)
+	if err != nil {
+		return err
+	}
+	for _, file := range list {
+		name := file.Name()
+		switch {
+		case strings.HasSuffix(name, dataSuffix):
+			id, err := strconv.ParseUint(strings.TrimSuffix(name, dataSuffix), 10, 64)
+			if err != nil {
+				queue.quarantine(filepath.Join(queue.directory, name), fmt.Errorf("invalid file name: %w", err))
+				continue
+			}
+			queue.pending = append(queue.pending, id)
+			queue.sizes[id] = file.Size()
+			queue.size += file.Size()
+			if id >= queue.next {
+				queue.next = id + 1
+			}
+		case strings.HasSuffix(name, tempSuffix):
+			err = os.Remove(filepath.Join(queue.directory, name))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	sort.Slice(queue.pending, func(i, j int) bool {
+		return queue.pending[i] < queue.pending[j]
+	})
+	now := time.Now()
+	var valid = queue.pending[:0]
+	for _, id := range queue.pending {
+		req, err := queue.Open(id)
+		if err != nil {
+			queue.size -= queue.sizes[id]
+			delete(queue.sizes, id)
+			queue.quarantine(queue.file(id), err)
+			continue
+		}
+		_ = req.Body.Close()
+		valid = append(valid, id)
+		if req.DeliverAt != nil && req.DeliverAt.After(now) {
+			queue.delayed[id] = *req.DeliverAt
+		}
+	}
+	queue.pending = valid
+	return nil
+}
+
+// rename broken file, so it will be ignored by queue but still available for investigation
+func (queue *inDirQueue) quarantine(file string, reason error) {
+	log.Println("indir: skip corrupted file", file, ":", reason)
+	if err := os.Rename(file, file+corruptedSuffix); err != nil {
+		log.Println("indir: failed quarantine file", file, ":", err)
+	}
+}
+
+// wait till notification, delay expiration (if positive) or context cancellation
+func wait(ctx context.Context, notify <-chan struct{}, delay time.Duration) error {
+	var due <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		due = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-notify:
+	case <-due:
+	}
+	return nil
 }
 
 type readCloser struct {
@@ -0,0 +1,95 @@
+package indir
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const jobExt = ".job"
+
+// NewJobs opens (or creates) directory-based storage of jobs. Each job (with result) is stored as one file in msgp.
+func NewJobs(directory string) (*jobs, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &jobs{directory: directory}, nil
+}
+
+type jobs struct {
+	directory string
+}
+
+func (js *jobs) Save(job *types.Job) error {
+	if !validID(job.ID) {
+		return fmt.Errorf("save job: invalid ID %s", job.ID)
+	}
+	_, err := writeAtomic(js.directory, js.file(job.ID), func(out io.Writer) error {
+		w := msgp.NewWriter(out)
+		if err := job.EncodeMsg(w); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+	if err != nil {
+		return fmt.Errorf("save job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (js *jobs) Get(id string) (*types.Job, error) {
+	if !validID(id) {
+		return nil, fmt.Errorf("job %s: %w", id, os.ErrNotExist)
+	}
+	f, err := os.Open(js.file(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var job types.Job
+	return &job, job.DecodeMsg(msgp.NewReader(f))
+}
+
+func (js *jobs) Expire(before time.Time) (int, error) {
+	list, err := ioutil.ReadDir(js.directory)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for _, info := range list {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), jobExt) || !info.ModTime().Before(before) {
+			continue
+		}
+		job, err := js.Get(strings.TrimSuffix(info.Name(), jobExt))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("read job %s: %w", info.Name(), err)
+		}
+		if !job.Finished() || !job.Updated.Before(before) {
+			continue
+		}
+		if err := os.Remove(js.file(job.ID)); err != nil && !os.IsNotExist(err) {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (js *jobs) Destroy() error {
+	return os.RemoveAll(js.directory)
+}
+
+func (js *jobs) file(id string) string {
+	return filepath.Join(js.directory, id+jobExt)
+}
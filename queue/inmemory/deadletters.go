@@ -0,0 +1,90 @@
+package inmemory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// NewDeadLetters creates in-memory storage of dead letters. Letters are lost after restart.
+func NewDeadLetters() *deadLetters {
+	return &deadLetters{letters: make(map[string]*letter)}
+}
+
+type letter struct {
+	header types.DeadLetter
+	data   []byte
+}
+
+type deadLetters struct {
+	lock    sync.RWMutex
+	letters map[string]*letter
+}
+
+func (dl *deadLetters) Put(header *types.DeadLetter, body io.Reader) error {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("put: read body: %w", err)
+	}
+	header.ID = uuid.New().String()
+	header.Size = int64(len(data))
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	dl.letters[header.ID] = &letter{header: *header, data: data}
+	return nil
+}
+
+func (dl *deadLetters) List() ([]types.DeadLetter, error) {
+	dl.lock.RLock()
+	var ans = make([]types.DeadLetter, 0, len(dl.letters))
+	for _, item := range dl.letters {
+		ans = append(ans, item.header)
+	}
+	dl.lock.RUnlock()
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Failed.Before(ans[j].Failed)
+	})
+	return ans, nil
+}
+
+func (dl *deadLetters) Get(id string) (*types.DeadLetter, io.ReadCloser, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+	item, ok := dl.letters[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	header := item.header
+	return &header, ioutil.NopCloser(bytes.NewReader(item.data)), nil
+}
+
+func (dl *deadLetters) Remove(id string) error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	if _, ok := dl.letters[id]; !ok {
+		return fmt.Errorf("dead letter %s: %w", id, os.ErrNotExist)
+	}
+	delete(dl.letters, id)
+	return nil
+}
+
+func (dl *deadLetters) Len() int64 {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+	return int64(len(dl.letters))
+}
+
+func (dl *deadLetters) Destroy() error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+	dl.letters = make(map[string]*letter)
+	return nil
+}
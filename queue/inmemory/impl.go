@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"github.com/reddec/trusted-cgi/types"
 	"io/ioutil"
+	"os"
+	"sort"
 	"sync"
-	"sync/atomic"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
 )
 
 type item struct {
 	payload types.Request
 	data    []byte
+	size    int64
 }
 
 func (it *item) makeRequest() *types.Request {
@@ -21,90 +25,225 @@ func (it *item) makeRequest() *types.Request {
 	return &cp
 }
 
-// Dummy implementation of in-memory queue with pre-allocated channel for buffering.
+// Dummy implementation of in-memory queue with limited size: Put waits for free space.
 // It's safe to call Close several times
 func New(size int) *memoryQueue {
+	if size < 1 {
+		size = 1
+	}
 	return &memoryQueue{
-		closed: make(chan struct{}),
-		stream: make(chan item, size),
+		size:    size,
+		closed:  make(chan struct{}),
+		changed: make(chan struct{}),
+		items:   make(map[uint64]*item),
+		leased:  make(map[uint64]bool),
 	}
 }
 
 type memoryQueue struct {
-	closed chan struct{}
-	stream chan item
-	peeked struct {
-		value     item
-		available bool
-	}
-	rlock   sync.Mutex
-	closing int32
+	size    int
+	closed  chan struct{}
+	lock    sync.Mutex
+	changed chan struct{} // closed (and replaced) after any change of queue
+	next    uint64
+	items   map[uint64]*item
+	pending []uint64 // available items ordered by ID
+	leased  map[uint64]bool
 }
 
 func (queue *memoryQueue) Put(ctx context.Context, request *types.Request) error {
 	defer request.Body.Close()
-	select {
-	case <-queue.closed:
-		return fmt.Errorf("put: queue is closed")
-	default:
-	}
 	data, err := ioutil.ReadAll(request.Body)
 	if err != nil {
 		return fmt.Errorf("put: read body: %w", err)
 	}
-	select {
-	case <-queue.closed:
-		return fmt.Errorf("put: queue is closed")
-	case <-ctx.Done():
-		return fmt.Errorf("put: context closed: %w", ctx.Err())
-	case queue.stream <- item{
-		payload: *request,
-		data:    data,
-	}:
-		return nil
+	for {
+		queue.lock.Lock()
+		select {
+		case <-queue.closed:
+			queue.lock.Unlock()
+			return fmt.Errorf("put: queue is closed")
+		default:
+		}
+		if len(queue.items) < queue.size {
+			id := queue.next
+			queue.next++
+			queue.items[id] = &item{payload: *request, data: data, size: int64(len(data) + request.Msgsize())}
+			queue.pending = append(queue.pending, id)
+			queue.notifyUnsafe()
+			queue.lock.Unlock()
+			return nil
+		}
+		changed := queue.changed
+		queue.lock.Unlock()
+		select {
+		case <-queue.closed:
+			return fmt.Errorf("put: queue is closed")
+		case <-ctx.Done():
+			return fmt.Errorf("put: context closed: %w", ctx.Err())
+		case <-changed:
+		}
 	}
 }
 
-func (queue *memoryQueue) Peek(ctx context.Context) (*types.Request, error) {
-	select {
-	case <-queue.closed:
-		return nil, fmt.Errorf("peek: queue is closed")
-	default:
-	}
-	queue.rlock.Lock()
-	defer queue.rlock.Unlock()
-	if !queue.peeked.available {
+func (queue *memoryQueue) Lease(ctx context.Context) (uint64, error) {
+	for {
+		queue.lock.Lock()
+		select {
+		case <-queue.closed:
+			queue.lock.Unlock()
+			return 0, fmt.Errorf("lease: queue is closed")
+		default:
+		}
+		id, ok, delay := queue.pickUnsafe(time.Now())
+		if ok {
+			queue.leased[id] = true
+			queue.lock.Unlock()
+			return id, nil
+		}
+		changed := queue.changed
+		queue.lock.Unlock()
+		var due <-chan time.Time
+		var timer *time.Timer
+		if delay > 0 {
+			timer = time.NewTimer(delay)
+			due = timer.C
+		}
 		select {
 		case <-queue.closed:
-			return nil, fmt.Errorf("peek: queue is closed")
 		case <-ctx.Done():
-			return nil, fmt.Errorf("peek: context closed: %w", ctx.Err())
-		case item := <-queue.stream:
-			queue.peeked.value = item
-			queue.peeked.available = true
+		case <-changed:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("lease: context closed: %w", ctx.Err())
 		}
 	}
-	return queue.peeked.value.makeRequest(), nil
 }
 
-func (queue *memoryQueue) Commit(ctx context.Context) error {
-	select {
-	case <-queue.closed:
-		return fmt.Errorf("commit: queue is closed")
-	default:
+func (queue *memoryQueue) Open(id uint64) (*types.Request, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	it, ok := queue.items[id]
+	if !ok {
+		return nil, fmt.Errorf("open: request %d does not exist", id)
+	}
+	return it.makeRequest(), nil
+}
+
+func (queue *memoryQueue) Ack(ctx context.Context, id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if !queue.leased[id] {
+		return fmt.Errorf("ack: request %d is not leased", id)
+	}
+	delete(queue.leased, id)
+	delete(queue.items, id)
+	queue.notifyUnsafe()
+	return nil
+}
+
+func (queue *memoryQueue) Release(ctx context.Context, id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if !queue.leased[id] {
+		return fmt.Errorf("release: request %d is not leased", id)
 	}
-	queue.rlock.Lock()
-	defer queue.rlock.Unlock()
-	queue.peeked.available = false
+	delete(queue.leased, id)
+	idx := sort.Search(len(queue.pending), func(i int) bool { return queue.pending[i] > id })
+	queue.pending = append(queue.pending, 0)
+	copy(queue.pending[idx+1:], queue.pending[idx:])
+	queue.pending[idx] = id
+	queue.notifyUnsafe()
 	return nil
 }
 
+func (queue *memoryQueue) Len() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return int64(len(queue.items))
+}
+
+func (queue *memoryQueue) InFlight() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return int64(len(queue.leased))
+}
+
+// Size of stored requests. Size of headers is estimated.
+func (queue *memoryQueue) Size() int64 {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	var size int64
+	for _, it := range queue.items {
+		size += it.size
+	}
+	return size
+}
+
+func (queue *memoryQueue) List(limit int) ([]types.QueueItem, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	var ids = make([]uint64, 0, len(queue.items))
+	for id := range queue.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	var ans = make([]types.QueueItem, 0, len(ids))
+	for _, id := range ids {
+		it := queue.items[id]
+		req := it.payload
+		req.Body = nil
+		ans = append(ans, types.QueueItem{ID: id, Leased: queue.leased[id], Size: it.size, Request: req})
+	}
+	return ans, nil
+}
+
+func (queue *memoryQueue) Delete(id uint64) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.leased[id] {
+		return fmt.Errorf("delete: request %d is in-flight", id)
+	}
+	idx := sort.Search(len(queue.pending), func(i int) bool { return queue.pending[i] >= id })
+	if idx == len(queue.pending) || queue.pending[idx] != id {
+		return fmt.Errorf("delete: request %d: %w", id, os.ErrNotExist)
+	}
+	queue.pending = append(queue.pending[:idx], queue.pending[idx+1:]...)
+	delete(queue.items, id)
+	queue.notifyUnsafe()
+	return nil
+}
+
+func (queue *memoryQueue) Purge() (int, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for _, id := range queue.pending {
+		delete(queue.items, id)
+	}
+	count := len(queue.pending)
+	queue.pending = nil
+	queue.notifyUnsafe()
+	return count, nil
+}
+
 func (queue *memoryQueue) Done() <-chan struct{} { return queue.closed }
 
 func (queue *memoryQueue) Close() {
-	if atomic.CompareAndSwapInt32(&queue.closing, 0, 1) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	select {
+	case <-queue.closed:
+	default:
 		close(queue.closed)
-		close(queue.stream)
 	}
 }
 
@@ -112,3 +251,30 @@ func (queue *memoryQueue) Destroy() error {
 	queue.Close()
 	return nil
 }
+
+// pick (and remove from pending list) the oldest request which delivery time has come. If there is no such request,
+// returns delay till the nearest delayed request or zero if queue is empty.
+func (queue *memoryQueue) pickUnsafe(now time.Time) (uint64, bool, time.Duration) {
+	var delay time.Duration
+	for i, id := range queue.pending {
+		at := queue.items[id].payload.DeliverAt
+		if at == nil || !at.After(now) {
+			if i == 0 {
+				queue.pending = queue.pending[1:]
+			} else {
+				queue.pending = append(queue.pending[:i], queue.pending[i+1:]...)
+			}
+			return id, true, 0
+		}
+		if d := at.Sub(now); delay == 0 || d < delay {
+			delay = d
+		}
+	}
+	return 0, false, delay
+}
+
+// wake up all waiting producers and consumers
+func (queue *memoryQueue) notifyUnsafe() {
+	close(queue.changed)
+	queue.changed = make(chan struct{})
+}
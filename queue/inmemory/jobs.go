@@ -0,0 +1,57 @@
+package inmemory
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// NewJobs creates in-memory storage of jobs. Jobs are lost after restart.
+func NewJobs() *jobs {
+	return &jobs{jobs: make(map[string]types.Job)}
+}
+
+type jobs struct {
+	lock sync.RWMutex
+	jobs map[string]types.Job
+}
+
+func (js *jobs) Save(job *types.Job) error {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+	js.jobs[job.ID] = *job
+	return nil
+}
+
+func (js *jobs) Get(id string) (*types.Job, error) {
+	js.lock.RLock()
+	defer js.lock.RUnlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s: %w", id, os.ErrNotExist)
+	}
+	return &job, nil
+}
+
+func (js *jobs) Expire(before time.Time) (int, error) {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+	var count int
+	for id, job := range js.jobs {
+		if job.Finished() && job.Updated.Before(before) {
+			delete(js.jobs, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (js *jobs) Destroy() error {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+	js.jobs = make(map[string]types.Job)
+	return nil
+}
@@ -2,18 +2,72 @@ package queue
 
 import (
 	"context"
+	"io"
+	"time"
+
 	"github.com/reddec/trusted-cgi/types"
 )
 
-// Thread-safe FIFO queue designed for one multiple concurrent writers and single consumer.
-// Queue should store somewhere request body.
+// Thread-safe FIFO queue designed for multiple concurrent writers and multiple concurrent consumers.
+// Consumer leases request, processes it and acknowledges (removes) it, so request is not lost if consumer stopped
+// before acknowledge (at-least-once delivery). Queue should store somewhere request body.
 type Queue interface {
 	// Put request to queue
 	Put(ctx context.Context, request *types.Request) error
-	// Peek oldest request or wait till new data arrived/context expiration
-	Peek(ctx context.Context) (*types.Request, error)
-	// Commit (remove) oldest record
-	Commit(ctx context.Context) error
+	// Lease the oldest available request or wait till new data arrived/context expiration. Delayed request (see
+	// Request.DeliverAt) is not available before delivery time. Leased request is not available for other consumers
+	// till it will be acknowledged or released. Returns ID of request.
+	Lease(ctx context.Context) (uint64, error)
+	// Open stored request by ID. Each call returns new body stream, so it could be used for retries.
+	Open(id uint64) (*types.Request, error)
+	// Ack (remove) leased request
+	Ack(ctx context.Context, id uint64) error
+	// Release leased request - it will be available for consumers again
+	Release(ctx context.Context, id uint64) error
+	// Number of stored requests (including leased but not yet acknowledged)
+	Len() int64
+	// Clean all internal allocated resource
+	Destroy() error
+}
+
+// Optional inspection of queue content for operators. Implementations of Queue may support it.
+type Inspector interface {
+	// Number of leased (in-flight) requests
+	InFlight() int64
+	// Size of stored requests (including headers) in bytes
+	Size() int64
+	// List up to limit (all if limit is not positive) the oldest stored requests (without body), including leased
+	List(limit int) ([]types.QueueItem, error)
+	// Delete not leased request by ID
+	Delete(id uint64) error
+	// Purge (delete) all not leased requests. Returns number of deleted requests
+	Purge() (int, error)
+}
+
+// Thread-safe storage of failed requests (dead letters) with random access by ID.
+type DeadLetters interface {
+	// Put failed request. Letter ID and size will be assigned by storage
+	Put(letter *types.DeadLetter, body io.Reader) error
+	// List all stored letters (without body) ordered by time of failure
+	List() ([]types.DeadLetter, error)
+	// Get letter by ID with body
+	Get(id string) (*types.DeadLetter, io.ReadCloser, error)
+	// Remove letter by ID
+	Remove(id string) error
+	// Number of stored letters
+	Len() int64
+	// Clean all internal allocated resource
+	Destroy() error
+}
+
+// Thread-safe storage of jobs (status and result of queued requests) with random access by ID.
+type Jobs interface {
+	// Save (create or replace) job
+	Save(job *types.Job) error
+	// Get job by ID
+	Get(id string) (*types.Job, error)
+	// Remove finished jobs which were not updated since the time. Returns number of removed jobs
+	Expire(before time.Time) (int, error)
 	// Clean all internal allocated resource
 	Destroy() error
 }
@@ -3,17 +3,25 @@ package queue_test
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
 	"github.com/reddec/trusted-cgi/types"
-	"github.com/stretchr/testify/assert"
-	"io/ioutil"
-	"testing"
 )
 
-func testPutPeek(ctx context.Context, t *testing.T, queue queue.Queue) *types.Request {
+func testPutLease(ctx context.Context, t *testing.T, queue queue.Queue) (uint64, *types.Request) {
 	payload := uuid.New().String()
 
 	req := &types.Request{
@@ -31,45 +39,210 @@ func testPutPeek(ctx context.Context, t *testing.T, queue queue.Queue) *types.Re
 	}
 	err := queue.Put(ctx, req)
 	if !assert.NoError(t, err) {
-		return req
+		return 0, req
 	}
 
-	v, err := queue.Peek(ctx)
+	id, err := queue.Lease(ctx)
+	if !assert.NoError(t, err) {
+		return id, req
+	}
+	v, err := queue.Open(id)
 	if !assert.NoError(t, err) {
-		return req
+		return id, req
 	}
 	data, err := ioutil.ReadAll(v.Body)
 	if !assert.NoError(t, err) {
-		return req
+		return id, req
 	}
+	assert.NoError(t, v.Body.Close())
 	assert.Equal(t, string(data), payload)
 	assert.Equal(t, v.WithBody(nil), req.WithBody(nil))
-	return req
+	return id, req
 }
 
-func TestInMemory(t *testing.T) {
-	ctx := context.Background()
-	q := inmemory.New(1)
-	defer q.Close()
-
-	req := testPutPeek(ctx, t, q)
-	// try again - should be same result
-	v2, err := q.Peek(ctx)
+func testLeaseAck(ctx context.Context, t *testing.T, q queue.Queue) {
+	id, req := testPutLease(ctx, t, q)
+	// open again - should be same result
+	v2, err := q.Open(id)
 	if !assert.NoError(t, err) {
 		return
 	}
+	_ = v2.Body.Close()
 	assert.Equal(t, v2.WithBody(nil), req.WithBody(nil))
+	assert.Equal(t, int64(1), q.Len())
+
+	// leased request is not available for others
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = q.Lease(tctx)
+	cancel()
+	assert.Error(t, err)
+
+	// released request is available again
+	assert.NoError(t, q.Release(ctx, id))
+	assert.Error(t, q.Release(ctx, id))
+	leased, err := q.Lease(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, id, leased)
+
+	assert.NoError(t, q.Ack(ctx, id))
+	assert.Error(t, q.Ack(ctx, id))
+	assert.Equal(t, int64(0), q.Len())
+	// put again
+	id, _ = testPutLease(ctx, t, q)
+	assert.NoError(t, q.Ack(ctx, id))
+}
+
+// each request should be processed by exactly one of concurrent consumers
+func testConcurrentConsumers(ctx context.Context, t *testing.T, q queue.Queue) {
+	const total = 50
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	var seen = make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				id, err := q.Lease(ctx)
+				if err != nil {
+					return
+				}
+				req, err := q.Open(id)
+				if !assert.NoError(t, err) {
+					return
+				}
+				data, _ := ioutil.ReadAll(req.Body)
+				_ = req.Body.Close()
+				assert.NoError(t, q.Ack(ctx, id))
+				lock.Lock()
+				seen[string(data)]++
+				if len(seen) == total {
+					cancel()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < total; i++ {
+		err := q.Put(ctx, &types.Request{Body: ioutil.NopCloser(bytes.NewBufferString(uuid.New().String()))})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	wg.Wait()
+	assert.Len(t, seen, total)
+	for payload, count := range seen {
+		assert.Equal(t, 1, count, payload)
+	}
+	assert.Equal(t, int64(0), q.Len())
+}
 
-	err = q.Commit(ctx)
+// delayed request is available only after delivery time, others are not blocked by it
+func testDelayed(ctx context.Context, t *testing.T, q queue.Queue) {
+	const delay = 200 * time.Millisecond
+	deliverAt := time.Now().Add(delay)
+	err := q.Put(ctx, &types.Request{Path: "/delayed", DeliverAt: &deliverAt, Body: ioutil.NopCloser(bytes.NewBufferString("later"))})
 	if !assert.NoError(t, err) {
 		return
 	}
-	// put again
-	testPutPeek(ctx, t, q)
+	err = q.Put(ctx, &types.Request{Path: "/immediate", Body: ioutil.NopCloser(bytes.NewBufferString("now"))})
+	if !assert.NoError(t, err) {
+		return
+	}
+	id, err := q.Lease(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	req, err := q.Open(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = req.Body.Close()
+	assert.Equal(t, "/immediate", req.Path)
+	assert.NoError(t, q.Ack(ctx, id))
+
+	id, err = q.Lease(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, time.Now().Before(deliverAt), "delayed request leased too early")
+	req, err = q.Open(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = req.Body.Close()
+	assert.Equal(t, "/delayed", req.Path)
+	if assert.NotNil(t, req.DeliverAt) {
+		assert.True(t, deliverAt.Equal(*req.DeliverAt))
+	}
+	assert.NoError(t, q.Ack(ctx, id))
+}
+
+func testInspector(ctx context.Context, t *testing.T, q queue.Queue) {
+	inspector, ok := q.(queue.Inspector)
+	if !assert.True(t, ok, "should support inspection") {
+		return
+	}
+	for i := 0; i < 4; i++ {
+		err := q.Put(ctx, &types.Request{
+			Path:    "/" + strconv.Itoa(i),
+			Headers: map[string]string{"X-Index": strconv.Itoa(i)},
+			Body:    ioutil.NopCloser(bytes.NewBufferString("hello")),
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	leased, err := q.Lease(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), inspector.InFlight())
+	assert.True(t, inspector.Size() > 4*5)
+
+	items, err := inspector.List(2)
+	if !assert.NoError(t, err) || !assert.Len(t, items, 2) {
+		return
+	}
+	assert.Equal(t, leased, items[0].ID)
+	assert.True(t, items[0].Leased)
+	assert.False(t, items[1].Leased)
+	assert.Equal(t, "1", items[1].Request.Headers["X-Index"])
+	assert.True(t, items[1].Size > 5)
+	items, err = inspector.List(0)
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+
+	// in-flight request can not be deleted
+	assert.Error(t, inspector.Delete(leased))
+	assert.NoError(t, inspector.Delete(items[1].ID))
+	assert.Error(t, inspector.Delete(items[1].ID))
+	assert.Equal(t, int64(3), q.Len())
+
+	count, err := inspector.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(1), q.Len())
+	assert.NoError(t, q.Ack(ctx, leased))
+	assert.Equal(t, int64(0), q.Len())
+	assert.Equal(t, int64(0), inspector.Size())
+}
+
+func TestInMemory(t *testing.T) {
+	ctx := context.Background()
+	q := inmemory.New(10)
+	defer q.Close()
+
+	testLeaseAck(ctx, t, q)
+	testConcurrentConsumers(ctx, t, inmemory.New(5))
+	testInspector(ctx, t, inmemory.New(10))
+	testDelayed(ctx, t, inmemory.New(10))
 
 	q.Close()
 
-	_, err = q.Peek(ctx)
+	_, err := q.Lease(ctx)
 	assert.Error(t, err)
 
 	var closed bool
@@ -80,28 +253,227 @@ func TestInMemory(t *testing.T) {
 
 	}
 	assert.True(t, closed, "should be closed")
-
 }
 
 func TestInDir(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	q, err := indir.New(filepath.Join(dir, "queue"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testLeaseAck(ctx, t, q)
+
+	// not acknowledged requests are available after restart
+	testPutLease(ctx, t, q)
+	_, req := testPutLease(ctx, t, q)
+	q, err = indir.New(filepath.Join(dir, "queue"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(2), q.Len())
+	_, err = q.Lease(ctx)
+	assert.NoError(t, err)
+	id, err := q.Lease(ctx)
+	assert.NoError(t, err)
+	restored, err := q.Open(id)
+	if assert.NoError(t, err) {
+		_ = restored.Body.Close()
+		assert.Equal(t, req.Path, restored.Path)
+	}
+
+	concurrent, err := indir.New(filepath.Join(dir, "concurrent"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testConcurrentConsumers(ctx, t, concurrent)
+
+	inspected, err := indir.New(filepath.Join(dir, "inspected"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testInspector(ctx, t, inspected)
+
+	delayed, err := indir.New(filepath.Join(dir, "delayed"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testDelayed(ctx, t, delayed)
+
+	// delivery time is kept after restart
+	deliverAt := time.Now().Add(time.Hour)
+	err = delayed.Put(ctx, &types.Request{DeliverAt: &deliverAt, Body: ioutil.NopCloser(bytes.NewBufferString("later"))})
+	if !assert.NoError(t, err) {
+		return
+	}
+	delayed, err = indir.New(filepath.Join(dir, "delayed"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), delayed.Len())
+	tctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = delayed.Lease(tctx)
+	assert.Error(t, err)
+
+	// corrupted files are skipped and renamed
+	broken := filepath.Join(dir, "broken")
+	q, err = indir.New(broken)
+	if !assert.NoError(t, err) {
+		return
+	}
+	testPutLease(ctx, t, q)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(broken, "100.data"), []byte("garbage"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(broken, "bad.data"), []byte("garbage"), 0600))
+	q, err = indir.New(broken)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), q.Len())
+	assert.FileExists(t, filepath.Join(broken, "100.data.corrupted"))
+	assert.FileExists(t, filepath.Join(broken, "bad.data.corrupted"))
+}
+
+// queue created by previous versions (files of dfq) should be readable
+func TestInDir_legacy(t *testing.T) {
 	ctx := context.Background()
 	q, err := indir.New("test/queue")
 	if !assert.NoError(t, err) {
 		return
 	}
+	assert.True(t, q.Len() > 0)
+	id, err := q.Lease(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	req, err := q.Open(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_ = req.Body.Close()
+	assert.NoError(t, q.Release(ctx, id))
+}
+
+func testDeadLetters(t *testing.T, storage queue.DeadLetters) {
+	for i, payload := range []string{"first", "second"} {
+		letter := &types.DeadLetter{
+			Queue:    "origin",
+			Reason:   "failed",
+			Attempts: i + 1,
+			Failed:   time.Now().Add(time.Duration(i) * time.Second),
+			Request:  types.Request{Method: "POST", Headers: map[string]string{"Content-Type": "text/plain"}},
+		}
+		if !assert.NoError(t, storage.Put(letter, bytes.NewBufferString(payload))) {
+			return
+		}
+		assert.NotEmpty(t, letter.ID)
+		assert.Equal(t, int64(len(payload)), letter.Size)
+	}
+	assert.Equal(t, int64(2), storage.Len())
 
-	req := testPutPeek(ctx, t, q)
-	// try again - should be same result
-	v2, err := q.Peek(ctx)
+	list, err := storage.List()
+	if !assert.NoError(t, err) || !assert.Len(t, list, 2) {
+		return
+	}
+	assert.Equal(t, 1, list[0].Attempts)
+	assert.Equal(t, 2, list[1].Attempts)
+	assert.Equal(t, "text/plain", list[1].Request.Headers["Content-Type"])
+
+	letter, body, err := storage.Get(list[1].ID)
 	if !assert.NoError(t, err) {
 		return
 	}
-	assert.Equal(t, v2.WithBody(nil), req.WithBody(nil))
+	data, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.NoError(t, body.Close())
+	assert.Equal(t, "second", string(data))
+	assert.Equal(t, list[1].ID, letter.ID)
+
+	assert.NoError(t, storage.Remove(list[0].ID))
+	assert.Error(t, storage.Remove(list[0].ID))
+	_, _, err = storage.Get(list[0].ID)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), storage.Len())
+	assert.NoError(t, storage.Destroy())
+}
 
-	err = q.Commit(ctx)
+func TestInMemory_deadLetters(t *testing.T) {
+	testDeadLetters(t, inmemory.NewDeadLetters())
+}
+
+func TestInDir_deadLetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
 	if !assert.NoError(t, err) {
 		return
 	}
-	// put again
-	testPutPeek(ctx, t, q)
+	defer os.RemoveAll(dir)
+	storage, err := indir.NewDeadLetters(filepath.Join(dir, "dead"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testDeadLetters(t, storage)
+
+	// restore after restart
+	storage, err = indir.NewDeadLetters(filepath.Join(dir, "dead"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, storage.Put(&types.DeadLetter{Queue: "origin"}, bytes.NewBufferString("data")))
+	storage, err = indir.NewDeadLetters(filepath.Join(dir, "dead"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), storage.Len())
+}
+
+func testJobs(t *testing.T, storage queue.Jobs) {
+	now := time.Now()
+	finished := &types.Job{ID: uuid.New().String(), Queue: "origin", Status: types.JobDone, Updated: now.Add(-time.Hour), Result: "hello"}
+	running := &types.Job{ID: uuid.New().String(), Queue: "origin", Status: types.JobRunning, Updated: now.Add(-time.Hour)}
+	assert.NoError(t, storage.Save(finished))
+	assert.NoError(t, storage.Save(running))
+
+	job, err := storage.Get(finished.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", job.Result)
+		assert.True(t, finished.Updated.Equal(job.Updated))
+	}
+	_, err = storage.Get(uuid.New().String())
+	assert.True(t, os.IsNotExist(errors.Unwrap(err)) || os.IsNotExist(err))
+	_, err = storage.Get("../../etc/passwd")
+	assert.Error(t, err)
+
+	// only finished jobs are expired
+	count, err := storage.Expire(now.Add(-2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	count, err = storage.Expire(now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = storage.Get(finished.ID)
+	assert.Error(t, err)
+	_, err = storage.Get(running.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Destroy())
+}
+
+func TestInMemory_jobs(t *testing.T) {
+	testJobs(t, inmemory.NewJobs())
+}
+
+func TestInDir_jobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	storage, err := indir.NewJobs(filepath.Join(dir, "jobs"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testJobs(t, storage)
 }
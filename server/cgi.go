@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+const maxCGIHeaderSize = 64 * 1024 // maximum size of headers block in lambda output
+
+// Writer that parses CGI (RFC 3875) response headers from the beginning of the output.
+// Headers block should be separated from body by an empty line. Special headers:
+//
+//   - Status: <code> [reason] - HTTP status code (default 200)
+//   - Location: <url> - redirect (default status 302 if Status is not set)
+//
+// All other headers are copied to the response as-is.
+func newCGIResponse(writer http.ResponseWriter) *cgiResponse {
+	return &cgiResponse{writer: writer}
+}
+
+type cgiResponse struct {
+	writer  http.ResponseWriter
+	buffer  bytes.Buffer
+	sent    bool
+	failure error
+}
+
+func (cr *cgiResponse) Write(data []byte) (int, error) {
+	if cr.sent {
+		return cr.writer.Write(data)
+	}
+	if cr.failure != nil {
+		return 0, cr.failure
+	}
+	cr.buffer.Write(data)
+	head, body, ok := splitCGIHeaders(cr.buffer.Bytes())
+	if !ok {
+		if cr.buffer.Len() > maxCGIHeaderSize {
+			cr.failure = fmt.Errorf("CGI headers block is too big")
+			return 0, cr.failure
+		}
+		return len(data), nil
+	}
+	if err := cr.writeHeaders(head); err != nil {
+		cr.failure = err
+		return 0, err
+	}
+	if len(body) > 0 {
+		if _, err := cr.writer.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	cr.buffer.Reset()
+	return len(data), nil
+}
+
+// Finish response. If headers were not sent yet, the whole output is treated as headers block. In case of
+// invocation error or malformed headers, the 502 Bad Gateway will be returned (if possible).
+func (cr *cgiResponse) Finish(invokeErr error) error {
+	if cr.sent {
+		return invokeErr
+	}
+	if invokeErr == nil {
+		invokeErr = cr.failure
+	}
+	if invokeErr == nil {
+		invokeErr = cr.writeHeaders(cr.buffer.Bytes())
+	}
+	if code := rejectionStatus(invokeErr); code != 0 {
+		http.Error(cr.writer, invokeErr.Error(), code)
+	} else if invokeErr != nil {
+		http.Error(cr.writer, "malformed or failed lambda response", http.StatusBadGateway)
+	}
+	return invokeErr
+}
+
+func (cr *cgiResponse) writeHeaders(head []byte) error {
+	block := make([]byte, 0, len(head)+4)
+	block = append(append(block, head...), "\r\n\r\n"...)
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("parse CGI headers: %w", err)
+	}
+	code := http.StatusOK
+	if headers.Get("Location") != "" {
+		code = http.StatusFound
+	}
+	if status := headers.Get("Status"); status != "" {
+		code, err = parseCGIStatus(status)
+		if err != nil {
+			return err
+		}
+		headers.Del("Status")
+	}
+	for k, v := range headers {
+		cr.writer.Header()[k] = v
+	}
+	cr.writer.WriteHeader(code)
+	cr.sent = true
+	return nil
+}
+
+// split output to headers block (without trailing empty line) and body. Supports LF and CRLF line endings.
+func splitCGIHeaders(data []byte) (head, body []byte, ok bool) {
+	if bytes.HasPrefix(data, []byte("\r\n")) {
+		return nil, data[2:], true
+	}
+	if bytes.HasPrefix(data, []byte("\n")) {
+		return nil, data[1:], true
+	}
+	if idx := bytes.Index(data, []byte("\r\n\r\n")); idx >= 0 {
+		if lf := bytes.Index(data, []byte("\n\n")); lf < 0 || lf > idx {
+			return data[:idx], data[idx+4:], true
+		}
+	}
+	if idx := bytes.Index(data, []byte("\n\n")); idx >= 0 {
+		return data[:idx], data[idx+2:], true
+	}
+	return nil, nil, false
+}
+
+func parseCGIStatus(value string) (int, error) {
+	codeText, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+	code, err := strconv.Atoi(codeText)
+	if err != nil || code < 100 || code > 999 {
+		return 0, fmt.Errorf("invalid CGI status %q", value)
+	}
+	return code, nil
+}
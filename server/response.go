@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/reddec/trusted-cgi/application"
+)
+
+// Lambda response writer. Finish should be called after invocation with invocation result.
+type lambdaResponse interface {
+	io.Writer
+	// Finish response and return final error (if any)
+	Finish(invokeErr error) error
+}
+
+// Writer that sends 200 OK before first write, so rejected (before execution) requests could get proper status.
+func newPlainResponse(writer http.ResponseWriter) *plainResponse {
+	return &plainResponse{writer: writer}
+}
+
+type plainResponse struct {
+	writer http.ResponseWriter
+	sent   bool
+}
+
+func (pr *plainResponse) Write(data []byte) (int, error) {
+	if !pr.sent {
+		pr.writer.WriteHeader(http.StatusOK)
+		pr.sent = true
+	}
+	return pr.writer.Write(data)
+}
+
+func (pr *plainResponse) Finish(invokeErr error) error {
+	if pr.sent {
+		return invokeErr
+	}
+	if code := rejectionStatus(invokeErr); code != 0 {
+		http.Error(pr.writer, invokeErr.Error(), code)
+		return invokeErr
+	}
+	pr.writer.WriteHeader(http.StatusOK)
+	return invokeErr
+}
+
+// Writer that flushes every write to the client, so output is streamed without buffering (chunked encoding, SSE).
+func newStreamWriter(writer http.ResponseWriter) http.ResponseWriter {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return writer
+	}
+	writer.Header().Set("X-Accel-Buffering", "no") // disable buffering in nginx
+	return &streamWriter{ResponseWriter: writer, flusher: flusher}
+}
+
+type streamWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (sw *streamWriter) Write(data []byte) (int, error) {
+	n, err := sw.ResponseWriter.Write(data)
+	if err == nil {
+		sw.flusher.Flush()
+	}
+	return n, err
+}
+
+func (sw *streamWriter) Flush() {
+	sw.flusher.Flush()
+}
+
+// HTTP status for requests rejected by platform before execution or zero
+func rejectionStatus(err error) int {
+	switch {
+	case errors.Is(err, application.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	case errors.Is(err, application.ErrWaitTimeout):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, application.ErrMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, application.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return 0
+	}
+}
+
+// reply to request rejected by policy: 429 with Retry-After if rate limit reached, otherwise 403
+func policyError(writer http.ResponseWriter, err error) {
+	var limitErr *application.RateLimitError
+	if errors.As(err, &limitErr) {
+		writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+		http.Error(writer, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	http.Error(writer, err.Error(), http.StatusForbidden)
+}
@@ -2,10 +2,15 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/reddec/jsonrpc2"
 
 	"github.com/reddec/trusted-cgi/api"
@@ -13,33 +18,45 @@ import (
 	"github.com/reddec/trusted-cgi/application"
 	"github.com/reddec/trusted-cgi/assets"
 	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/tracing"
 	"github.com/reddec/trusted-cgi/types"
 )
 
+const (
+	delayHeader     = "X-Delay"      // delay of queued request processing
+	deliverAtHeader = "X-Deliver-At" // time of queued request processing
+)
+
 type TokenHandler interface {
 	ValidateToken(ctx context.Context, value *api.Token) error
 }
 
 type Server struct {
-	Policies     application.Policies
-	Platform     application.Platform
-	Cases        application.Cases
-	Queues       application.Queues
-	Dev          bool
-	BehindProxy  bool
-	Tracker      stats.Recorder
-	TokenHandler TokenHandler
-	ProjectAPI   api.ProjectAPI
-	LambdaAPI    api.LambdaAPI
-	UserAPI      api.UserAPI
-	QueuesAPI    api.QueuesAPI
-	PoliciesAPI  api.PoliciesAPI
+	Policies       application.Policies
+	Platform       application.Platform
+	Cases          application.Cases
+	Queues         application.Queues
+	Topics         application.Topics
+	Dev            bool
+	TrustedProxies types.Networks // X-Forwarded-For and X-Real-Ip are respected only from the networks
+	Tracker        stats.Recorder
+	TokenHandler   TokenHandler
+	ProjectAPI     api.ProjectAPI
+	LambdaAPI      api.LambdaAPI
+	UserAPI        api.UserAPI
+	QueuesAPI      api.QueuesAPI
+	TopicsAPI      api.TopicsAPI
+	PoliciesAPI    api.PoliciesAPI
+	Metrics        http.Handler // optional handler for /metrics
 }
 
 func (srv *Server) Handler(ctx context.Context) http.Handler {
 	mux := http.NewServeMux()
 	srv.installAPI(ctx, mux)
 	srv.installPublicRoutes(ctx, mux)
+	if srv.Metrics != nil {
+		mux.Handle("/metrics", srv.Metrics)
+	}
 	srv.installUI(mux)
 	return mux
 }
@@ -50,6 +67,7 @@ func (srv *Server) installAPI(ctx context.Context, mux *http.ServeMux) {
 	handlers.RegisterLambdaAPI(&router, srv.LambdaAPI, srv.TokenHandler)
 	handlers.RegisterProjectAPI(&router, srv.ProjectAPI, srv.TokenHandler)
 	handlers.RegisterQueuesAPI(&router, srv.QueuesAPI, srv.TokenHandler)
+	handlers.RegisterTopicsAPI(&router, srv.TopicsAPI, srv.TokenHandler)
 	handlers.RegisterPoliciesAPI(&router, srv.PoliciesAPI, srv.TokenHandler)
 
 	mux.Handle("/u/", chooseHandler(srv.Dev, jsonrpc2.HandlerRestContext(ctx, &router)))
@@ -60,26 +78,138 @@ func (srv *Server) installUI(mux *http.ServeMux) {
 }
 
 func (srv *Server) installPublicRoutes(ctx context.Context, mux *http.ServeMux) {
-	mux.Handle("/a/", openedHandler(http.StripPrefix("/a/", srv.withRequest(ctx, srv.handleLambda))))
-	mux.Handle("/l/", openedHandler(http.StripPrefix("/l/", srv.withRequest(ctx, srv.handleLink))))
-	mux.Handle("/q/", openedHandler(http.StripPrefix("/q/", srv.withRequest(ctx, srv.handleQueue))))
+	mux.Handle("/a/", openedHandler(http.StripPrefix("/a/", srv.withRequest(ctx, "invoke lambda", srv.handleLambda))))
+	mux.Handle("/l/", openedHandler(http.StripPrefix("/l/", srv.withRequest(ctx, "invoke link", srv.handleLink))))
+	mux.Handle("/q/", openedHandler(http.StripPrefix("/q/", srv.withRequest(ctx, "enqueue", srv.handleQueue))))
+	mux.Handle("/t/", openedHandler(http.StripPrefix("/t/", srv.withRequest(ctx, "publish", srv.handleTopic))))
 }
-func (srv *Server) handleQueue(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+func (srv *Server) handleQueue(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
 	q, err := srv.Queues.Get(uid)
 	if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusNotFound)
 		return
 	}
-	err = srv.Policies.Inspect(q.Target, req)
+	err = srv.inspect(ctx, q.Target, req)
+	if err != nil {
+		record.Err = err.Error()
+		policyError(writer, err)
+		return
+	}
+	if jobID, ok := jobPath(raw.URL.Path); ok && raw.Method == http.MethodGet {
+		srv.handleJob(writer, record, uid, jobID)
+		return
+	}
+	if callback := req.Headers[application.CallbackHeader]; q.Result == application.ResultCallback && callback != "" && !q.CallbackAllowed(callback) {
+		record.Err = application.ErrCallbackNotAllowed.Error()
+		http.Error(writer, record.Err, http.StatusBadRequest)
+		return
+	}
 
+	req.DeliverAt, err = deliveryTime(req, time.Now())
 	if err != nil {
 		record.Err = err.Error()
-		http.Error(writer, err.Error(), http.StatusForbidden)
+		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	putCtx, span := tracing.Start(ctx, "queue put")
+	span.SetKind(tracing.KindProducer)
+	span.SetAttribute("queue.name", uid)
+	req.Traceparent = tracing.Traceparent(putCtx)
 	err = srv.Queues.Put(uid, req)
+	span.End(err)
+	if err != nil {
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if q.Result == "" {
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	jobID := req.Headers[application.JobHeader]
+	writer.Header().Set(application.JobHeader, jobID)
+	if q.Result == application.ResultStore {
+		writer.Header().Set("Location", "/q/"+uid+"/jobs/"+jobID)
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(writer).Encode(map[string]string{"id": jobID})
+}
+
+func (srv *Server) handleJob(writer http.ResponseWriter, record *stats.Record, queue, id string) {
+	job, err := srv.Queues.Job(queue, id)
+	if err != nil {
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(job)
+}
+
+// job ID from path <queue>/jobs/<id>
+func jobPath(path string) (string, bool) {
+	sections := strings.Split(strings.Trim(path, "/"), "/")
+	if len(sections) != 3 || sections[1] != "jobs" || sections[2] == "" {
+		return "", false
+	}
+	return sections[2], true
+}
+func (srv *Server) handleTopic(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+	topic, err := srv.Topics.Get(uid)
+	if err != nil {
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	// request should be allowed by policies of all subscribers, each policy is checked once
+	var subscribers int
+	var inspected = make(map[string]bool)
+	for name := range topic.Queues {
+		q, err := srv.Queues.Get(name)
+		if err != nil {
+			continue // removed queue is skipped by publisher
+		}
+		subscribers++
+		key := "lambda:" + q.Target // lambda without policy
+		if policy, err := srv.Policies.Find(q.Target); err == nil {
+			key = policy.ID
+		}
+		if !inspected[key] {
+			inspected[key] = true
+			err = srv.inspect(ctx, q.Target, req)
+			if err != nil {
+				record.Err = err.Error()
+				policyError(writer, err)
+				return
+			}
+		}
+		if callback := req.Headers[application.CallbackHeader]; q.Result == application.ResultCallback && callback != "" && !q.CallbackAllowed(callback) {
+			record.Err = application.ErrCallbackNotAllowed.Error()
+			http.Error(writer, record.Err, http.StatusBadRequest)
+			return
+		}
+	}
+	if subscribers == 0 {
+		record.Err = "topic has no subscribers"
+		http.Error(writer, record.Err, http.StatusConflict)
+		return
+	}
+	req.DeliverAt, err = deliveryTime(req, time.Now())
+	if err != nil {
+		record.Err = err.Error()
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pubCtx, span := tracing.Start(ctx, "topic publish")
+	span.SetKind(tracing.KindProducer)
+	span.SetAttribute("topic.name", uid)
+	req.Traceparent = tracing.Traceparent(pubCtx)
+	err = srv.Topics.Publish(uid, req)
+	span.End(err)
 	if err != nil {
 		record.Err = err.Error()
 		http.Error(writer, err.Error(), http.StatusInternalServerError)
@@ -87,7 +217,38 @@ func (srv *Server) handleQueue(ctx context.Context, req *types.Request, writer h
 	}
 	writer.WriteHeader(http.StatusNoContent)
 }
-func (srv *Server) handleLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+
+// delivery time of queued request from X-Deliver-At (RFC3339 or unix seconds) or X-Delay (duration like 10m or
+// seconds) header. Returns nil if request should be delivered immediately.
+func deliveryTime(req *types.Request, now time.Time) (*time.Time, error) {
+	if value := req.Headers[deliverAtHeader]; value != "" {
+		if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+			at := time.Unix(sec, 0)
+			return &at, nil
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", deliverAtHeader, err)
+		}
+		return &at, nil
+	}
+	if value := req.Headers[delayHeader]; value != "" {
+		var delay time.Duration
+		if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+			delay = time.Duration(sec) * time.Second
+		} else if delay, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", delayHeader, err)
+		}
+		if delay < 0 {
+			return nil, fmt.Errorf("invalid %s header: negative delay", delayHeader)
+		}
+		at := now.Add(delay)
+		return &at, nil
+	}
+	return nil, nil
+}
+
+func (srv *Server) handleLambda(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
 	lambda, err := srv.Platform.FindByUID(uid)
 
 	if err != nil {
@@ -96,10 +257,10 @@ func (srv *Server) handleLambda(ctx context.Context, req *types.Request, writer
 		return
 	}
 
-	srv.runLambda(ctx, req, writer, lambda, record)
+	srv.runLambda(ctx, raw, req, writer, lambda, record)
 }
 
-func (srv *Server) handleLink(ctx context.Context, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
+func (srv *Server) handleLink(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, record *stats.Record, uid string) {
 	lambda, err := srv.Platform.FindByLink(uid)
 
 	if err != nil {
@@ -107,46 +268,96 @@ func (srv *Server) handleLink(ctx context.Context, req *types.Request, writer ht
 		http.Error(writer, err.Error(), http.StatusNotFound)
 		return
 	}
+	record.UID = lambda.UID
+	record.Alias = uid
 
-	srv.runLambda(ctx, req, writer, lambda, record)
+	srv.runLambda(ctx, raw, req, writer, lambda, record)
 }
 
-func (srv *Server) runLambda(ctx context.Context, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) {
-	err := srv.Policies.Inspect(lambda.UID, req)
+func (srv *Server) runLambda(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) {
+	err := srv.inspect(ctx, lambda.UID, req)
 	if err != nil {
 		record.End = time.Now()
 		record.Err = err.Error()
-		http.Error(writer, err.Error(), http.StatusForbidden)
+		policyError(writer, err)
+		return
+	}
+	if monitor, ok := srv.Tracker.(stats.Monitor); ok {
+		defer monitor.Started(lambda.UID, record.Alias)()
+	}
+	manifest := lambda.Lambda.Manifest()
+	if manifest.WebSocket != nil && websocket.IsWebSocketUpgrade(raw) {
+		srv.runWebSocket(ctx, raw, req, writer, lambda, record)
 		return
 	}
-	for k, v := range lambda.Lambda.Manifest().OutputHeaders {
+	for k, v := range manifest.OutputHeaders {
 		writer.Header().Set(k, v)
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	if manifest.Stream {
+		writer = newStreamWriter(writer)
+	}
 
-	err = srv.Platform.Invoke(ctx, lambda.Lambda, *req, writer)
+	var response lambdaResponse
+	// static files are served as-is, without headers in output; proxy always returns headers
+	if (manifest.ParseHeaders || manifest.Proxy != nil) && !(manifest.Static != "" && req.Method == http.MethodGet) {
+		response = newCGIResponse(writer)
+	} else {
+		response = newPlainResponse(writer)
+	}
+	err = srv.Platform.Invoke(ctx, lambda.Lambda, *req, response)
+	trackResult(record, err)
+	err = response.Finish(err)
 	record.End = time.Now()
 	if err != nil {
 		record.Err = err.Error()
 	}
 }
 
-type resourceHandler func(ctx context.Context, req *types.Request, writer http.ResponseWriter, rec *stats.Record, uid string)
+// check policies of the lambda in a child span
+func (srv *Server) inspect(ctx context.Context, uid string, req *types.Request) error {
+	_, span := tracing.Start(ctx, "policy")
+	span.SetAttribute("lambda.uid", uid)
+	err := srv.Policies.Inspect(uid, req)
+	span.End(err)
+	return err
+}
+
+type resourceHandler func(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, rec *stats.Record, uid string)
 
-func (srv *Server) withRequest(ctx context.Context, next resourceHandler) http.Handler {
+func (srv *Server) withRequest(ctx context.Context, spanName string, next resourceHandler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		sections := strings.SplitN(strings.Trim(request.URL.Path, "/"), "/", 2)
 		uid := sections[0]
-		req := types.FromHTTP(request, srv.BehindProxy)
+		body := &countingReader{ReadCloser: request.Body}
+		request.Body = body
+		tracked := &trackingWriter{ResponseWriter: writer}
+		req := types.FromHTTP(request, srv.TrustedProxies)
 		var record = stats.Record{
 			UID:     uid,
 			Request: *req,
 			Begin:   time.Now(),
 		}
-		next(ctx, req, writer, &record, uid)
+		sctx, span := tracing.StartAt(tracing.WithRemote(ctx, request.Header.Get("Traceparent")), spanName, record.Begin)
+		span.SetKind(tracing.KindServer)
+		span.SetAttribute("http.method", request.Method)
+		span.SetAttribute("http.target", request.RequestURI)
+		span.SetAttribute("resource", uid)
+		next(sctx, request, req, tracked, &record, uid)
 		record.End = time.Now()
+		record.Status = tracked.Status()
+		record.BytesIn = body.read
+		record.BytesOut = tracked.written
 		srv.Tracker.Track(record)
+		span.SetAttribute("http.status_code", record.Status)
+		if record.UID != uid {
+			span.SetAttribute("lambda.uid", record.UID)
+		}
+		var err error
+		if record.Err != "" {
+			err = errors.New(record.Err)
+		}
+		span.End(err)
 	})
 }
 
@@ -1,15 +1,22 @@
 package server_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/reddec/trusted-cgi/api/services"
@@ -18,11 +25,15 @@ import (
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/topicmanager"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/inmemory"
 	"github.com/reddec/trusted-cgi/server"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/stats/impl/rollup"
 	"github.com/reddec/trusted-cgi/templates"
+	"github.com/reddec/trusted-cgi/tracing"
 	"github.com/reddec/trusted-cgi/types"
 )
 
@@ -60,7 +71,12 @@ func createTestServer() (*testServer, error) {
 		return inmemory.New(1024), nil
 	}
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(tmpDir, "queues.json")), basePlatform, queueFactory)
+	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(tmpDir, "queues.json")), basePlatform, queueFactory, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	topicManager, err := topicmanager.New(topicmanager.FileConfig(filepath.Join(tmpDir, "topics.json")), queueManager)
 	if err != nil {
 		return nil, err
 	}
@@ -72,9 +88,11 @@ func createTestServer() (*testServer, error) {
 
 	tracker := memlog.New(1000)
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
+	aggregates := rollup.New(time.Hour)
+	projectApi := services.NewProjectSrv(useCases, tracker, aggregates)
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, aggregates)
 	queuesApi := services.NewQueuesSrv(queueManager)
+	topicsApi := services.NewTopicsSrv(topicManager)
 	policiesApi := services.NewPoliciesSrv(policies)
 	userApi, err := services.CreateUserSrv(filepath.Join(tmpDir, "server.json"), "admin")
 	if err != nil {
@@ -86,6 +104,7 @@ func createTestServer() (*testServer, error) {
 		Platform:     basePlatform,
 		Cases:        useCases,
 		Queues:       queueManager,
+		Topics:       topicManager,
 		Dev:          true,
 		Tracker:      tracker,
 		TokenHandler: userApi,
@@ -93,6 +112,7 @@ func createTestServer() (*testServer, error) {
 		LambdaAPI:    lambdaApi,
 		UserAPI:      userApi,
 		QueuesAPI:    queuesApi,
+		TopicsAPI:    topicsApi,
 		PoliciesAPI:  policiesApi,
 	}
 	return &testServer{
@@ -230,6 +250,221 @@ func TestHandlerByQueue(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, rr.Code)
 }
 
+func TestHandlerByQueue_delayed(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	assert.NoError(t, err)
+	err = srv.Server.Queues.Add(application.Queue{
+		Name:           "my-queue",
+		Target:         uid,
+		MaxElementSize: 1024,
+	})
+	assert.NoError(t, err)
+
+	for header, value := range map[string]string{"X-Delay": "ten minutes", "X-Deliver-At": "tomorrow"} {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/q/my-queue", bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		req.Header.Set(header, value)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, header)
+	}
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/q/my-queue", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	req.Header.Set("X-Delay", "1h")
+	begin := time.Now()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	time.Sleep(50 * time.Millisecond)
+	items, err := srv.Server.Queues.Peek("my-queue", 0)
+	if !assert.NoError(t, err) || !assert.Len(t, items, 1) {
+		return
+	}
+	assert.False(t, items[0].Leased, "delayed request should not be processed")
+	if assert.NotNil(t, items[0].Request.DeliverAt) {
+		assert.WithinDuration(t, begin.Add(time.Hour), *items[0].Request.DeliverAt, time.Second)
+	}
+}
+
+func TestHandlerByQueue_jobs(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	assert.NoError(t, err)
+	err = srv.Server.Queues.Add(application.Queue{
+		Name:   "my-queue",
+		Target: uid,
+		Result: application.ResultStore,
+	})
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/q/my-queue", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	var accepted struct {
+		ID string `json:"id"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &accepted))
+	assert.NotEmpty(t, accepted.ID)
+	assert.Equal(t, "/q/my-queue/jobs/"+accepted.ID, rr.Header().Get("Location"))
+
+	var job types.Job
+	for i := 0; i < 100 && !job.Finished(); i++ {
+		rr = httptest.NewRecorder()
+		req, err = http.NewRequest(http.MethodGet, "https://example.com/q/my-queue/jobs/"+accepted.ID, nil)
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		if !assert.Equal(t, http.StatusOK, rr.Code) {
+			return
+		}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, types.JobDone, job.Status)
+	assert.Equal(t, "hello", job.Result)
+
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodGet, "https://example.com/q/my-queue/jobs/unknown", nil)
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestHandlerByQueue_callback(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+	assert.NoError(t, err)
+	err = srv.Server.Queues.Add(application.Queue{
+		Name:         "my-queue",
+		Target:       uid,
+		Result:       application.ResultCallback,
+		CallbackURLs: types.StringSet("https://hooks.example.com/done"),
+	})
+	assert.NoError(t, err)
+
+	send := func(callback string) int {
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/q/my-queue", bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		req.Header.Set(application.CallbackHeader, callback)
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusAccepted, send("https://hooks.example.com/done/1"))
+	assert.Equal(t, http.StatusBadRequest, send("http://169.254.169.254/latest/meta-data"))
+	assert.Equal(t, http.StatusBadRequest, send("https://hooks.example.com.evil.org/done"))
+	assert.Equal(t, http.StatusBadRequest, send("https://hooks.example.com/done/../admin"))
+}
+
+func TestHandlerByTopic(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	for _, name := range []string{"first-queue", "second-queue"} {
+		uid, err := srv.AddDummyLambda(ctx, "cat", "-")
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Server.Queues.Add(application.Queue{Name: name, Target: uid}))
+	}
+	assert.NoError(t, srv.Server.Topics.Add("my-topic"))
+	assert.NoError(t, srv.Server.Topics.Subscribe("my-topic", "first-queue"))
+	assert.NoError(t, srv.Server.Topics.Subscribe("my-topic", "second-queue"))
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/t/unknown-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// topic without subscribers should not accept requests
+	assert.NoError(t, srv.Server.Topics.Add("empty-topic"))
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/t/empty-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// delayed, so requests stay in queues
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/t/my-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	req.Header.Set("X-Delay", "1h")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	for _, name := range []string{"first-queue", "second-queue"} {
+		items, err := srv.Server.Queues.Peek(name, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, items, 1, name) {
+			assert.Equal(t, http.MethodPost, items[0].Request.Method, name)
+		}
+	}
+
+	// all subscribers should allow request
+	second, err := srv.Server.Queues.Get("second-queue")
+	assert.NoError(t, err)
+	_, err = srv.Server.Policies.Create("temp", application.PolicyDefinition{
+		Public: false,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Server.Policies.Apply(second.Target, "temp"))
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/t/my-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// shared policy is checked once: one request per minute is allowed for both subscribers
+	first, err := srv.Server.Queues.Get("first-queue")
+	assert.NoError(t, err)
+	_, err = srv.Server.Policies.Create("limited", application.PolicyDefinition{
+		Public:    true,
+		RateLimit: &application.RateLimit{Requests: 1, Interval: types.JsonDuration(time.Minute), Key: application.RateLimitByGlobal},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Server.Policies.Apply(first.Target, "limited"))
+	assert.NoError(t, srv.Server.Policies.Apply(second.Target, "limited"))
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/t/my-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	req.Header.Set("X-Delay", "1h")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "https://example.com/t/my-topic", bytes.NewBufferString("hello"))
+	assert.NoError(t, err)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
 func TestHandlerByQueue_forbidden(t *testing.T) {
 	ctx := context.Background()
 	srv, err := createTestServer()
@@ -262,3 +497,351 @@ func TestHandlerByQueue_forbidden(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusForbidden, rr.Code)
 }
+
+func TestHandlerByAlias_rateLimited(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	first, err := srv.AddDummyLambda(ctx, "cat", "-")
+	assert.NoError(t, err)
+	second, err := srv.AddDummyLambda(ctx, "cat", "-")
+	assert.NoError(t, err)
+	err = srv.Server.Queues.Add(application.Queue{
+		Name:           "my-queue",
+		Target:         second,
+		Retry:          1,
+		MaxElementSize: 1024,
+	})
+	assert.NoError(t, err)
+
+	_, err = srv.Server.Policies.Create("limited", application.PolicyDefinition{
+		Public:    true,
+		RateLimit: &application.RateLimit{Requests: 1, Interval: types.JsonDuration(time.Minute), Burst: 2},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Server.Policies.Apply(first, "limited"))
+	assert.NoError(t, srv.Server.Policies.Apply(second, "limited"))
+	_, err = srv.Server.Platform.Link(first, "test-link")
+	assert.NoError(t, err)
+
+	send := func(path string, remote string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "https://example.com"+path, bytes.NewBufferString("hello"))
+		req.RemoteAddr = remote
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// limit is shared by lambdas of the policy
+	assert.Equal(t, http.StatusOK, send("/l/test-link", "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusNoContent, send("/q/my-queue", "10.0.0.1:1234").Code)
+	rr := send("/l/test-link", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, send("/q/my-queue", "10.0.0.1:4321").Code)
+	// other clients have own limits
+	assert.Equal(t, http.StatusOK, send("/l/test-link", "10.0.0.2:1234").Code)
+}
+
+func TestHandlerByUID_parseHeaders(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	invoke := func(script string) *httptest.ResponseRecorder {
+		uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+			Manifest: types.Manifest{
+				Run:           []string{"sh", "-c", script},
+				OutputHeaders: map[string]string{"Content-Type": "text/plain"},
+				ParseHeaders:  true,
+			},
+		})
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPost, "https://example.com/a/"+uid, bytes.NewBufferString("hello"))
+		assert.NoError(t, err)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("status and headers", func(t *testing.T) {
+		rr := invoke(`printf 'Status: 201 Created\r\nContent-Type: application/json\r\nX-Foo: bar\r\n\r\n'; cat -`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "bar", rr.Header().Get("X-Foo"))
+		assert.Equal(t, "hello", rr.Body.String())
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := invoke(`printf 'Location: https://example.com/\n\n'`)
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://example.com/", rr.Header().Get("Location"))
+	})
+
+	t.Run("only headers", func(t *testing.T) {
+		rr := invoke(`printf 'Status: 404\n'`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("failed lambda", func(t *testing.T) {
+		rr := invoke(`exit 1`)
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+
+	t.Run("malformed status", func(t *testing.T) {
+		rr := invoke(`printf 'Status: abc\n\nhello'`)
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+}
+
+func TestHandlerByAlias_proxy(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	socket := filepath.Join(srv.Dir, "backend.sock")
+	listener, err := net.Listen("unix", socket)
+	if !assert.NoError(t, err) {
+		return
+	}
+	backend := &http.Server{Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		writer.Header().Set("X-Backend", request.Header.Get("X-Forwarded-Host"))
+		writer.Header().Add("Set-Cookie", "a=1")
+		writer.Header().Add("Set-Cookie", "b=2")
+		writer.WriteHeader(http.StatusCreated)
+		_, _ = writer.Write([]byte(request.Method + " " + request.URL.RequestURI() + " " + string(body)))
+	})}
+	go backend.Serve(listener)
+	defer backend.Close()
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Proxy: &types.Proxy{Socket: socket},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = srv.Server.Platform.Link(uid, "backend")
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "https://example.com/l/backend/foo/bar?x=1", bytes.NewBufferString("hello"))
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "example.com", rr.Header().Get("X-Backend"))
+	assert.Equal(t, []string{"a=1", "b=2"}, rr.Header().Values("Set-Cookie"))
+	assert.Equal(t, "PUT /foo/bar?x=1 hello", rr.Body.String())
+
+	// declared body bigger than limit should not be cut
+	lambda, err := srv.Server.Platform.FindByUID(uid)
+	if !assert.NoError(t, err) {
+		return
+	}
+	manifest := lambda.Lambda.Manifest()
+	manifest.MaximumPayload = 3
+	assert.NoError(t, lambda.Lambda.SetManifest(manifest))
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPut, "https://example.com/l/backend/foo", bytes.NewBufferString("hello"))
+	req.Header.Set("Content-Length", "5")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestHandlerByUID_stream(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:           []string{"sh", "-c", `echo "data: first"; echo; sleep 2; echo "data: second"; echo`},
+			OutputHeaders: map[string]string{"Content-Type": "text/event-stream"},
+			Stream:        true,
+		},
+	})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Server.Handler(ctx))
+	defer ts.Close()
+
+	started := time.Now()
+	res, err := http.Get(ts.URL + "/a/" + uid)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "data: first\n", line)
+	assert.Less(t, int64(time.Since(started)), int64(time.Second), "first event should not wait for the lambda end")
+}
+
+func TestHandlerByUID_websocket(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:       []string{"sh", "-c", `while read line; do echo "echo: $line"; done`},
+			WebSocket: &types.WebSocket{},
+		},
+	})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Server.Handler(ctx))
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/a/"+uid, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", "world"} {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(msg)))
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, "echo: "+msg, string(data))
+	}
+	// close stdin - lambda should finish and close connection
+	conn.SetCloseHandler(func(code int, text string) error { return nil })
+	assert.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
+
+func TestHandlerByAlias_stats(t *testing.T) {
+	ctx := context.Background()
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+	reader := srv.Server.Tracker.(stats.Reader)
+
+	uid, err := srv.Server.Cases.CreateFromTemplate(ctx, templates.Template{
+		Manifest: types.Manifest{
+			Run:          []string{"sh", "-c", `cat; exit 3`},
+			ParseHeaders: true,
+			TimeLimit:    types.JsonDuration(time.Second),
+		},
+	})
+	assert.NoError(t, err)
+	_, err = srv.Server.Platform.Link(uid, "test-link")
+	assert.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/l/test-link", bytes.NewBufferString("Status: 201\n\nhello"))
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	records, err := reader.LastByUID(uid, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		record := records[0]
+		assert.Equal(t, "test-link", record.Alias)
+		assert.Equal(t, http.StatusCreated, record.Status)
+		assert.Equal(t, 3, record.ExitCode)
+		assert.Equal(t, int64(18), record.BytesIn)
+		assert.Equal(t, int64(5), record.BytesOut)
+		assert.Empty(t, record.KillReason)
+		assert.NotEmpty(t, record.Err)
+	}
+
+	// time limit
+	manifest := types.Manifest{Run: []string{"sleep", "10"}, TimeLimit: types.JsonDuration(100 * time.Millisecond)}
+	_, err = srv.Server.LambdaAPI.Update(ctx, nil, uid, manifest)
+	assert.NoError(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "https://example.com/a/"+uid, nil))
+
+	records, err = reader.LastByUID(uid, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, stats.KillTimeout, records[0].KillReason)
+		assert.Equal(t, -1, records[0].ExitCode)
+		assert.Empty(t, records[0].Alias)
+	}
+}
+
+type spansRecorder struct {
+	spans []tracing.SpanData
+}
+
+func (sr *spansRecorder) Export(spans []tracing.SpanData) error {
+	sr.spans = append(sr.spans, spans...)
+	return nil
+}
+
+func (sr *spansRecorder) Close() error { return nil }
+
+func (sr *spansRecorder) byName(name string) *tracing.SpanData {
+	for i := range sr.spans {
+		if sr.spans[i].Name == name {
+			return &sr.spans[i]
+		}
+	}
+	return nil
+}
+
+func TestHandlerByUID_tracing(t *testing.T) {
+	var exporter spansRecorder
+	tracer := tracing.New(&exporter)
+	ctx := tracing.WithTracer(context.Background(), tracer)
+	srv, err := createTestServer()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(srv.Dir)
+	handler := srv.Server.Handler(ctx)
+
+	uid, err := srv.AddDummyLambda(ctx, "sh", "-c", "printf %s $TRACEPARENT")
+	assert.NoError(t, err)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/a/"+uid, nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, tracer.Close())
+
+	root := exporter.byName("invoke lambda")
+	policySpan := exporter.byName("policy")
+	process := exporter.byName("process")
+	if !assert.NotNil(t, root) || !assert.NotNil(t, policySpan) || !assert.NotNil(t, process) {
+		return
+	}
+	assert.Equal(t, traceID, root.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", root.ParentSpanID.String())
+	assert.Equal(t, http.StatusOK, root.Attributes["http.status_code"])
+	assert.Equal(t, root.SpanID, policySpan.ParentSpanID)
+	assert.Equal(t, root.SpanID, process.ParentSpanID)
+	// lambda gets context of the process span
+	assert.Equal(t, "00-"+traceID+"-"+process.SpanID.String()+"-01", rr.Body.String())
+}
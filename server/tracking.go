@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+// Response writer that tracks status and size of the response. Supports flushing and hijacking (if underlying
+// writer supports them).
+type trackingWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (tw *trackingWriter) WriteHeader(statusCode int) {
+	if tw.status == 0 {
+		tw.status = statusCode
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *trackingWriter) Write(data []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	n, err := tw.ResponseWriter.Write(data)
+	tw.written += int64(n)
+	return n, err
+}
+
+func (tw *trackingWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (tw *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijacking is not supported")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && tw.status == 0 {
+		tw.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// Status of response. If nothing was written, the default 200 OK will be sent by HTTP server.
+func (tw *trackingWriter) Status() int {
+	if tw.status == 0 {
+		return http.StatusOK
+	}
+	return tw.status
+}
+
+// Reader that counts read bytes
+type countingReader struct {
+	io.ReadCloser
+	read int64
+}
+
+func (cr *countingReader) Read(data []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(data)
+	cr.read += int64(n)
+	return n, err
+}
+
+// fill record by invocation result: exit code and kill reason
+func trackResult(record *stats.Record, err error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		record.ExitCode = exitErr.ExitCode()
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		record.KillReason = stats.KillTimeout
+	case errors.Is(err, context.Canceled):
+		record.KillReason = stats.KillCanceled
+	case errors.Is(err, application.ErrTooManyRequests), errors.Is(err, application.ErrWaitTimeout):
+		record.KillReason = stats.KillLimit
+	case exitErr != nil && exitErr.ExitCode() == -1:
+		record.KillReason = stats.KillSignal
+	}
+}
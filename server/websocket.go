@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+const (
+	defaultMaxMessage = 1024 * 1024 // default maximum size of WebSocket message
+	closeGrace        = time.Second // time for lambda to exit after client disconnected (stdin closed)
+)
+
+var upgrader = websocket.Upgrader{
+	// public routes are opened for any origin (see openedHandler); access is controlled by policies
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Accept WebSocket connection and run lambda for the whole connection lifetime. Incoming messages are written to
+// stdin, output is sent back as messages. Framing of messages in stdin/stdout is defined by manifest.
+func (srv *Server) runWebSocket(ctx context.Context, raw *http.Request, req *types.Request, writer http.ResponseWriter, lambda *application.Definition, record *stats.Record) {
+	config := *lambda.Lambda.Manifest().WebSocket
+	maxMessage := config.MaxMessage
+	if maxMessage <= 0 {
+		maxMessage = defaultMaxMessage
+	}
+	conn, err := upgrader.Upgrade(writer, raw, nil)
+	if err != nil {
+		record.End = time.Now()
+		record.Err = err.Error()
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxMessage)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	input, inputWriter := io.Pipe()
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		_ = inputWriter.CloseWithError(readMessages(conn, config.Framing, inputWriter))
+	}()
+
+	// after client disconnected lambda has a chance to exit by closed stdin
+	var killed atomic.Bool
+	go func() {
+		select {
+		case <-disconnected:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(closeGrace):
+			killed.Store(true)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	messageType := websocket.TextMessage
+	if config.Binary {
+		messageType = websocket.BinaryMessage
+	}
+	output := &messageWriter{conn: conn, messageType: messageType, framing: config.Framing, limit: maxMessage}
+	err = srv.Platform.Invoke(ctx, lambda.Lambda, *req.WithBody(input), output)
+	if err == nil {
+		err = output.Finish()
+	}
+	trackResult(record, err)
+	if killed.Load() {
+		err = nil // lambda was stopped because client has gone
+		record.KillReason = stats.KillCanceled
+	}
+
+	code, reason := websocket.CloseNormalClosure, ""
+	if errors.Is(err, application.ErrMethodNotAllowed) {
+		code, reason = websocket.ClosePolicyViolation, err.Error()
+	} else if rejectionStatus(err) != 0 {
+		code, reason = websocket.CloseTryAgainLater, err.Error()
+	} else if err != nil {
+		code, reason = websocket.CloseInternalServerErr, "lambda failed"
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeGrace))
+	record.End = time.Now()
+	if err != nil {
+		record.Err = err.Error()
+	}
+}
+
+// read messages from connection and write them to the output with defined framing
+func readMessages(conn *websocket.Conn, framing string, out io.Writer) error {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		if framing == types.FramingLength {
+			data = append([]byte(strconv.Itoa(len(data))+"\n"), data...)
+		} else {
+			data = append(data, '\n')
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+}
+
+// Writer that splits lambda output to messages by defined framing
+type messageWriter struct {
+	conn        *websocket.Conn
+	messageType int
+	framing     string
+	limit       int64
+	buffer      bytes.Buffer
+}
+
+func (mw *messageWriter) Write(data []byte) (int, error) {
+	mw.buffer.Write(data)
+	var err error
+	if mw.framing == types.FramingLength {
+		err = mw.sendFrames()
+	} else {
+		err = mw.sendLines()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Finish sends the rest of output (incomplete line) as a message.
+func (mw *messageWriter) Finish() error {
+	if mw.buffer.Len() == 0 {
+		return nil
+	}
+	if mw.framing == types.FramingLength {
+		return fmt.Errorf("incomplete message in output")
+	}
+	return mw.send(mw.buffer.Bytes())
+}
+
+func (mw *messageWriter) sendLines() error {
+	for {
+		idx := bytes.IndexByte(mw.buffer.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := mw.buffer.Next(idx + 1)
+		if err := mw.send(bytes.TrimRight(line, "\r\n")); err != nil {
+			return err
+		}
+	}
+	if int64(mw.buffer.Len()) > mw.limit {
+		// too long line - send as-is
+		return mw.send(mw.buffer.Next(mw.buffer.Len()))
+	}
+	return nil
+}
+
+func (mw *messageWriter) sendFrames() error {
+	for {
+		data := mw.buffer.Bytes()
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			if len(data) > 20 {
+				return fmt.Errorf("malformed message header in output")
+			}
+			return nil // incomplete header
+		}
+		size, err := strconv.ParseInt(string(data[:idx]), 10, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("malformed message header %q in output", data[:idx])
+		}
+		if size > mw.limit {
+			return fmt.Errorf("too big message in output")
+		}
+		if int64(len(data)-idx-1) < size {
+			return nil // incomplete message
+		}
+		mw.buffer.Next(idx + 1)
+		if err := mw.send(mw.buffer.Next(int(size))); err != nil {
+			return err
+		}
+	}
+}
+
+func (mw *messageWriter) send(payload []byte) error {
+	return mw.conn.WriteMessage(mw.messageType, payload)
+}
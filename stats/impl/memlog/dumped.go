@@ -112,3 +112,7 @@ func (d *dumped) LastByUID(uid string, limit int) ([]stats.Record, error) {
 func (d *dumped) Last(limit int) ([]stats.Record, error) {
 	return d.mem.Last(limit)
 }
+
+func (d *dumped) Search(query stats.Query) ([]stats.Record, error) {
+	return d.mem.Search(query)
+}
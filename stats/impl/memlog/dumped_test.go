@@ -0,0 +1,73 @@
+package memlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+func TestDumped_previousFormat(t *testing.T) {
+	dir, err := os.MkdirTemp("", "memlog-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".stats")
+	begin := time.Now().Truncate(time.Second)
+
+	// record without status, exit code, sizes, kill reason and alias
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	writer := msgp.NewWriter(f)
+	require.NoError(t, writer.WriteArrayHeader(1))
+	require.NoError(t, writer.WriteMapHeader(4))
+	require.NoError(t, writer.WriteString("uid"))
+	require.NoError(t, writer.WriteString("old"))
+	require.NoError(t, writer.WriteString("err"))
+	require.NoError(t, writer.WriteString("failed"))
+	require.NoError(t, writer.WriteString("beg"))
+	require.NoError(t, writer.WriteTime(begin))
+	require.NoError(t, writer.WriteString("end"))
+	require.NoError(t, writer.WriteTime(begin.Add(time.Second)))
+	require.NoError(t, writer.Flush())
+	require.NoError(t, f.Close())
+
+	d, err := NewDumped(file, 10)
+	require.NoError(t, err)
+	records, err := d.LastByUID("old", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "failed", records[0].Err)
+	assert.True(t, begin.Equal(records[0].Begin))
+	assert.Zero(t, records[0].Status)
+
+	d.Track(stats.Record{
+		UID:        "new",
+		Begin:      begin,
+		End:        begin,
+		Alias:      "link",
+		Status:     201,
+		ExitCode:   -1,
+		BytesIn:    10,
+		BytesOut:   20,
+		KillReason: stats.KillTimeout,
+	})
+	require.NoError(t, d.Dump())
+
+	d, err = NewDumped(file, 10)
+	require.NoError(t, err)
+	records, err = d.LastByUID("new", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "link", records[0].Alias)
+	assert.Equal(t, 201, records[0].Status)
+	assert.Equal(t, -1, records[0].ExitCode)
+	assert.Equal(t, int64(10), records[0].BytesIn)
+	assert.Equal(t, int64(20), records[0].BytesOut)
+	assert.Equal(t, stats.KillTimeout, records[0].KillReason)
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
+// Legacy (v0) records are encoded as tuples and require conversion. Newer records are encoded as maps with optional
+// fields, so dumps made before adding fields (status, exit code, sizes, kill reason, alias) are read as-is.
 func isLegacyRecord(itemReader *msgp.Reader) (bool, error) {
 	t, err := itemReader.NextType()
 	if err != nil {
@@ -49,3 +49,14 @@ func (s *statLogger) Last(limit int) ([]stats.Record, error) {
 
 	return chunk, nil
 }
+
+func (s *statLogger) Search(query stats.Query) ([]stats.Record, error) {
+	var ans = make([]stats.Record, 0)
+	clone := s.buffer.Flatten()
+	for i := len(clone) - 1; i >= 0 && (query.Limit <= 0 || len(ans) < query.Limit); i-- {
+		if query.Match(clone[i]) {
+			ans = append(ans, clone[i])
+		}
+	}
+	return ans, nil
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const prefix = "trusted_cgi_"
+
+// Handler exposes metrics in Prometheus text format. If token is not empty, requests should
+// contain header Authorization: Bearer <token>.
+func (c *collector) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if token != "" {
+			value := request.Header.Get("Authorization")
+			if !strings.HasPrefix(value, "Bearer ") || subtle.ConstantTimeCompare([]byte(value[len("Bearer "):]), []byte(token)) != 1 {
+				http.Error(writer, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = c.Write(writer)
+	})
+}
+
+// Write all metrics in Prometheus text format
+func (c *collector) Write(out io.Writer) error {
+	c.lock.Lock()
+	source, index := c.source, c.index
+	c.lock.Unlock()
+	var depths map[string]int64
+	if source != nil {
+		depths = source.Depths() // outside of lock - source may be busy
+	}
+	if index != nil {
+		c.prune(index)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &writer{out: bufio.NewWriter(out)}
+
+	lambdas := make([]lambdaKey, 0, len(c.lambdas))
+	for key := range c.lambdas {
+		lambdas = append(lambdas, key)
+	}
+	sort.Slice(lambdas, func(i, j int) bool {
+		if lambdas[i].uid != lambdas[j].uid {
+			return lambdas[i].uid < lambdas[j].uid
+		}
+		return lambdas[i].alias < lambdas[j].alias
+	})
+
+	w.header("requests_total", "counter", "Number of processed requests")
+	for _, key := range lambdas {
+		lm := c.lambdas[key]
+		statuses := make([]int, 0, len(lm.requests))
+		for status := range lm.requests {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			w.sample("requests_total", labels("uid", key.uid, "alias", key.alias, "status", strconv.Itoa(status)), float64(lm.requests[status]))
+		}
+	}
+
+	w.header("errors_total", "counter", "Number of failed requests by reason (timeout, canceled, limit, signal or error)")
+	for _, key := range lambdas {
+		lm := c.lambdas[key]
+		reasons := make([]string, 0, len(lm.errors))
+		for reason := range lm.errors {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			w.sample("errors_total", labels("uid", key.uid, "alias", key.alias, "reason", reason), float64(lm.errors[reason]))
+		}
+	}
+
+	w.header("running", "gauge", "Number of running invocations")
+	for _, key := range lambdas {
+		w.sample("running", labels("uid", key.uid, "alias", key.alias), float64(c.lambdas[key].running))
+	}
+
+	w.header("request_duration_seconds", "histogram", "Duration of requests")
+	for _, key := range lambdas {
+		w.histogram("request_duration_seconds", labels("uid", key.uid, "alias", key.alias), c.lambdas[key].duration)
+	}
+
+	w.header("request_size_bytes", "histogram", "Size of requests payload")
+	for _, key := range lambdas {
+		w.histogram("request_size_bytes", labels("uid", key.uid, "alias", key.alias), c.lambdas[key].requestSize)
+	}
+
+	w.header("response_size_bytes", "histogram", "Size of responses payload")
+	for _, key := range lambdas {
+		w.histogram("response_size_bytes", labels("uid", key.uid, "alias", key.alias), c.lambdas[key].responseSize)
+	}
+
+	queueNames := make([]string, 0, len(c.queues)+len(depths))
+	for name := range c.queues {
+		queueNames = append(queueNames, name)
+	}
+	for name := range depths {
+		if _, ok := c.queues[name]; !ok {
+			queueNames = append(queueNames, name)
+		}
+	}
+	sort.Strings(queueNames)
+
+	w.header("queue_depth", "gauge", "Number of requests stored in queue")
+	for _, name := range queueNames {
+		if depth, ok := depths[name]; ok {
+			w.sample("queue_depth", labels("queue", name), float64(depth))
+		}
+	}
+
+	w.header("queue_tasks_total", "counter", "Number of processed tasks by result (success or failed)")
+	for _, name := range queueNames {
+		if qm, ok := c.queues[name]; ok {
+			w.sample("queue_tasks_total", labels("queue", name, "result", "success"), float64(qm.succeeded))
+			w.sample("queue_tasks_total", labels("queue", name, "result", "failed"), float64(qm.failed))
+		}
+	}
+
+	w.header("queue_retries_total", "counter", "Number of repeated attempts to process tasks")
+	for _, name := range queueNames {
+		if qm, ok := c.queues[name]; ok {
+			w.sample("queue_retries_total", labels("queue", name), float64(qm.retries))
+		}
+	}
+
+	actions := make([]actionKey, 0, len(c.actions))
+	for key := range c.actions {
+		actions = append(actions, key)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].uid != actions[j].uid {
+			return actions[i].uid < actions[j].uid
+		}
+		return actions[i].action < actions[j].action
+	})
+
+	w.header("scheduled_actions_total", "counter", "Number of scheduled actions by result (success or failed)")
+	for _, key := range actions {
+		am := c.actions[key]
+		w.sample("scheduled_actions_total", labels("uid", key.uid, "action", key.action, "result", "success"), float64(am.succeeded))
+		w.sample("scheduled_actions_total", labels("uid", key.uid, "action", key.action, "result", "failed"), float64(am.failed))
+	}
+
+	w.header("scheduled_action_duration_seconds", "histogram", "Duration of scheduled actions")
+	for _, key := range actions {
+		w.histogram("scheduled_action_duration_seconds", labels("uid", key.uid, "action", key.action), c.actions[key].duration)
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.out.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// pairs of name and value
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(pairs[i+1]))
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
+
+type writer struct {
+	out *bufio.Writer
+	err error
+}
+
+func (w *writer) header(name, kind, help string) {
+	w.printf("# HELP %s%s %s\n# TYPE %s%s %s\n", prefix, name, help, prefix, name, kind)
+}
+
+func (w *writer) sample(name, labels string, value float64) {
+	w.printf("%s%s{%s} %s\n", prefix, name, labels, formatFloat(value))
+}
+
+func (w *writer) histogram(name, labels string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		w.sample(name+"_bucket", labels+`,le="`+formatFloat(bound)+`"`, float64(cumulative))
+	}
+	w.sample(name+"_bucket", labels+`,le="+Inf"`, float64(h.count))
+	w.sample(name+"_sum", labels, h.sum)
+	w.sample(name+"_count", labels, float64(h.count))
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.out, format, args...)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
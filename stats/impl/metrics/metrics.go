@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+// Default buckets for histograms
+var (
+	DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}    // seconds
+	SizeBuckets     = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304} // bytes
+)
+
+// Source of queues depths (see queuemanager)
+type QueueSource interface {
+	// Depths of all queues by name
+	Depths() map[string]int64
+}
+
+// Source of existing lambdas (see platform)
+type LambdaSource interface {
+	// Get lambda by UID (if indexed)
+	FindByUID(uid string) (*application.Definition, error)
+	// Get lambda by link/alias (if indexed)
+	FindByLink(link string) (*application.Definition, error)
+}
+
+// New collector of platform metrics. Collector implements stats.Recorder and stats.Monitor for requests,
+// queuemanager.Observer for queues and receives outcomes of scheduled actions. Metrics are exposed in
+// Prometheus text format by Handler.
+func New() *collector {
+	return &collector{
+		lambdas: make(map[lambdaKey]*lambdaMetrics),
+		queues:  make(map[string]*queueMetrics),
+		actions: make(map[actionKey]*actionMetrics),
+	}
+}
+
+type collector struct {
+	lock    sync.Mutex
+	lambdas map[lambdaKey]*lambdaMetrics
+	queues  map[string]*queueMetrics
+	actions map[actionKey]*actionMetrics
+	source  QueueSource
+	index   LambdaSource
+}
+
+type lambdaKey struct {
+	uid   string
+	alias string
+}
+
+type lambdaMetrics struct {
+	requests     map[int]uint64    // by status
+	errors       map[string]uint64 // by reason
+	running      int64
+	duration     *histogram
+	requestSize  *histogram
+	responseSize *histogram
+}
+
+type queueMetrics struct {
+	succeeded uint64
+	failed    uint64
+	retries   uint64
+}
+
+type actionKey struct {
+	uid    string
+	action string
+}
+
+type actionMetrics struct {
+	succeeded uint64
+	failed    uint64
+	duration  *histogram
+}
+
+// Track finished request. If source of lambdas is set, requests to unknown lambdas (including queues and topics)
+// are skipped.
+func (c *collector) Track(record stats.Record) {
+	c.lock.Lock()
+	index := c.index
+	c.lock.Unlock()
+	if index != nil && !exists(index, lambdaKey{uid: record.UID, alias: record.Alias}) {
+		return // outside of lock - index may be busy
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lm := c.lambda(record.UID, record.Alias)
+	lm.requests[record.Status]++
+	if record.Err != "" {
+		reason := record.KillReason
+		if reason == "" {
+			reason = "error"
+		}
+		lm.errors[reason]++
+	}
+	if record.End.After(record.Begin) {
+		lm.duration.Observe(record.End.Sub(record.Begin).Seconds())
+	}
+	lm.requestSize.Observe(float64(record.BytesIn))
+	lm.responseSize.Observe(float64(record.BytesOut))
+}
+
+// Started invocation. Returned function decreases number of running invocations and can be called several times.
+func (c *collector) Started(uid, alias string) func() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lm := c.lambda(uid, alias)
+	lm.running++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			lm.running--
+		})
+	}
+}
+
+// Processed task from queue
+func (c *collector) Processed(queue string, attempts int, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	qm, ok := c.queues[queue]
+	if !ok {
+		qm = &queueMetrics{}
+		c.queues[queue] = qm
+	}
+	if err != nil {
+		qm.failed++
+	} else {
+		qm.succeeded++
+	}
+	if attempts > 1 {
+		qm.retries += uint64(attempts - 1)
+	}
+}
+
+// Scheduled actions outcomes
+func (c *collector) Scheduled(results []types.ScheduleResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, res := range results {
+		key := actionKey{uid: res.UID, action: res.Action}
+		am, ok := c.actions[key]
+		if !ok {
+			am = &actionMetrics{duration: newHistogram(DurationBuckets)}
+			c.actions[key] = am
+		}
+		if res.Err != nil {
+			am.failed++
+		} else {
+			am.succeeded++
+		}
+		am.duration.Observe(res.End.Sub(res.Begin).Seconds())
+	}
+}
+
+// Queues sets source of queues depths which will be requested on each exposition.
+func (c *collector) Queues(source QueueSource) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.source = source
+}
+
+// Lambdas sets source of existing lambdas. Requests to unknown lambdas are not tracked, and metrics of removed
+// lambdas (or aliases) are dropped on each exposition.
+func (c *collector) Lambdas(index LambdaSource) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.index = index
+}
+
+// remove metrics of lambdas and aliases which are not exist anymore (except running)
+func (c *collector) prune(index LambdaSource) {
+	c.lock.Lock()
+	lambdas := make([]lambdaKey, 0, len(c.lambdas))
+	for key := range c.lambdas {
+		lambdas = append(lambdas, key)
+	}
+	actions := make([]actionKey, 0, len(c.actions))
+	for key := range c.actions {
+		actions = append(actions, key)
+	}
+	c.lock.Unlock()
+
+	var removed []lambdaKey
+	for _, key := range lambdas {
+		if !exists(index, key) {
+			removed = append(removed, key)
+		}
+	}
+	var removedActions []actionKey
+	for _, key := range actions {
+		if !exists(index, lambdaKey{uid: key.uid}) {
+			removedActions = append(removedActions, key)
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, key := range removed {
+		if lm, ok := c.lambdas[key]; ok && lm.running <= 0 {
+			delete(c.lambdas, key)
+		}
+	}
+	for _, key := range removedActions {
+		delete(c.actions, key)
+	}
+}
+
+// check that lambda exists and alias (if set) is linked to it
+func exists(index LambdaSource, key lambdaKey) bool {
+	if key.alias != "" {
+		def, err := index.FindByLink(key.alias)
+		return err == nil && def.UID == key.uid
+	}
+	_, err := index.FindByUID(key.uid)
+	return err == nil
+}
+
+func (c *collector) lambda(uid, alias string) *lambdaMetrics {
+	key := lambdaKey{uid: uid, alias: alias}
+	lm, ok := c.lambdas[key]
+	if !ok {
+		lm = &lambdaMetrics{
+			requests:     make(map[int]uint64),
+			errors:       make(map[string]uint64),
+			duration:     newHistogram(DurationBuckets),
+			requestSize:  newHistogram(SizeBuckets),
+			responseSize: newHistogram(SizeBuckets),
+		}
+		c.lambdas[key] = lm
+	}
+	return lm
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64 // non-cumulative, last one for +Inf
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) Observe(value float64) {
+	i := 0
+	for i < len(h.bounds) && value > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+type staticDepths map[string]int64
+
+func (sd staticDepths) Depths() map[string]int64 { return sd }
+
+func TestCollector_Write(t *testing.T) {
+	c := New()
+	c.Queues(staticDepths{"jobs": 3})
+	begin := time.Now()
+	c.Track(stats.Record{UID: "app", Alias: "hello", Status: 200, Begin: begin, End: begin.Add(20 * time.Millisecond), BytesIn: 100, BytesOut: 2000})
+	c.Track(stats.Record{UID: "app", Status: 504, Err: "timeout", KillReason: stats.KillTimeout, Begin: begin, End: begin.Add(2 * time.Second)})
+	done := c.Started("app", "")
+	c.Started("app", "hello")()
+	c.Processed("jobs", 3, nil)
+	c.Processed("jobs", 1, errors.New("failed"))
+	c.Scheduled([]types.ScheduleResult{{UID: "app", Action: "backup", Begin: begin, End: begin.Add(time.Second)}})
+
+	var out bytes.Buffer
+	err := c.Write(&out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	text := out.String()
+	assert.Contains(t, text, "# TYPE trusted_cgi_requests_total counter\n")
+	assert.Contains(t, text, `trusted_cgi_requests_total{uid="app",alias="hello",status="200"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_requests_total{uid="app",alias="",status="504"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_errors_total{uid="app",alias="",reason="timeout"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_running{uid="app",alias=""} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_running{uid="app",alias="hello"} 0`+"\n")
+	assert.Contains(t, text, `trusted_cgi_request_duration_seconds_bucket{uid="app",alias="hello",le="0.01"} 0`+"\n")
+	assert.Contains(t, text, `trusted_cgi_request_duration_seconds_bucket{uid="app",alias="hello",le="0.025"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_request_duration_seconds_count{uid="app",alias="hello"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_response_size_bytes_sum{uid="app",alias="hello"} 2000`+"\n")
+	assert.Contains(t, text, `trusted_cgi_queue_depth{queue="jobs"} 3`+"\n")
+	assert.Contains(t, text, `trusted_cgi_queue_tasks_total{queue="jobs",result="success"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_queue_tasks_total{queue="jobs",result="failed"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_queue_retries_total{queue="jobs"} 2`+"\n")
+	assert.Contains(t, text, `trusted_cgi_scheduled_actions_total{uid="app",action="backup",result="success"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_scheduled_action_duration_seconds_bucket{uid="app",action="backup",le="+Inf"} 1`+"\n")
+
+	done()
+	done()
+	out.Reset()
+	_ = c.Write(&out)
+	assert.Contains(t, out.String(), `trusted_cgi_running{uid="app",alias=""} 0`+"\n")
+}
+
+type staticLambdas map[string]string // alias or uid => uid
+
+func (sl staticLambdas) FindByUID(uid string) (*application.Definition, error) {
+	for _, v := range sl {
+		if v == uid {
+			return &application.Definition{UID: uid}, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+func (sl staticLambdas) FindByLink(link string) (*application.Definition, error) {
+	if uid, ok := sl[link]; ok {
+		return &application.Definition{UID: uid}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func TestCollector_Lambdas(t *testing.T) {
+	c := New()
+	index := staticLambdas{"app": "app", "hello": "app", "old": "removed"}
+	c.Lambdas(index)
+	begin := time.Now()
+	c.Track(stats.Record{UID: "app", Alias: "hello", Status: 200, Begin: begin, End: begin})
+	c.Track(stats.Record{UID: "removed", Status: 200, Begin: begin, End: begin})
+	c.Track(stats.Record{UID: "missing", Status: 404, Begin: begin, End: begin})
+	c.Track(stats.Record{UID: "my-queue", Status: 204, Begin: begin, End: begin})
+	c.Scheduled([]types.ScheduleResult{{UID: "removed", Action: "backup", Begin: begin, End: begin}})
+
+	var out bytes.Buffer
+	assert.NoError(t, c.Write(&out))
+	text := out.String()
+	assert.Contains(t, text, `trusted_cgi_requests_total{uid="app",alias="hello",status="200"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_requests_total{uid="removed",alias="",status="200"} 1`+"\n")
+	assert.Contains(t, text, `trusted_cgi_scheduled_actions_total{uid="removed",action="backup",result="success"} 1`+"\n")
+	assert.NotContains(t, text, `uid="missing"`)
+	assert.NotContains(t, text, `uid="my-queue"`)
+
+	delete(index, "old")
+	delete(index, "hello")
+	out.Reset()
+	assert.NoError(t, c.Write(&out))
+	text = out.String()
+	assert.NotContains(t, text, `uid="removed"`)
+	assert.NotContains(t, text, `alias="hello"`)
+}
+
+func TestCollector_Handler(t *testing.T) {
+	handler := New().Handler("secret")
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "secret")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "bare token without Bearer prefix")
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "# TYPE trusted_cgi_running gauge")
+}
+
+func TestLabels_escape(t *testing.T) {
+	assert.Equal(t, `a="x\"y\\z\n"`, labels("a", "x\"y\\z\n"))
+}
@@ -0,0 +1,324 @@
+package rollup
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+const (
+	BucketSize       = time.Minute // time resolution of aggregates
+	DefaultRetention = 24 * time.Hour
+	maxCallers       = 128 // maximum number of distinct remote addresses per bucket
+	topCallers       = 10  // number of callers in summary
+)
+
+// Latency histogram bounds in milliseconds: exponential from 0.5ms to ~20 minutes with step ~20%
+var latencyBounds = func() []float64 {
+	var bounds []float64
+	for v := 0.5; v < 20*60*1000; v *= 1.2 {
+		bounds = append(bounds, v)
+	}
+	return bounds
+}()
+
+// Source of existing lambdas (see platform)
+type LambdaSource interface {
+	// Get lambda by UID (if indexed)
+	FindByUID(uid string) (*application.Definition, error)
+}
+
+// New aggregator of records, which keeps per-minute buckets for each lambda and for whole project during retention
+// period. Summaries are computed from buckets only, so its cost depends on window but not on number of requests.
+// Zero or negative retention means default retention (24 hours).
+func New(retention time.Duration) *aggregator {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &aggregator{
+		retention: retention,
+		lambdas:   make(map[string]*series),
+	}
+}
+
+type aggregator struct {
+	retention time.Duration
+	lock      sync.Mutex
+	total     series
+	lambdas   map[string]*series
+	index     LambdaSource
+}
+
+// Lambdas sets source of existing lambdas. Requests to unknown lambdas (including queues and topics) are counted
+// only in project total.
+func (agg *aggregator) Lambdas(index LambdaSource) {
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+	agg.index = index
+}
+
+// Track record in aggregates. Records older than retention are ignored.
+func (agg *aggregator) Track(record stats.Record) {
+	slot := record.Begin.Truncate(BucketSize).Unix()
+	oldest := agg.oldestSlot(time.Now())
+	if slot < oldest {
+		return
+	}
+	agg.lock.Lock()
+	index := agg.index
+	agg.lock.Unlock()
+	resolved := true
+	if index != nil {
+		_, err := index.FindByUID(record.UID) // outside of lock - index may be busy
+		resolved = err == nil
+	}
+	latency := float64(record.End.Sub(record.Begin)) / float64(time.Millisecond)
+	caller := record.Request.RemoteAddress
+	if host, _, err := net.SplitHostPort(caller); err == nil {
+		caller = host
+	}
+	failed := record.Err != ""
+
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+	if agg.total.add(slot, oldest, latency, caller, failed) {
+		// once per bucket drop expired aggregates of all lambdas
+		agg.expire(oldest)
+	}
+	if !resolved {
+		return
+	}
+	s, ok := agg.lambdas[record.UID]
+	if !ok {
+		s = &series{}
+		agg.lambdas[record.UID] = s
+	}
+	s.add(slot, oldest, latency, caller, failed)
+}
+
+// Import recent (within retention) records from reader, for example to restore aggregates after restart.
+func (agg *aggregator) Import(source stats.Reader) error {
+	records, err := source.Search(stats.Query{From: time.Unix(agg.oldestSlot(time.Now()), 0)})
+	if err != nil {
+		return err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		agg.Track(records[i])
+	}
+	return nil
+}
+
+func (agg *aggregator) Summary(uid string, window time.Duration) (*stats.Summary, error) {
+	now := time.Now()
+	from := agg.windowStart(now, window)
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+	var acc bucket
+	if s, ok := agg.lambdas[uid]; ok {
+		if s.expire(agg.oldestSlot(now)) {
+			delete(agg.lambdas, uid)
+		} else {
+			s.collect(from.Unix(), &acc)
+		}
+	}
+	summary := acc.summary(from, now)
+	summary.UID = uid
+	return summary, nil
+}
+
+func (agg *aggregator) ProjectSummary(window time.Duration) (*stats.ProjectSummary, error) {
+	now := time.Now()
+	from := agg.windowStart(now, window)
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+	agg.expire(agg.oldestSlot(now))
+	var total bucket
+	agg.total.collect(from.Unix(), &total)
+	var ans = &stats.ProjectSummary{
+		Total:   *total.summary(from, now),
+		Lambdas: make([]stats.Summary, 0, len(agg.lambdas)),
+	}
+	for uid, s := range agg.lambdas {
+		var acc bucket
+		s.collect(from.Unix(), &acc)
+		if acc.requests == 0 {
+			continue
+		}
+		summary := acc.summary(from, now)
+		summary.UID = uid
+		ans.Lambdas = append(ans.Lambdas, *summary)
+	}
+	sort.Slice(ans.Lambdas, func(i, j int) bool {
+		if ans.Lambdas[i].Requests != ans.Lambdas[j].Requests {
+			return ans.Lambdas[i].Requests > ans.Lambdas[j].Requests
+		}
+		return ans.Lambdas[i].UID < ans.Lambdas[j].UID
+	})
+	return ans, nil
+}
+
+// beginning of window aligned to bucket and limited by retention
+func (agg *aggregator) windowStart(now time.Time, window time.Duration) time.Time {
+	if window <= 0 || window > agg.retention {
+		window = agg.retention
+	}
+	return now.Add(-window).Truncate(BucketSize)
+}
+
+// drop expired buckets and lambdas without buckets
+func (agg *aggregator) expire(oldest int64) {
+	agg.total.expire(oldest)
+	for uid, s := range agg.lambdas {
+		if s.expire(oldest) {
+			delete(agg.lambdas, uid)
+		}
+	}
+}
+
+func (agg *aggregator) oldestSlot(now time.Time) int64 {
+	return now.Add(-agg.retention).Truncate(BucketSize).Unix()
+}
+
+// Buckets of aggregates by time slot (unix time of bucket beginning)
+type series struct {
+	buckets map[int64]*bucket
+}
+
+// add record to bucket of slot. Returns true if new bucket created
+func (s *series) add(slot, oldest int64, latency float64, caller string, failed bool) bool {
+	if s.buckets == nil {
+		s.buckets = make(map[int64]*bucket)
+	}
+	b, ok := s.buckets[slot]
+	if !ok {
+		// new bucket is a good moment to drop expired
+		s.expire(oldest)
+		b = &bucket{}
+		s.buckets[slot] = b
+	}
+	b.add(latency, caller, failed)
+	return !ok
+}
+
+// drop buckets older than oldest slot. Returns true if no buckets left
+func (s *series) expire(oldest int64) bool {
+	for old := range s.buckets {
+		if old < oldest {
+			delete(s.buckets, old)
+		}
+	}
+	return len(s.buckets) == 0
+}
+
+func (s *series) collect(from int64, acc *bucket) {
+	for slot, b := range s.buckets {
+		if slot >= from {
+			acc.merge(b)
+		}
+	}
+}
+
+type bucket struct {
+	requests int64
+	errors   int64
+	latency  []uint32 // counts by latencyBounds, last one is for bigger values
+	maxMs    float64
+	callers  map[string]int64
+}
+
+func (b *bucket) add(latency float64, caller string, failed bool) {
+	b.requests++
+	if failed {
+		b.errors++
+	}
+	if b.latency == nil {
+		b.latency = make([]uint32, len(latencyBounds)+1)
+	}
+	b.latency[sort.SearchFloat64s(latencyBounds, latency)]++
+	if latency > b.maxMs {
+		b.maxMs = latency
+	}
+	if b.callers == nil {
+		b.callers = make(map[string]int64)
+	}
+	if _, ok := b.callers[caller]; !ok && len(b.callers) >= maxCallers {
+		caller = "" // others
+	}
+	b.callers[caller]++
+}
+
+func (b *bucket) merge(other *bucket) {
+	b.requests += other.requests
+	b.errors += other.errors
+	if other.latency != nil {
+		if b.latency == nil {
+			b.latency = make([]uint32, len(latencyBounds)+1)
+		}
+		for i, v := range other.latency {
+			b.latency[i] += v
+		}
+	}
+	if other.maxMs > b.maxMs {
+		b.maxMs = other.maxMs
+	}
+	if len(other.callers) > 0 && b.callers == nil {
+		b.callers = make(map[string]int64)
+	}
+	for caller, v := range other.callers {
+		b.callers[caller] += v
+	}
+}
+
+func (b *bucket) summary(from, to time.Time) *stats.Summary {
+	var summary = &stats.Summary{
+		From:       from,
+		To:         to,
+		Requests:   b.requests,
+		Errors:     b.errors,
+		TopCallers: make([]stats.Caller, 0),
+	}
+	if minutes := to.Sub(from).Minutes(); minutes > 0 {
+		summary.RatePerMinute = float64(b.requests) / minutes
+	}
+	if b.requests == 0 {
+		return summary
+	}
+	summary.ErrorRatio = float64(b.errors) / float64(b.requests)
+	summary.P50 = b.percentile(0.50)
+	summary.P95 = b.percentile(0.95)
+	summary.P99 = b.percentile(0.99)
+	for caller, v := range b.callers {
+		summary.TopCallers = append(summary.TopCallers, stats.Caller{Address: caller, Requests: v})
+	}
+	sort.Slice(summary.TopCallers, func(i, j int) bool {
+		if summary.TopCallers[i].Requests != summary.TopCallers[j].Requests {
+			return summary.TopCallers[i].Requests > summary.TopCallers[j].Requests
+		}
+		return summary.TopCallers[i].Address < summary.TopCallers[j].Address
+	})
+	if len(summary.TopCallers) > topCallers {
+		summary.TopCallers = summary.TopCallers[:topCallers]
+	}
+	return summary
+}
+
+// upper bound of bucket which contains percentile, limited by maximum observed value
+func (b *bucket) percentile(q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(b.requests)))
+	var cumulative uint64
+	for i, v := range b.latency {
+		cumulative += uint64(v)
+		if cumulative >= rank {
+			if i < len(latencyBounds) && latencyBounds[i] < b.maxMs {
+				return latencyBounds[i]
+			}
+			return b.maxMs
+		}
+	}
+	return b.maxMs
+}
@@ -0,0 +1,116 @@
+package rollup
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/application"
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func testRecord(uid, remote string, begin time.Time, latency time.Duration, failed bool) stats.Record {
+	record := stats.Record{
+		UID:     uid,
+		Request: types.Request{Method: "GET", RemoteAddress: remote},
+		Begin:   begin,
+		End:     begin.Add(latency),
+	}
+	if failed {
+		record.Err = "failed"
+	}
+	return record
+}
+
+func TestAggregator_Summary(t *testing.T) {
+	agg := New(time.Hour)
+	now := time.Now()
+	// 100 requests with latency 1..100ms, every 10th is failed
+	for i := 1; i <= 100; i++ {
+		remote := "10.0.0." + strconv.Itoa(i%3) + ":1234"
+		agg.Track(testRecord("app", remote, now.Add(-time.Duration(i)*time.Second), time.Duration(i)*time.Millisecond, i%10 == 0))
+	}
+	agg.Track(testRecord("other", "10.0.0.1:1", now, time.Millisecond, false))
+	agg.Track(testRecord("app", "10.0.0.1:1", now.Add(-30*time.Minute), time.Millisecond, false)) // out of window
+	agg.Track(testRecord("app", "10.0.0.1:1", now.Add(-2*time.Hour), time.Millisecond, false))    // out of retention
+
+	summary, err := agg.Summary("app", 10*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "app", summary.UID)
+	assert.Equal(t, int64(100), summary.Requests)
+	assert.Equal(t, int64(10), summary.Errors)
+	assert.InDelta(t, 0.1, summary.ErrorRatio, 0.001)
+	assert.InDelta(t, 100/summary.To.Sub(summary.From).Minutes(), summary.RatePerMinute, 0.001)
+	// approximation error is bucket width (~20%)
+	assert.InDelta(t, 50, summary.P50, 12)
+	assert.InDelta(t, 95, summary.P95, 5)
+	assert.InDelta(t, 99, summary.P99, 2)
+	require.Len(t, summary.TopCallers, 3)
+	assert.Equal(t, stats.Caller{Address: "10.0.0.1", Requests: 34}, summary.TopCallers[0])
+
+	project, err := agg.ProjectSummary(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(102), project.Total.Requests)
+	require.Len(t, project.Lambdas, 2)
+	assert.Equal(t, "app", project.Lambdas[0].UID)
+	assert.Equal(t, "other", project.Lambdas[1].UID)
+
+	// wide window - including old requests
+	wide, err := agg.Summary("app", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), wide.Requests)
+
+	empty, err := agg.Summary("unknown", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), empty.Requests)
+	assert.NotNil(t, empty.TopCallers)
+}
+
+func TestAggregator_Import(t *testing.T) {
+	source := memlog.New(10)
+	now := time.Now()
+	source.Track(testRecord("app", "10.0.0.1:1", now.Add(-48*time.Hour), time.Millisecond, false))
+	source.Track(testRecord("app", "10.0.0.1:1", now.Add(-time.Minute), time.Millisecond, false))
+	source.Track(testRecord("app", "10.0.0.1:1", now, time.Millisecond, true))
+
+	agg := New(0)
+	require.NoError(t, agg.Import(source))
+	summary, err := agg.Summary("app", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), summary.Requests)
+	assert.Equal(t, int64(1), summary.Errors)
+}
+
+type staticLambdas map[string]bool
+
+func (sl staticLambdas) FindByUID(uid string) (*application.Definition, error) {
+	if !sl[uid] {
+		return nil, os.ErrNotExist
+	}
+	return &application.Definition{UID: uid}, nil
+}
+
+func TestAggregator_Lambdas(t *testing.T) {
+	agg := New(time.Hour)
+	agg.Lambdas(staticLambdas{"app": true})
+	now := time.Now()
+	agg.Track(testRecord("app", "10.0.0.1:1", now, time.Millisecond, false))
+	agg.Track(testRecord("missing", "10.0.0.1:1", now, time.Millisecond, true))
+	agg.Track(testRecord("my-queue", "10.0.0.1:1", now, time.Millisecond, false))
+
+	// expired series should be removed
+	agg.lambdas["removed"] = &series{buckets: map[int64]*bucket{now.Add(-2 * time.Hour).Unix(): {requests: 1}}}
+
+	summary, err := agg.ProjectSummary(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), summary.Total.Requests)
+	require.Len(t, summary.Lambdas, 1)
+	assert.Equal(t, "app", summary.Lambdas[0].UID)
+	assert.Len(t, agg.lambdas, 1)
+}
@@ -0,0 +1,228 @@
+package seglog
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+const (
+	DefaultSegmentSize = 4 * 1024 * 1024 // size of segment after which new one will be started
+	dataExt            = ".log"
+	indexExt           = ".idx"
+)
+
+// New append-only segmented log of records in directory. Records are written to the active (last) segment. Once
+// segment becomes bigger than segment size, new segment is started. Each segment has an index (UID, method, begin
+// time and error flag of records), so queries decode only matched records.
+//
+// Retention removes the oldest segments if the newest record in segment is older than maxAge or if total size of
+// segments is bigger than maxSize. The active segment is never removed. Zero values disable corresponding retention.
+func New(directory string, maxAge time.Duration, maxSize int64) (*segLog, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	sl := &segLog{
+		directory:   directory,
+		maxAge:      maxAge,
+		maxSize:     maxSize,
+		segmentSize: DefaultSegmentSize,
+	}
+	if err := sl.open(); err != nil {
+		sl.Close()
+		return nil, err
+	}
+	return sl, nil
+}
+
+type segLog struct {
+	directory   string
+	maxAge      time.Duration
+	maxSize     int64
+	segmentSize int64
+	lock        sync.RWMutex
+	segments    []*segment // from oldest to newest, the last one is active
+}
+
+func (sl *segLog) open() error {
+	list, err := filepath.Glob(filepath.Join(sl.directory, "*"+dataExt))
+	if err != nil {
+		return err
+	}
+	var sequences []uint64
+	for _, file := range list {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(file), dataExt), 16, 64)
+		if err != nil {
+			continue // not a segment
+		}
+		sequences = append(sequences, seq)
+	}
+	sort.Slice(sequences, func(i, j int) bool {
+		return sequences[i] < sequences[j]
+	})
+	for i, seq := range sequences {
+		seg, err := openSegment(sl.directory, seq, i == len(sequences)-1)
+		if err != nil {
+			return fmt.Errorf("open segment %d: %w", seq, err)
+		}
+		sl.segments = append(sl.segments, seg)
+	}
+	if len(sl.segments) == 0 {
+		seg, err := createSegment(sl.directory, 0)
+		if err != nil {
+			return fmt.Errorf("create segment: %w", err)
+		}
+		sl.segments = append(sl.segments, seg)
+	}
+	sl.cleanup(time.Now())
+	return nil
+}
+
+// Track record by appending it to the active segment. Errors are logged.
+func (sl *segLog) Track(record stats.Record) {
+	data, err := record.MarshalMsg(nil)
+	if err != nil {
+		log.Println("[ERROR] stats: encode record:", err)
+		return
+	}
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	active := sl.segments[len(sl.segments)-1]
+	if active.size > 0 && active.size+int64(len(data)) > sl.segmentSize {
+		next, err := sl.rotate()
+		if err != nil {
+			log.Println("[ERROR] stats: rotate segment:", err)
+		} else {
+			active = next
+		}
+	}
+	if err := active.append(data, record); err != nil {
+		log.Println("[ERROR] stats: write record:", err)
+	}
+}
+
+func (sl *segLog) LastByUID(uid string, limit int) ([]stats.Record, error) {
+	if limit <= 0 {
+		return []stats.Record{}, nil
+	}
+	return sl.Search(stats.Query{UID: uid, Limit: limit})
+}
+
+func (sl *segLog) Last(limit int) ([]stats.Record, error) {
+	if limit <= 0 {
+		return []stats.Record{}, nil
+	}
+	return sl.Search(stats.Query{Limit: limit})
+}
+
+// Search records from newest to oldest
+func (sl *segLog) Search(query stats.Query) ([]stats.Record, error) {
+	sl.lock.RLock()
+	defer sl.lock.RUnlock()
+	var ans = make([]stats.Record, 0)
+	for i := len(sl.segments) - 1; i >= 0; i-- {
+		seg := sl.segments[i]
+		if len(seg.entries) == 0 || !seg.overlaps(query.From, query.To) {
+			continue
+		}
+		for j := len(seg.entries) - 1; j >= 0; j-- {
+			if query.Limit > 0 && len(ans) >= query.Limit {
+				return ans, nil
+			}
+			e := seg.entries[j]
+			if !query.MatchIndex(e.uid, e.method, time.Unix(0, e.begin), e.failed) {
+				continue
+			}
+			record, err := seg.read(e)
+			if err != nil {
+				return nil, fmt.Errorf("read record from segment %d: %w", seg.seq, err)
+			}
+			ans = append(ans, *record)
+		}
+	}
+	return ans, nil
+}
+
+// Import records from source (for example - dump of memlog) if log is empty.
+func (sl *segLog) Import(source stats.Reader) error {
+	sl.lock.RLock()
+	empty := len(sl.segments) == 1 && len(sl.segments[0].entries) == 0
+	sl.lock.RUnlock()
+	if !empty {
+		return nil
+	}
+	records, err := source.Last(math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		sl.Track(records[i])
+	}
+	return nil
+}
+
+// Dump synchronizes active segment to disk and applies retention.
+func (sl *segLog) Dump() error {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	sl.cleanup(time.Now())
+	return sl.segments[len(sl.segments)-1].sync()
+}
+
+// Close all segments. Log should not be used after close
+func (sl *segLog) Close() error {
+	sl.lock.Lock()
+	defer sl.lock.Unlock()
+	var err error
+	for _, seg := range sl.segments {
+		if closeErr := seg.close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	sl.segments = nil
+	return err
+}
+
+func (sl *segLog) rotate() (*segment, error) {
+	active := sl.segments[len(sl.segments)-1]
+	if err := active.seal(); err != nil {
+		return nil, err
+	}
+	next, err := createSegment(sl.directory, active.seq+1)
+	if err != nil {
+		return nil, err
+	}
+	sl.segments = append(sl.segments, next)
+	sl.cleanup(time.Now())
+	return next, nil
+}
+
+// remove the oldest segments (except active) by retention rules
+func (sl *segLog) cleanup(now time.Time) {
+	var total int64
+	for _, seg := range sl.segments {
+		total += seg.size
+	}
+	for len(sl.segments) > 1 {
+		oldest := sl.segments[0]
+		expired := sl.maxAge > 0 && oldest.maxBegin < now.Add(-sl.maxAge).UnixNano()
+		oversize := sl.maxSize > 0 && total > sl.maxSize
+		if !expired && !oversize {
+			break
+		}
+		if err := oldest.remove(); err != nil {
+			log.Println("[ERROR] stats: remove segment", oldest.seq, ":", err)
+		}
+		total -= oldest.size
+		sl.segments = sl.segments[1:]
+	}
+}
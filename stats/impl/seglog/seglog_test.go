@@ -0,0 +1,158 @@
+package seglog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/reddec/trusted-cgi/stats"
+	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/types"
+)
+
+func testRecord(uid, method string, begin time.Time, failed bool) stats.Record {
+	record := stats.Record{
+		UID:     uid,
+		Request: types.Request{Method: method, Path: "/" + uid},
+		Begin:   begin,
+		End:     begin.Add(time.Millisecond),
+		Status:  200,
+	}
+	if failed {
+		record.Err = "failed"
+		record.Status = 502
+	}
+	return record
+}
+
+func TestSegLog_Search(t *testing.T) {
+	dir := t.TempDir()
+	sl, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	defer sl.Close()
+
+	begin := time.Now().Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		method := "GET"
+		if i%2 == 0 {
+			method = "POST"
+		}
+		sl.Track(testRecord("app-"+strconv.Itoa(i%3), method, begin.Add(time.Duration(i)*time.Second), i%4 == 0))
+	}
+
+	all, err := sl.Search(stats.Query{})
+	require.NoError(t, err)
+	require.Len(t, all, 10)
+	assert.Equal(t, begin.Add(9*time.Second).UnixNano(), all[0].Begin.UnixNano(), "newest first")
+
+	last, err := sl.Last(3)
+	require.NoError(t, err)
+	assert.Equal(t, all[:3], last)
+
+	byUID, err := sl.LastByUID("app-1", 10)
+	require.NoError(t, err)
+	assert.Len(t, byUID, 3) // 1, 4, 7
+
+	failed, err := sl.Search(stats.Query{ErrorsOnly: true})
+	require.NoError(t, err)
+	assert.Len(t, failed, 3) // 0, 4, 8
+
+	posts, err := sl.Search(stats.Query{Method: "post", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "POST", posts[0].Request.Method)
+
+	ranged, err := sl.Search(stats.Query{From: begin.Add(2 * time.Second), To: begin.Add(5 * time.Second)})
+	require.NoError(t, err)
+	assert.Len(t, ranged, 3) // 2, 3, 4
+}
+
+func TestSegLog_Retention(t *testing.T) {
+	dir := t.TempDir()
+	sl, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	defer sl.Close()
+	sl.segmentSize = 512
+
+	begin := time.Now().Add(-time.Hour)
+	for i := 0; i < 50; i++ {
+		sl.Track(testRecord("app", "GET", begin.Add(time.Duration(i)*time.Second), false))
+	}
+	require.True(t, len(sl.segments) > 3, "should be rotated")
+	all, err := sl.Search(stats.Query{})
+	require.NoError(t, err)
+	assert.Len(t, all, 50)
+
+	// by size
+	sl.maxSize = 3 * sl.segmentSize
+	require.NoError(t, sl.Dump())
+	assert.True(t, len(sl.segments) <= 4)
+	kept, err := sl.Search(stats.Query{})
+	require.NoError(t, err)
+	assert.True(t, len(kept) < 50)
+	assert.Equal(t, all[:len(kept)], kept, "newest should be kept")
+
+	// by age - only active segment is left
+	sl.maxAge = time.Minute
+	require.NoError(t, sl.Dump())
+	assert.Len(t, sl.segments, 1)
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestSegLog_Restore(t *testing.T) {
+	dir := t.TempDir()
+	sl, err := New(dir, 0, 0)
+	require.NoError(t, err)
+	begin := time.Now().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		sl.Track(testRecord("app", "GET", begin.Add(time.Duration(i)*time.Second), false))
+	}
+	require.NoError(t, sl.Close())
+
+	// lost index and broken tail of data
+	dataFile, idxFile := segmentFiles(dir, 0)
+	require.NoError(t, os.Remove(idxFile))
+	f, err := os.OpenFile(dataFile, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0x8f, 0xa3, 'u'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	sl, err = New(dir, 0, 0)
+	require.NoError(t, err)
+	defer sl.Close()
+	sl.Track(testRecord("app", "GET", begin.Add(5*time.Second), false))
+
+	all, err := sl.Search(stats.Query{})
+	require.NoError(t, err)
+	require.Len(t, all, 6)
+	for i, record := range all {
+		assert.Equal(t, begin.Add(time.Duration(5-i)*time.Second).UnixNano(), record.Begin.UnixNano())
+	}
+}
+
+func TestSegLog_Import(t *testing.T) {
+	source := memlog.New(10)
+	begin := time.Now().Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		source.Track(testRecord("app", "GET", begin.Add(time.Duration(i)*time.Second), false))
+	}
+
+	sl, err := New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	defer sl.Close()
+	require.NoError(t, sl.Import(source))
+	require.NoError(t, sl.Import(source)) // should be ignored for non-empty log
+
+	all, err := sl.Search(stats.Query{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, begin.Add(2*time.Second).UnixNano(), all[0].Begin.UnixNano())
+}
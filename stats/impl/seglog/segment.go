@@ -0,0 +1,285 @@
+package seglog
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/reddec/trusted-cgi/stats"
+)
+
+// Segment is a pair of files: data (encoded records one by one) and index (entry per record)
+type segment struct {
+	seq      uint64
+	dataFile string
+	idxFile  string
+	data     *os.File
+	index    *os.File // opened only for active segment
+	size     int64    // size of valid data
+	entries  []entry
+	minBegin int64 // unix nano
+	maxBegin int64 // unix nano
+}
+
+// Indexed information about record
+type entry struct {
+	offset int64
+	size   int64
+	begin  int64 // unix nano
+	uid    string
+	method string
+	failed bool
+}
+
+func segmentFiles(directory string, seq uint64) (string, string) {
+	name := fmt.Sprintf("%016x", seq)
+	return filepath.Join(directory, name+dataExt), filepath.Join(directory, name+indexExt)
+}
+
+func createSegment(directory string, seq uint64) (*segment, error) {
+	dataFile, idxFile := segmentFiles(directory, seq)
+	data, err := os.OpenFile(dataFile, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(idxFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		_ = data.Close()
+		return nil, err
+	}
+	return &segment{
+		seq:      seq,
+		dataFile: dataFile,
+		idxFile:  idxFile,
+		data:     data,
+		index:    index,
+		minBegin: math.MaxInt64,
+		maxBegin: math.MinInt64,
+	}, nil
+}
+
+// Open existent segment and load index. Missed or broken part of index is restored from data, broken tail of data
+// (ex: after crash) is truncated.
+func openSegment(directory string, seq uint64, active bool) (*segment, error) {
+	dataFile, idxFile := segmentFiles(directory, seq)
+	data, err := os.OpenFile(dataFile, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{
+		seq:      seq,
+		dataFile: dataFile,
+		idxFile:  idxFile,
+		data:     data,
+		minBegin: math.MaxInt64,
+		maxBegin: math.MinInt64,
+	}
+	if err := seg.load(); err != nil {
+		_ = data.Close()
+		return nil, err
+	}
+	if !active {
+		return seg, nil
+	}
+	seg.index, err = os.OpenFile(idxFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		_ = data.Close()
+		return nil, err
+	}
+	return seg, nil
+}
+
+func (seg *segment) load() error {
+	stat, err := seg.data.Stat()
+	if err != nil {
+		return err
+	}
+	dataSize := stat.Size()
+
+	// read index till the first broken entry
+	content, err := os.ReadFile(seg.idxFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var validIndex int64
+	for len(content) > 0 {
+		e, rest, err := decodeEntry(content)
+		if err != nil || e.offset != seg.size || e.offset+e.size > dataSize {
+			break
+		}
+		seg.add(e)
+		validIndex += int64(len(content) - len(rest))
+		content = rest
+	}
+
+	// restore index for records which are not indexed
+	tail := make([]byte, dataSize-seg.size)
+	if _, err := seg.data.ReadAt(tail, seg.size); err != nil && err != io.EOF {
+		return err
+	}
+	var restored []byte
+	for len(tail) > 0 {
+		var record stats.Record
+		rest, err := record.UnmarshalMsg(tail)
+		if err != nil {
+			break
+		}
+		e := newEntry(seg.size, int64(len(tail)-len(rest)), record)
+		seg.add(e)
+		restored = e.appendTo(restored)
+		tail = rest
+	}
+	if seg.size != dataSize {
+		if err := seg.data.Truncate(seg.size); err != nil {
+			return fmt.Errorf("truncate broken data: %w", err)
+		}
+	}
+
+	index, err := os.OpenFile(seg.idxFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	if err := index.Truncate(validIndex); err != nil {
+		return fmt.Errorf("truncate broken index: %w", err)
+	}
+	if _, err := index.WriteAt(restored, validIndex); err != nil {
+		return fmt.Errorf("restore index: %w", err)
+	}
+	return nil
+}
+
+func (seg *segment) append(data []byte, record stats.Record) error {
+	if _, err := seg.data.WriteAt(data, seg.size); err != nil {
+		return err
+	}
+	e := newEntry(seg.size, int64(len(data)), record)
+	if _, err := seg.index.Write(e.appendTo(nil)); err != nil {
+		return err
+	}
+	seg.add(e)
+	return nil
+}
+
+func (seg *segment) add(e entry) {
+	seg.entries = append(seg.entries, e)
+	seg.size = e.offset + e.size
+	if e.begin < seg.minBegin {
+		seg.minBegin = e.begin
+	}
+	if e.begin > seg.maxBegin {
+		seg.maxBegin = e.begin
+	}
+}
+
+func (seg *segment) read(e entry) (*stats.Record, error) {
+	data := make([]byte, e.size)
+	if _, err := seg.data.ReadAt(data, e.offset); err != nil {
+		return nil, err
+	}
+	var record stats.Record
+	_, err := record.UnmarshalMsg(data)
+	return &record, err
+}
+
+// check that segment may contain records in time range (zero time means unbounded)
+func (seg *segment) overlaps(from, to time.Time) bool {
+	if !from.IsZero() && seg.maxBegin < from.UnixNano() {
+		return false
+	}
+	if !to.IsZero() && seg.minBegin >= to.UnixNano() {
+		return false
+	}
+	return true
+}
+
+func (seg *segment) sync() error {
+	if err := seg.data.Sync(); err != nil {
+		return err
+	}
+	if seg.index != nil {
+		return seg.index.Sync()
+	}
+	return nil
+}
+
+// close index of segment, segment will be used only for reading
+func (seg *segment) seal() error {
+	if err := seg.sync(); err != nil {
+		return err
+	}
+	err := seg.index.Close()
+	seg.index = nil
+	return err
+}
+
+func (seg *segment) close() error {
+	if seg.index != nil {
+		_ = seg.index.Close()
+	}
+	return seg.data.Close()
+}
+
+func (seg *segment) remove() error {
+	_ = seg.close()
+	if err := os.Remove(seg.idxFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(seg.dataFile)
+}
+
+func newEntry(offset, size int64, record stats.Record) entry {
+	return entry{
+		offset: offset,
+		size:   size,
+		begin:  record.Begin.UnixNano(),
+		uid:    record.UID,
+		method: record.Request.Method,
+		failed: record.Err != "",
+	}
+}
+
+func (e entry) appendTo(out []byte) []byte {
+	out = msgp.AppendArrayHeader(out, 6)
+	out = msgp.AppendInt64(out, e.offset)
+	out = msgp.AppendInt64(out, e.size)
+	out = msgp.AppendInt64(out, e.begin)
+	out = msgp.AppendString(out, e.uid)
+	out = msgp.AppendString(out, e.method)
+	out = msgp.AppendBool(out, e.failed)
+	return out
+}
+
+func decodeEntry(data []byte) (e entry, rest []byte, err error) {
+	var n uint32
+	n, data, err = msgp.ReadArrayHeaderBytes(data)
+	if err != nil {
+		return
+	}
+	if n != 6 {
+		err = fmt.Errorf("unexpected number of fields in index entry: %d", n)
+		return
+	}
+	if e.offset, data, err = msgp.ReadInt64Bytes(data); err != nil {
+		return
+	}
+	if e.size, data, err = msgp.ReadInt64Bytes(data); err != nil {
+		return
+	}
+	if e.begin, data, err = msgp.ReadInt64Bytes(data); err != nil {
+		return
+	}
+	if e.uid, data, err = msgp.ReadStringBytes(data); err != nil {
+		return
+	}
+	if e.method, data, err = msgp.ReadStringBytes(data); err != nil {
+		return
+	}
+	e.failed, rest, err = msgp.ReadBoolBytes(data)
+	return
+}
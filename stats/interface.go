@@ -9,25 +9,47 @@ import (
 
 // Tracking record
 type Record struct {
-	UID     string        `json:"uid" msg:"uid,omitempty"`             // app UID
-	Err     string        `json:"error,omitempty" msg:"err,omitempty"` // optional error
-	Request types.Request `json:"request" msg:"req,omitempty"`         // incoming request
-	Begin   time.Time     `json:"begin" msg:"beg,omitempty"`           // started time
-	End     time.Time     `json:"end" msg:"end,omitempty"`             // ended time
+	UID        string        `json:"uid" msg:"uid,omitempty"`                    // app UID
+	Err        string        `json:"error,omitempty" msg:"err,omitempty"`        // optional error
+	Request    types.Request `json:"request" msg:"req,omitempty"`                // incoming request
+	Begin      time.Time     `json:"begin" msg:"beg,omitempty"`                  // started time
+	End        time.Time     `json:"end" msg:"end,omitempty"`                    // ended time
+	Alias      string        `json:"alias,omitempty" msg:"alias,omitempty"`      // alias (link) used for request
+	Status     int           `json:"status,omitempty" msg:"status,omitempty"`    // returned HTTP status
+	ExitCode   int           `json:"exit_code,omitempty" msg:"exit,omitempty"`   // exit code of process (-1 if killed by signal)
+	BytesIn    int64         `json:"bytes_in,omitempty" msg:"in,omitempty"`      // size of request body
+	BytesOut   int64         `json:"bytes_out,omitempty" msg:"out,omitempty"`    // size of response body
+	KillReason string        `json:"kill_reason,omitempty" msg:"kill,omitempty"` // reason of termination: timeout, canceled, limit, signal
 }
 
+// Reasons of process termination or rejection
+const (
+	KillTimeout  = "timeout"  // time limit exceeded
+	KillCanceled = "canceled" // request canceled (ex: client disconnected)
+	KillLimit    = "limit"    // rejected by concurrency limits
+	KillSignal   = "signal"   // killed by signal (ex: out of memory)
+)
+
 // Recorder for apps requests
 type Recorder interface {
 	// Track single recorder
 	Track(record Record)
 }
 
+// Monitor is optional extension of Recorder which tracks running invocations
+type Monitor interface {
+	// Started invocation of lambda. Returned function should be called once invocation finished
+	Started(uid, alias string) (done func())
+}
+
 // Reader from tracking systems. All returned records should be sorted from newest to oldest (by insertion moment)
 type Reader interface {
 	// Last records for specific app with limits
 	LastByUID(uid string, limit int) ([]Record, error)
 	// Last all records
 	Last(limit int) ([]Record, error)
+	// Search records by query
+	Search(query Query) ([]Record, error)
 }
 
 type Stats interface {
@@ -56,6 +56,42 @@ func (z *Record) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "End")
 				return
 			}
+		case "alias":
+			z.Alias, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Alias")
+				return
+			}
+		case "status":
+			z.Status, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "Status")
+				return
+			}
+		case "exit":
+			z.ExitCode, err = dc.ReadInt()
+			if err != nil {
+				err = msgp.WrapError(err, "ExitCode")
+				return
+			}
+		case "in":
+			z.BytesIn, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "BytesIn")
+				return
+			}
+		case "out":
+			z.BytesOut, err = dc.ReadInt64()
+			if err != nil {
+				err = msgp.WrapError(err, "BytesOut")
+				return
+			}
+		case "kill":
+			z.KillReason, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "KillReason")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -70,8 +106,9 @@ func (z *Record) DecodeMsg(dc *msgp.Reader) (err error) {
 // EncodeMsg implements msgp.Encodable
 func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 	// omitempty: check for empty values
-	zb0001Len := uint32(5)
-	var zb0001Mask uint8 /* 5 bits */
+	zb0001Len := uint32(11)
+	var zb0001Mask uint16 /* 11 bits */
+	_ = zb0001Mask
 	if z.UID == "" {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -88,6 +125,30 @@ func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 		zb0001Len--
 		zb0001Mask |= 0x10
 	}
+	if z.Alias == "" {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
+	if z.Status == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.ExitCode == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.BytesIn == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.BytesOut == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.KillReason == "" {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
 	// variable map header, size zb0001Len
 	err = en.Append(0x80 | uint8(zb0001Len))
 	if err != nil {
@@ -154,6 +215,78 @@ func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 			return
 		}
 	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// write "alias"
+		err = en.Append(0xa5, 0x61, 0x6c, 0x69, 0x61, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Alias)
+		if err != nil {
+			err = msgp.WrapError(err, "Alias")
+			return
+		}
+	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// write "status"
+		err = en.Append(0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt(z.Status)
+		if err != nil {
+			err = msgp.WrapError(err, "Status")
+			return
+		}
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// write "exit"
+		err = en.Append(0xa4, 0x65, 0x78, 0x69, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt(z.ExitCode)
+		if err != nil {
+			err = msgp.WrapError(err, "ExitCode")
+			return
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// write "in"
+		err = en.Append(0xa2, 0x69, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.BytesIn)
+		if err != nil {
+			err = msgp.WrapError(err, "BytesIn")
+			return
+		}
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// write "out"
+		err = en.Append(0xa3, 0x6f, 0x75, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteInt64(z.BytesOut)
+		if err != nil {
+			err = msgp.WrapError(err, "BytesOut")
+			return
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// write "kill"
+		err = en.Append(0xa4, 0x6b, 0x69, 0x6c, 0x6c)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.KillReason)
+		if err != nil {
+			err = msgp.WrapError(err, "KillReason")
+			return
+		}
+	}
 	return
 }
 
@@ -161,8 +294,9 @@ func (z *Record) EncodeMsg(en *msgp.Writer) (err error) {
 func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	// omitempty: check for empty values
-	zb0001Len := uint32(5)
-	var zb0001Mask uint8 /* 5 bits */
+	zb0001Len := uint32(11)
+	var zb0001Mask uint16 /* 11 bits */
+	_ = zb0001Mask
 	if z.UID == "" {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -179,6 +313,30 @@ func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 		zb0001Len--
 		zb0001Mask |= 0x10
 	}
+	if z.Alias == "" {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
+	if z.Status == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.ExitCode == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.BytesIn == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.BytesOut == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.KillReason == "" {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
 	// variable map header, size zb0001Len
 	o = append(o, 0x80|uint8(zb0001Len))
 	if zb0001Len == 0 {
@@ -211,6 +369,36 @@ func (z *Record) MarshalMsg(b []byte) (o []byte, err error) {
 		o = append(o, 0xa3, 0x65, 0x6e, 0x64)
 		o = msgp.AppendTime(o, z.End)
 	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// string "alias"
+		o = append(o, 0xa5, 0x61, 0x6c, 0x69, 0x61, 0x73)
+		o = msgp.AppendString(o, z.Alias)
+	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// string "status"
+		o = append(o, 0xa6, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73)
+		o = msgp.AppendInt(o, z.Status)
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// string "exit"
+		o = append(o, 0xa4, 0x65, 0x78, 0x69, 0x74)
+		o = msgp.AppendInt(o, z.ExitCode)
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// string "in"
+		o = append(o, 0xa2, 0x69, 0x6e)
+		o = msgp.AppendInt64(o, z.BytesIn)
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// string "out"
+		o = append(o, 0xa3, 0x6f, 0x75, 0x74)
+		o = msgp.AppendInt64(o, z.BytesOut)
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// string "kill"
+		o = append(o, 0xa4, 0x6b, 0x69, 0x6c, 0x6c)
+		o = msgp.AppendString(o, z.KillReason)
+	}
 	return
 }
 
@@ -262,6 +450,42 @@ func (z *Record) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "End")
 				return
 			}
+		case "alias":
+			z.Alias, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Alias")
+				return
+			}
+		case "status":
+			z.Status, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Status")
+				return
+			}
+		case "exit":
+			z.ExitCode, bts, err = msgp.ReadIntBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "ExitCode")
+				return
+			}
+		case "in":
+			z.BytesIn, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "BytesIn")
+				return
+			}
+		case "out":
+			z.BytesOut, bts, err = msgp.ReadInt64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "BytesOut")
+				return
+			}
+		case "kill":
+			z.KillReason, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "KillReason")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -276,6 +500,6 @@ func (z *Record) UnmarshalMsg(bts []byte) (o []byte, err error) {
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z *Record) Msgsize() (s int) {
-	s = 1 + 4 + msgp.StringPrefixSize + len(z.UID) + 4 + msgp.StringPrefixSize + len(z.Err) + 4 + z.Request.Msgsize() + 4 + msgp.TimeSize + 4 + msgp.TimeSize
+	s = 1 + 4 + msgp.StringPrefixSize + len(z.UID) + 4 + msgp.StringPrefixSize + len(z.Err) + 4 + z.Request.Msgsize() + 4 + msgp.TimeSize + 4 + msgp.TimeSize + 6 + msgp.StringPrefixSize + len(z.Alias) + 7 + msgp.IntSize + 5 + msgp.IntSize + 3 + msgp.Int64Size + 4 + msgp.Int64Size + 5 + msgp.StringPrefixSize + len(z.KillReason)
 	return
 }
@@ -0,0 +1,24 @@
+package stats
+
+// Multi recorder sends records to all recorders. Running invocations are reported to recorders which implement Monitor.
+type Multi []Recorder
+
+func (m Multi) Track(record Record) {
+	for _, recorder := range m {
+		recorder.Track(record)
+	}
+}
+
+func (m Multi) Started(uid, alias string) func() {
+	var finishers []func()
+	for _, recorder := range m {
+		if monitor, ok := recorder.(Monitor); ok {
+			finishers = append(finishers, monitor.Started(uid, alias))
+		}
+	}
+	return func() {
+		for _, done := range finishers {
+			done()
+		}
+	}
+}
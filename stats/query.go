@@ -0,0 +1,41 @@
+package stats
+
+import (
+	"strings"
+	"time"
+)
+
+// Query to records. Zero values of fields disable corresponding filters
+type Query struct {
+	UID        string    `json:"uid,omitempty"`         // lambda UID
+	From       time.Time `json:"from,omitempty"`        // minimal (inclusive) time of request begin
+	To         time.Time `json:"to,omitempty"`          // maximal (exclusive) time of request begin
+	ErrorsOnly bool      `json:"errors_only,omitempty"` // only failed requests
+	Method     string    `json:"method,omitempty"`      // HTTP method (case-insensitive)
+	Limit      int       `json:"limit,omitempty"`       // maximum number of records (zero or negative means unlimited)
+}
+
+// Match record to query (limit is not checked)
+func (q Query) Match(record Record) bool {
+	return q.MatchIndex(record.UID, record.Request.Method, record.Begin, record.Err != "")
+}
+
+// MatchIndex checks indexed fields of record (limit is not checked)
+func (q Query) MatchIndex(uid, method string, begin time.Time, failed bool) bool {
+	if q.UID != "" && q.UID != uid {
+		return false
+	}
+	if q.ErrorsOnly && !failed {
+		return false
+	}
+	if q.Method != "" && !strings.EqualFold(q.Method, method) {
+		return false
+	}
+	if !q.From.IsZero() && begin.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !begin.Before(q.To) {
+		return false
+	}
+	return true
+}
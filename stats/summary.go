@@ -0,0 +1,38 @@
+package stats
+
+import "time"
+
+// Aggregated statistics over time window
+type Summary struct {
+	UID           string    `json:"uid,omitempty"` // lambda UID, empty for whole project
+	From          time.Time `json:"from"`          // beginning of window (aligned to aggregation bucket)
+	To            time.Time `json:"to"`            // end of window
+	Requests      int64     `json:"requests"`      // number of requests
+	Errors        int64     `json:"errors"`        // number of failed requests
+	ErrorRatio    float64   `json:"error_ratio"`   // errors to requests ratio (0...1)
+	RatePerMinute float64   `json:"rate_per_minute"`
+	P50           float64   `json:"p50_ms"` // approximate 50th percentile of latency in milliseconds
+	P95           float64   `json:"p95_ms"` // approximate 95th percentile of latency in milliseconds
+	P99           float64   `json:"p99_ms"` // approximate 99th percentile of latency in milliseconds
+	TopCallers    []Caller  `json:"top_callers"`
+}
+
+// Number of requests from remote address
+type Caller struct {
+	Address  string `json:"address"` // remote address (empty for all other addresses which were not tracked)
+	Requests int64  `json:"requests"`
+}
+
+// Summary of project
+type ProjectSummary struct {
+	Total   Summary   `json:"total"`   // all requests
+	Lambdas []Summary `json:"lambdas"` // per lambda, ordered by number of requests (most requested first)
+}
+
+// Summarizer provides aggregated statistics for recent time window
+type Summarizer interface {
+	// Summary for lambda over window till now
+	Summary(uid string, window time.Duration) (*Summary, error)
+	// Summary for whole project over window till now
+	ProjectSummary(window time.Duration) (*ProjectSummary, error)
+}
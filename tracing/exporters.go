@@ -0,0 +1,223 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewFileExporter appends spans to the file as JSON objects, one per line. Useful for offline debugging and tests.
+func NewFileExporter(filename string) (*fileExporter, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+	return &fileExporter{file: f}, nil
+}
+
+type fileExporter struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// Span as it is written by file exporter
+type FileSpan struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         Kind                   `json:"kind"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+func (fe *fileExporter) Export(spans []SpanData) error {
+	var buffer bytes.Buffer
+	enc := json.NewEncoder(&buffer)
+	for _, span := range spans {
+		item := FileSpan{
+			TraceID:    span.TraceID.String(),
+			SpanID:     span.SpanID.String(),
+			Name:       span.Name,
+			Kind:       span.Kind,
+			Start:      span.Start,
+			End:        span.End,
+			Attributes: span.Attributes,
+			Error:      span.Error,
+		}
+		if span.ParentSpanID.IsValid() {
+			item.ParentSpanID = span.ParentSpanID.String()
+		}
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+	_, err := fe.file.Write(buffer.Bytes())
+	return err
+}
+
+func (fe *fileExporter) Close() error {
+	fe.lock.Lock()
+	defer fe.lock.Unlock()
+	return fe.file.Close()
+}
+
+// NewOTLPExporter sends spans to OpenTelemetry collector by OTLP/HTTP with JSON encoding. Endpoint is base URL of
+// collector (ex: http://localhost:4318), spans are sent to <endpoint>/v1/traces.
+func NewOTLPExporter(endpoint string, service string, headers map[string]string) *otlpExporter {
+	return &otlpExporter{
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service: service,
+		headers: headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type otlpExporter struct {
+	url     string
+	service string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (oe *otlpExporter) Export(spans []SpanData) error {
+	payload, err := json.Marshal(oe.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, oe.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range oe.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := oe.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", res.Status)
+	}
+	return nil
+}
+
+func (oe *otlpExporter) Close() error {
+	oe.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP JSON mapping of ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 - error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func (oe *otlpExporter) request(spans []SpanData) otlpRequest {
+	var items = make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		item := otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentSpanID.IsValid() {
+			item.ParentSpanID = span.ParentSpanID.String()
+		}
+		if span.Error != "" {
+			item.Status = &otlpStatus{Code: 2, Message: span.Error}
+		}
+		items = append(items, item)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name": oe.service,
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/reddec/trusted-cgi"},
+			Spans: items,
+		}},
+	}}}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	var ans = make([]otlpKeyValue, 0, len(attributes))
+	for k, v := range attributes {
+		var value = make(map[string]interface{}, 1)
+		switch val := v.(type) {
+		case bool:
+			value["boolValue"] = val
+		case int:
+			value["intValue"] = strconv.Itoa(val)
+		case int64:
+			value["intValue"] = strconv.FormatInt(val, 10)
+		case float64:
+			value["doubleValue"] = val
+		default:
+			value["stringValue"] = fmt.Sprint(val)
+		}
+		ans = append(ans, otlpKeyValue{Key: k, Value: value})
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Key < ans[j].Key
+	})
+	return ans
+}
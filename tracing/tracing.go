@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Kind of span (values are same as in OTLP)
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+	KindProducer Kind = 4
+	KindConsumer Kind = 5
+)
+
+const (
+	batchSize     = 256             // maximum number of spans in one export
+	batchInterval = 2 * time.Second // maximum delay before export
+	bufferSize    = 4096            // spans over this limit will be dropped
+)
+
+// Finished span
+type SpanData struct {
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID
+	Name         string
+	Kind         Kind
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{} // values are string, bool, int, int64 or float64
+	Error        string
+}
+
+// Exporter of finished spans
+type Exporter interface {
+	// Export batch of spans
+	Export(spans []SpanData) error
+	// Close exporter and release resources
+	Close() error
+}
+
+// New tracer which exports finished spans in background by batches. Tracer should be closed to flush pending spans.
+func New(exporter Exporter) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		spans:    make(chan SpanData, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+type Tracer struct {
+	exporter Exporter
+	spans    chan SpanData
+	done     chan struct{}
+	lock     sync.RWMutex
+	closed   bool
+}
+
+// Close tracer: exports pending spans and closes exporter. Spans finished after close are dropped.
+func (t *Tracer) Close() error {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.spans)
+	t.lock.Unlock()
+	<-t.done
+	return t.exporter.Close()
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			log.Println("[ERROR] tracing: export spans:", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- span:
+	default:
+		log.Println("[WARN] tracing: buffer is full - span dropped")
+	}
+}
+
+// Span in progress. All methods are safe for nil span (tracing disabled).
+type Span struct {
+	tracer *Tracer
+	flags  byte
+	lock   sync.Mutex
+	ended  bool
+	data   SpanData
+}
+
+// Context of span for propagation
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Flags: s.flags}
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+func (s *Span) SetKind(kind Kind) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Kind = kind
+}
+
+// End span with optional error. Only first call has effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if err != nil {
+		s.data.Error = err.Error()
+	}
+	data := s.data
+	s.lock.Unlock()
+	s.tracer.enqueue(data)
+}
+
+type ctxKey int
+
+const (
+	spanKey ctxKey = iota
+	tracerKey
+	remoteKey
+)
+
+// WithTracer returns context with tracer used for root spans.
+func WithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey, tracer)
+}
+
+// WithRemote returns context where remote span (defined by traceparent) will be the parent of new spans.
+// Invalid traceparent is ignored.
+func WithRemote(ctx context.Context, traceparent string) context.Context {
+	sc, ok := ParseTraceparent(traceparent)
+	if !ok {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, spanKey, (*Span)(nil))
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// Start new span as child of span in context (or remote span, see WithRemote). Returns nil span if there is no
+// tracer neither in parent span nor in context.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return StartAt(ctx, name, time.Now())
+}
+
+// StartAt is same as Start, but with defined start time.
+func StartAt(ctx context.Context, name string, begin time.Time) (context.Context, *Span) {
+	var span = &Span{
+		flags: FlagSampled,
+		data: SpanData{
+			SpanID: newSpanID(),
+			Name:   name,
+			Kind:   KindInternal,
+			Start:  begin,
+		},
+	}
+	if parent, _ := ctx.Value(spanKey).(*Span); parent != nil {
+		span.tracer = parent.tracer
+		span.flags = parent.flags
+		span.data.TraceID = parent.data.TraceID
+		span.data.ParentSpanID = parent.data.SpanID
+	} else if tracer, _ := ctx.Value(tracerKey).(*Tracer); tracer != nil {
+		span.tracer = tracer
+		if remote, ok := ctx.Value(remoteKey).(SpanContext); ok {
+			span.flags = remote.Flags
+			span.data.TraceID = remote.TraceID
+			span.data.ParentSpanID = remote.SpanID
+		} else {
+			span.data.TraceID = newTraceID()
+		}
+	} else {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// Traceparent of current span in context or of remote span. Empty if nothing defined.
+func Traceparent(ctx context.Context) string {
+	if span, _ := ctx.Value(spanKey).(*Span); span != nil {
+		return span.Context().Traceparent()
+	}
+	if remote, ok := ctx.Value(remoteKey).(SpanContext); ok {
+		return remote.Traceparent()
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memExporter struct {
+	spans  []SpanData
+	closed bool
+}
+
+func (me *memExporter) Export(spans []SpanData) error {
+	me.spans = append(me.spans, spans...)
+	return nil
+}
+
+func (me *memExporter) Close() error {
+	me.closed = true
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const value = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(value)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.Equal(t, FlagSampled, sc.Flags)
+	assert.Equal(t, value, sc.Traceparent())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+	// future versions may have more fields
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.True(t, ok)
+}
+
+func TestStart(t *testing.T) {
+	ctx, span := Start(context.Background(), "no tracer")
+	assert.Nil(t, span)
+	span.SetAttribute("key", "value")
+	span.End(nil)
+	assert.Empty(t, Traceparent(ctx))
+
+	var exporter memExporter
+	tracer := New(&exporter)
+	ctx = WithTracer(context.Background(), tracer)
+	ctx = WithRemote(ctx, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", Traceparent(ctx))
+
+	rootCtx, root := Start(ctx, "root")
+	root.SetKind(KindServer)
+	childCtx, child := Start(rootCtx, "child")
+	child.SetAttribute("answer", 42)
+	assert.Equal(t, child.Context().Traceparent(), Traceparent(childCtx))
+	child.End(errors.New("failed"))
+	child.End(nil) // ignored
+	root.End(nil)
+	require.NoError(t, tracer.Close())
+	assert.True(t, exporter.closed)
+
+	require.Len(t, exporter.spans, 2)
+	childData, rootData := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rootData.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", rootData.ParentSpanID.String())
+	assert.Equal(t, KindServer, rootData.Kind)
+	assert.Equal(t, rootData.TraceID, childData.TraceID)
+	assert.Equal(t, rootData.SpanID, childData.ParentSpanID)
+	assert.Equal(t, KindInternal, childData.Kind)
+	assert.Equal(t, "failed", childData.Error)
+	assert.Equal(t, 42, childData.Attributes["answer"])
+
+	// spans after close are dropped
+	_, late := Start(rootCtx, "late")
+	late.End(nil)
+	assert.Len(t, exporter.spans, 2)
+}
+
+func TestNewFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "traces.jsonl")
+
+	exporter, err := NewFileExporter(file)
+	require.NoError(t, err)
+	tracer := New(exporter)
+	ctx, root := Start(WithTracer(context.Background(), tracer), "root")
+	_, child := Start(ctx, "child")
+	child.End(nil)
+	root.End(errors.New("failed"))
+	require.NoError(t, tracer.Close())
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	var spans []FileSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span FileSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.Len(t, spans, 2)
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Equal(t, "failed", spans[1].Error)
+}
+
+func TestNewOTLPExporter(t *testing.T) {
+	var received otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/v1/traces", request.URL.Path)
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", request.Header.Get("X-Api-Key"))
+		assert.NoError(t, json.NewDecoder(request.Body).Decode(&received))
+	}))
+	defer collector.Close()
+
+	tracer := New(NewOTLPExporter(collector.URL+"/", "test-service", map[string]string{"X-Api-Key": "secret"}))
+	_, span := Start(WithTracer(context.Background(), tracer), "root")
+	span.SetAttribute("http.status_code", 200)
+	span.End(errors.New("failed"))
+	require.NoError(t, tracer.Close())
+
+	require.Len(t, received.ResourceSpans, 1)
+	resource := received.ResourceSpans[0]
+	assert.Equal(t, []otlpKeyValue{{Key: "service.name", Value: map[string]interface{}{"stringValue": "test-service"}}}, resource.Resource.Attributes)
+	require.Len(t, resource.ScopeSpans, 1)
+	require.Len(t, resource.ScopeSpans[0].Spans, 1)
+	sent := resource.ScopeSpans[0].Spans[0]
+	assert.Equal(t, span.Context().TraceID.String(), sent.TraceID)
+	assert.Equal(t, "root", sent.Name)
+	assert.Equal(t, []otlpKeyValue{{Key: "http.status_code", Value: map[string]interface{}{"intValue": "200"}}}, sent.Attributes)
+	require.NotNil(t, sent.Status)
+	assert.Equal(t, 2, sent.Status.Code)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -15,24 +16,37 @@ import (
 	"github.com/reddec/trusted-cgi/application/platform"
 	"github.com/reddec/trusted-cgi/application/policy"
 	"github.com/reddec/trusted-cgi/application/queuemanager"
+	"github.com/reddec/trusted-cgi/application/topicmanager"
 	"github.com/reddec/trusted-cgi/queue"
 	"github.com/reddec/trusted-cgi/queue/indir"
 	"github.com/reddec/trusted-cgi/server"
+	"github.com/reddec/trusted-cgi/stats"
 	"github.com/reddec/trusted-cgi/stats/impl/memlog"
+	"github.com/reddec/trusted-cgi/stats/impl/metrics"
+	"github.com/reddec/trusted-cgi/stats/impl/rollup"
+	"github.com/reddec/trusted-cgi/stats/impl/seglog"
+	"github.com/reddec/trusted-cgi/tracing"
+	"github.com/reddec/trusted-cgi/types"
 )
 
 const (
 	defPoliciesFile         = "policies.json"
 	defQueuesFile           = "queues.json"
+	defTopicsFile           = "topics.json"
 	defServerFile           = "server.json"
 	defProjectFile          = "project.json"
-	defStatsFile            = ".stats"
+	defStatsFile            = ".stats" // legacy dump, imported once to stats directory
+	defStatsDir             = ".stats.d"
 	defTemplatesDir         = ".templates"
 	defQueuesDir            = ".queues"
+	defDeadLettersDir       = ".queues/.dead-letters"
+	defJobsDir              = ".queues/.jobs"
 	defSshKey               = ".id_rsa"
 	defGracefulShutdown     = 10 * time.Second // time to wait for HTTP connections shutdown (if ListenAndServe were used)
 	defCfgPassword          = "admin"
 	defCfgStatsDepth        = 8192
+	defCfgStatsMaxAge       = 30 * 24 * time.Hour
+	defCfgStatsMaxSize      = 256 * 1024 * 1024
 	defCfgDumpInterval      = 30 * time.Second
 	defCfgSchedulerInterval = 30 * time.Second
 )
@@ -43,6 +57,8 @@ func Default() *Config {
 		dir:               ".",
 		password:          defCfgPassword,
 		statsDepth:        defCfgStatsDepth,
+		statsMaxAge:       defCfgStatsMaxAge,
+		statsMaxSize:      defCfgStatsMaxSize,
 		dumpInterval:      defCfgDumpInterval,
 		schedulerInterval: defCfgSchedulerInterval,
 		ssh:               true,
@@ -54,10 +70,15 @@ type Config struct {
 	ctx               context.Context
 	password          string
 	statsDepth        uint
+	statsMaxAge       time.Duration
+	statsMaxSize      int64
 	dumpInterval      time.Duration
 	schedulerInterval time.Duration
 	dir               string
 	ssh               bool
+	metrics           bool
+	metricsToken      string
+	traceExporter     tracing.Exporter
 }
 
 // Directory for project files.
@@ -84,6 +105,28 @@ func (cfg *Config) SSH(enable bool) *Config {
 	return cfg
 }
 
+// Retention of statistics: maximum age of records and maximum size of log in bytes. Zero value means unlimited.
+func (cfg *Config) StatsRetention(maxAge time.Duration, maxSize int64) *Config {
+	cfg.statsMaxAge = maxAge
+	cfg.statsMaxSize = maxSize
+	return cfg
+}
+
+// Metrics exposition on /metrics in Prometheus format. Non-empty token will be required as bearer token.
+// By default - disabled.
+func (cfg *Config) Metrics(enable bool, token string) *Config {
+	cfg.metrics = enable
+	cfg.metricsToken = token
+	return cfg
+}
+
+// Tracing of requests, queue tasks and lambda processes to the exporter. Exporter will be closed with the instance.
+// By default - disabled.
+func (cfg *Config) Tracing(exporter tracing.Exporter) *Config {
+	cfg.traceExporter = exporter
+	return cfg
+}
+
 // New instance of trusted-cgi using defaults storages and implementations.
 // Also initializes SSH key (if enabled). Starts supporting go-routines that will be stopped when context will be canceled.
 // The Done() channel can be used to determinate sub-routine termination.
@@ -107,14 +150,40 @@ func (cfg *Config) New() (*Instance, error) {
 	queueFactory := func(name string) (queue.Queue, error) {
 		return indir.New(filepath.Join(cfg.dir, defQueuesDir, name))
 	}
+	deadLetterFactory := func(name string) (queue.DeadLetters, error) {
+		return indir.NewDeadLetters(filepath.Join(cfg.dir, defDeadLettersDir, name))
+	}
+	jobsFactory := func(name string) (queue.Jobs, error) {
+		return indir.NewJobs(filepath.Join(cfg.dir, defJobsDir, name))
+	}
 
 	ctx, cancel := context.WithCancel(globalContext)
+	var wg sync.WaitGroup
+	if cfg.traceExporter != nil {
+		tracer := tracing.New(cfg.traceExporter)
+		ctx = tracing.WithTracer(ctx, tracer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+			_ = tracer.Close()
+		}()
+	}
 
-	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(cfg.dir, defQueuesFile)), basePlatform, queueFactory)
+	queueManager, err := queuemanager.New(ctx, queuemanager.FileConfig(filepath.Join(cfg.dir, defQueuesFile)), basePlatform, queueFactory, deadLetterFactory, jobsFactory)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initialize queues: %w", err)
 	}
+	topicManager, err := topicmanager.New(topicmanager.FileConfig(filepath.Join(cfg.dir, defTopicsFile)), queueManager)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("initialize topics: %w", err)
+	}
+	collector := metrics.New()
+	collector.Queues(queueManager)
+	collector.Lambdas(basePlatform)
+	queueManager.Observe(collector)
 
 	useCases, err := cases.New(basePlatform, queueManager, policies, cfg.dir, filepath.Join(cfg.dir, defTemplatesDir))
 	if err != nil {
@@ -130,32 +199,52 @@ func (cfg *Config) New() (*Instance, error) {
 		}
 	}
 
-	tracker, err := memlog.NewDumped(filepath.Join(cfg.dir, defStatsFile), cfg.statsDepth)
+	tracker, err := seglog.New(filepath.Join(cfg.dir, defStatsDir), cfg.statsMaxAge, cfg.statsMaxSize)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("initalize stats: %w", err)
 	}
+	if err := importStats(tracker, filepath.Join(cfg.dir, defStatsFile), cfg.statsDepth); err != nil {
+		_ = tracker.Close()
+		cancel()
+		return nil, fmt.Errorf("initalize stats: %w", err)
+	}
+	aggregates := rollup.New(rollup.DefaultRetention)
+	aggregates.Lambdas(basePlatform)
+	if err := aggregates.Import(tracker); err != nil {
+		_ = tracker.Close()
+		cancel()
+		return nil, fmt.Errorf("restore stats aggregates: %w", err)
+	}
 
-	projectApi := services.NewProjectSrv(useCases, tracker)
-	lambdaApi := services.NewLambdaSrv(useCases, tracker)
+	projectApi := services.NewProjectSrv(useCases, tracker, aggregates)
+	lambdaApi := services.NewLambdaSrv(useCases, tracker, aggregates)
 	queuesApi := services.NewQueuesSrv(queueManager)
+	topicsApi := services.NewTopicsSrv(topicManager)
 	policiesApi := services.NewPoliciesSrv(policies)
 	userApi, err := services.CreateUserSrv(filepath.Join(cfg.dir, defServerFile), cfg.password)
 	if err != nil {
+		_ = tracker.Close()
 		cancel()
 		return nil, fmt.Errorf("initialize admin API (user): %w", err)
 	}
-	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer tracker.Close()
 		dumpTracker(ctx, cfg.dumpInterval, tracker)
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		runScheduler(ctx, cfg.schedulerInterval, useCases)
+		dumpTracker(ctx, cfg.dumpInterval, policies) // last usage of tokens
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runScheduler(ctx, cfg.schedulerInterval, useCases, collector.Scheduled)
 	}()
 
 	done := make(chan struct{})
@@ -169,14 +258,19 @@ func (cfg *Config) New() (*Instance, error) {
 		Platform:     basePlatform,
 		Cases:        useCases,
 		Queues:       queueManager,
-		Tracker:      tracker,
+		Topics:       topicManager,
+		Tracker:      stats.Multi{tracker, collector, aggregates},
 		TokenHandler: userApi,
 		ProjectAPI:   projectApi,
 		LambdaAPI:    lambdaApi,
 		UserAPI:      userApi,
 		QueuesAPI:    queuesApi,
+		TopicsAPI:    topicsApi,
 		PoliciesAPI:  policiesApi,
 	}
+	if cfg.metrics {
+		srv.Metrics = collector.Handler(cfg.metricsToken)
+	}
 	return &Instance{
 		Location: cfg.dir,
 		server:   srv,
@@ -268,12 +362,27 @@ func dumpTracker(ctx context.Context, each time.Duration, tracker interface {
 		}
 		err := tracker.Dump()
 		if err != nil {
-			log.Println("[ERROR] failed to dump statistics:", err)
+			log.Println("[ERROR] failed to dump:", err)
 		}
 	}
 }
 
-func runScheduler(ctx context.Context, each time.Duration, runner application.Cases) {
+// import records from legacy dump (if exists) and rename dump to prevent repeated import
+func importStats(tracker interface{ Import(stats.Reader) error }, dumpFile string, depth uint) error {
+	if _, err := os.Stat(dumpFile); os.IsNotExist(err) {
+		return nil
+	}
+	dump, err := memlog.NewDumped(dumpFile, depth)
+	if err != nil {
+		return fmt.Errorf("read legacy stats: %w", err)
+	}
+	if err := tracker.Import(dump); err != nil {
+		return fmt.Errorf("import legacy stats: %w", err)
+	}
+	return os.Rename(dumpFile, dumpFile+".imported")
+}
+
+func runScheduler(ctx context.Context, each time.Duration, runner application.Cases, report func([]types.ScheduleResult)) {
 	t := time.NewTicker(each)
 	defer t.Stop()
 	for {
@@ -282,6 +391,6 @@ func runScheduler(ctx context.Context, each time.Duration, runner application.Ca
 		case <-ctx.Done():
 			return
 		}
-		runner.RunScheduledActions(ctx)
+		report(runner.RunScheduledActions(ctx))
 	}
 }
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+//go:generate msgp
+type DeadLetter struct {
+	ID       string    `json:"id" msg:"id"`
+	Queue    string    `json:"queue" msg:"queue"`       // origin queue
+	Reason   string    `json:"reason" msg:"reason"`     // error of the last attempt
+	Attempts int       `json:"attempts" msg:"attempts"` // number of used attempts
+	Failed   time.Time `json:"failed" msg:"failed"`     // time of the last attempt
+	Size     int64     `json:"size" msg:"size"`         // size of request body in bytes
+	Request  Request   `json:"request" msg:"request"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// Status of job
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+//go:generate msgp
+type Job struct {
+	ID        string    `json:"id" msg:"id"`
+	Queue     string    `json:"queue" msg:"queue"`
+	Status    string    `json:"status" msg:"status"`
+	Created   time.Time `json:"created" msg:"created"`               // time of enqueue
+	Updated   time.Time `json:"updated" msg:"updated"`               // time of the last status change
+	Attempts  int       `json:"attempts,omitempty" msg:"attempts"`   // number of used attempts
+	Error     string    `json:"error,omitempty" msg:"error"`         // error of the last attempt for failed job
+	Result    string    `json:"result,omitempty" msg:"result"`       // output of lambda
+	Truncated bool      `json:"truncated,omitempty" msg:"truncated"` // output exceeded limit and was cut
+}
+
+// Finished job will not change status anymore
+func (job *Job) Finished() bool {
+	return job.Status == JobDone || job.Status == JobFailed
+}
@@ -0,0 +1,22 @@
+package types
+
+import "time"
+
+// Kinds of log entries
+const (
+	LogRequest = "request" // invocation by request
+	LogAction  = "action"  // action (make target)
+	LogProcess = "process" // long-running process (worker or proxy backend)
+)
+
+// Captured output of lambda process
+type LogEntry struct {
+	ID        uint64    `json:"id"`                  // sequence number (per lambda)
+	Kind      string    `json:"kind"`                // request, action or process
+	Name      string    `json:"name,omitempty"`      // action name
+	Begin     time.Time `json:"begin"`               // start time
+	End       time.Time `json:"end"`                 // finish time
+	Output    string    `json:"output"`              // stderr (for actions - stdout and stderr)
+	Truncated bool      `json:"truncated,omitempty"` // output was bigger than limit
+	Err       string    `json:"error,omitempty"`     // execution error
+}
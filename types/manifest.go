@@ -24,6 +24,52 @@ type Manifest struct {
 	MaximumPayload int64             `json:"maximum_payload,omitempty"` // limit incoming payload (zero is unlimited)
 	Cron           []Schedule        `json:"cron,omitempty"`            // crontab expression and action name to invoke
 	Static         string            `json:"static,omitempty"`          // relative path to static folder
+	ParseHeaders   bool              `json:"parse_headers,omitempty"`   // parse CGI headers (Status, Location, ...) from the beginning of output
+	CGI            bool              `json:"cgi,omitempty"`             // expose standard CGI (RFC 3875) variables to environment
+	Sandbox        *Sandbox          `json:"sandbox,omitempty"`         // run lambda in isolated environment (linux only)
+	MaxConcurrency int               `json:"max_concurrency,omitempty"` // maximum parallel invocations (zero is platform default)
+	MaxWaiting     int               `json:"max_waiting,omitempty"`     // maximum requests waiting for free slot (zero is platform default, negative is no waiting)
+	WaitTimeout    JsonDuration      `json:"wait_timeout,omitempty"`    // maximum time to wait for free slot (zero is platform default)
+	Workers        *Workers          `json:"workers,omitempty"`         // keep long-running processes and pass requests to them
+	Proxy          *Proxy            `json:"proxy,omitempty"`           // forward requests to local HTTP server
+	Stream         bool              `json:"stream,omitempty"`          // send output to client as soon as it produced (disable buffering)
+	WebSocket      *WebSocket        `json:"websocket,omitempty"`       // accept WebSocket connections and bridge messages to stdin/stdout
+}
+
+// Framing of WebSocket messages in stdin/stdout
+const (
+	FramingLines  = "lines"  // each message is a line (default)
+	FramingLength = "length" // each message is prefixed by "<length>\n"
+)
+
+type WebSocket struct {
+	Framing    string `json:"framing,omitempty"`     // messages framing in stdin/stdout: lines (default) or length
+	Binary     bool   `json:"binary,omitempty"`      // send output as binary messages (text by default)
+	MaxMessage int64  `json:"max_message,omitempty"` // maximum size of message in bytes (default 1MiB)
+}
+
+type Proxy struct {
+	Socket       string       `json:"socket,omitempty"`        // unix socket path (relative to lambda dir)
+	Address      string       `json:"address,omitempty"`       // TCP address (host:port), used if socket is not set
+	StartTimeout JsonDuration `json:"start_timeout,omitempty"` // time to wait for started server readiness (default 10s)
+}
+
+type Workers struct {
+	Size        int          `json:"size,omitempty"`         // maximum number of workers (default 1)
+	MaxRequests int          `json:"max_requests,omitempty"` // restart worker after N requests (zero is unlimited)
+	IdleTimeout JsonDuration `json:"idle_timeout,omitempty"` // stop idle worker after timeout (zero is never)
+}
+
+type Sandbox struct {
+	Network bool    `json:"network,omitempty"` // keep access to the host network (isolated by default)
+	Memory  int64   `json:"memory,omitempty"`  // memory limit in bytes (zero is unlimited)
+	CPU     float64 `json:"cpu,omitempty"`     // CPU limit in cores, ex: 0.5 (zero is unlimited)
+	Pids    int     `json:"pids,omitempty"`    // maximum number of processes (zero is unlimited)
+}
+
+// Limits returns true if sandbox requires resources limitation (cgroups)
+func (sb *Sandbox) Limits() bool {
+	return sb.Memory > 0 || sb.CPU > 0 || sb.Pids > 0
 }
 
 type Schedule struct {
@@ -32,12 +78,24 @@ type Schedule struct {
 	TimeLimit JsonDuration `json:"time_limit"` // time limit to execute
 }
 
+// Outcome of scheduled action
+type ScheduleResult struct {
+	UID    string    // lambda UID
+	Action string    // invoked action
+	Begin  time.Time // start time
+	End    time.Time // finish time
+	Err    error     // execution error
+}
+
 func (mf *Manifest) Validate() error {
 	for _, entry := range mf.Cron {
 		if _, err := cron.Parse(entry.Cron); err != nil {
 			return fmt.Errorf("bad cront expression for action %s (%s): %w", entry.Action, entry.Cron, err)
 		}
 	}
+	if mf.WebSocket != nil && (mf.Workers != nil || mf.Proxy != nil) {
+		return fmt.Errorf("websocket is not supported for workers and proxy")
+	}
 	return nil
 }
 
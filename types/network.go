@@ -0,0 +1,82 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// List of IP networks. Single addresses are stored as networks with full mask.
+type Networks []*net.IPNet
+
+// Parse list of IP addresses or CIDR ranges (ex: 10.0.0.0/8, 2001:db8::/32, 127.0.0.1).
+func ParseNetworks(values ...string) (Networks, error) {
+	var ans = make(Networks, 0, len(values))
+	for _, value := range values {
+		network, err := ParseNetwork(value)
+		if err != nil {
+			return nil, err
+		}
+		ans = append(ans, network)
+	}
+	return ans, nil
+}
+
+// Parse IP address or CIDR range. IPv4-mapped IPv6 addresses are converted to IPv4.
+func ParseNetwork(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+	}
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+	}
+	if ip4 := ip.To4(); ip4 != nil && len(network.IP) == net.IPv6len {
+		// ::ffff:10.0.0.0/104 => 10.0.0.0/8
+		ones, _ := network.Mask.Size()
+		if ones < 96 {
+			return nil, fmt.Errorf("invalid CIDR %q: IPv4-mapped prefix is too short", value)
+		}
+		network = &net.IPNet{IP: ip4.Mask(net.CIDRMask(ones-96, 32)), Mask: net.CIDRMask(ones-96, 32)}
+	}
+	return network, nil
+}
+
+// Contains checks that IP is in at least one of networks.
+func (ns Networks) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range ns {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse IP address with optional port (ex: 127.0.0.1:1234, [::1]:1234, ::1). IPv4-mapped IPv6 addresses
+// (ex: ::ffff:127.0.0.1) are converted to IPv4. Returns nil for invalid address.
+func ParseIP(address string) net.IP {
+	address = strings.TrimSpace(address)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if idx := strings.IndexByte(address, '%'); idx >= 0 {
+		address = address[:idx] // drop IPv6 zone
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
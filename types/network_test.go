@@ -0,0 +1,46 @@
+package types
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromHTTP_trustedProxies(t *testing.T) {
+	proxies, err := ParseNetworks("127.0.0.1", "10.0.0.0/8", "::ffff:192.168.0.0/112")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		realIP    string
+		proxies   Networks
+		expected  string
+	}{
+		{name: "no proxies", remote: "127.0.0.1:1234", forwarded: []string{"1.2.3.4"}, expected: "127.0.0.1:1234"},
+		{name: "untrusted peer", remote: "8.8.8.8:1234", forwarded: []string{"1.2.3.4"}, proxies: proxies, expected: "8.8.8.8:1234"},
+		{name: "trusted peer", remote: "127.0.0.1:1234", forwarded: []string{"1.2.3.4"}, proxies: proxies, expected: "1.2.3.4"},
+		{name: "spoofed chain", remote: "127.0.0.1:1234", forwarded: []string{"6.6.6.6, 1.2.3.4, 10.1.1.1"}, proxies: proxies, expected: "1.2.3.4"},
+		{name: "multiple headers", remote: "127.0.0.1:1234", forwarded: []string{"6.6.6.6", "1.2.3.4"}, proxies: proxies, expected: "1.2.3.4"},
+		{name: "mapped proxy", remote: "192.168.1.1:1234", forwarded: []string{"::ffff:1.2.3.4"}, proxies: proxies, expected: "1.2.3.4"},
+		{name: "real ip", remote: "127.0.0.1:1234", realIP: "1.2.3.4", proxies: proxies, expected: "1.2.3.4"},
+		{name: "all trusted", remote: "127.0.0.1:1234", forwarded: []string{"10.1.1.1"}, proxies: proxies, expected: "10.1.1.1"},
+		{name: "malformed", remote: "127.0.0.1:1234", forwarded: []string{"1.2.3.4, garbage"}, proxies: proxies, expected: "127.0.0.1:1234"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = c.remote
+			for _, v := range c.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if c.realIP != "" {
+				r.Header.Set("X-Real-Ip", c.realIP)
+			}
+			if got := FromHTTP(r, c.proxies).RemoteAddress; got != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+}
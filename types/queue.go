@@ -0,0 +1,9 @@
+package types
+
+// Stored request of queue (without body)
+type QueueItem struct {
+	ID      uint64  `json:"id"`
+	Leased  bool    `json:"leased"` // request is processing by worker right now (in-flight)
+	Size    int64   `json:"size"`   // stored size (including headers) in bytes
+	Request Request `json:"request"`
+}
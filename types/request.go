@@ -4,36 +4,45 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 //go:generate msgp
 type Request struct {
-	Method        string            `json:"method" msg:"method"`
-	URL           string            `json:"url" msg:"url"`
-	Path          string            `json:"path" msg:"path"`
-	RemoteAddress string            `json:"remote_address" msg:"remote_address"`
-	Form          map[string]string `json:"form" msg:"form"`
-	Headers       map[string]string `json:"headers" msg:"headers"`
-	Body          io.ReadCloser     `json:"-" msg:"-"`
+	Method        string              `json:"method" msg:"method"`
+	URL           string              `json:"url" msg:"url"`
+	Path          string              `json:"path" msg:"path"`
+	RemoteAddress string              `json:"remote_address" msg:"remote_address"`
+	Form          map[string]string   `json:"form" msg:"form"`
+	Headers       map[string]string   `json:"headers" msg:"headers"`
+	FormValues    map[string][]string `json:"form_values,omitempty" msg:"form_values,omitempty"`     // all values of form fields
+	HeaderValues  map[string][]string `json:"header_values,omitempty" msg:"header_values,omitempty"` // all values of headers
+	Traceparent   string              `json:"traceparent,omitempty" msg:"traceparent,omitempty"`     // W3C trace context of queued request
+	DeliverAt     *time.Time          `json:"deliver_at,omitempty" msg:"deliver_at,omitempty"`       // queued request should not be processed before the time
+	Claims        map[string]string   `json:"claims,omitempty" msg:"claims,omitempty"`               // claims of verified JWT (set by policy)
+	Body          io.ReadCloser       `json:"-" msg:"-"`
 }
 
-// Create request from HTTP request
-func FromHTTP(r *http.Request, behindProxy bool) *Request {
+// Create request from HTTP request. X-Forwarded-For and X-Real-Ip headers are respected only if request came from
+// one of trusted proxies.
+func FromHTTP(r *http.Request, trustedProxies Networks) *Request {
 	_ = r.ParseForm()
 	var vals = make(map[string]string)
+	var formValues = make(map[string][]string)
 	for k, v := range r.Form {
 		vals[k] = v[0]
+		formValues[k] = append([]string(nil), v...)
 	}
 	var headers = make(map[string]string)
+	var headerValues = make(map[string][]string)
 	for k, v := range r.Header {
 		headers[k] = v[0]
+		headerValues[k] = append([]string(nil), v...)
 	}
-	var address string
-	if behindProxy {
-		address = getRequestAddress(r)
-	} else {
-		address = r.RemoteAddr
+	if r.Host != "" {
+		headers["Host"] = r.Host // Go moves Host header to the dedicated field
 	}
+	address := getRequestAddress(r, trustedProxies)
 	return &Request{
 		Method:        r.Method,
 		URL:           r.RequestURI,
@@ -41,10 +50,52 @@ func FromHTTP(r *http.Request, behindProxy bool) *Request {
 		RemoteAddress: address,
 		Form:          vals,
 		Headers:       headers,
+		FormValues:    formValues,
+		HeaderValues:  headerValues,
 		Body:          r.Body,
 	}
 }
 
+// All values of the header. Falls back to the single value for requests without multiple values (ex: legacy).
+func (z *Request) HeaderAll(name string) []string {
+	if values, ok := z.HeaderValues[name]; ok {
+		return values
+	}
+	if value, ok := z.Headers[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// All values of the form field. Falls back to the single value for requests without multiple values (ex: legacy).
+func (z *Request) FormAll(name string) []string {
+	if values, ok := z.FormValues[name]; ok {
+		return values
+	}
+	if value, ok := z.Form[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// Set single value of the header. Maps are copied, so shallow copies of request are not affected.
+func (z *Request) SetHeader(name, value string) {
+	headers := make(map[string]string, len(z.Headers)+1)
+	for k, v := range z.Headers {
+		headers[k] = v
+	}
+	headers[name] = value
+	z.Headers = headers
+	if z.HeaderValues != nil {
+		values := make(map[string][]string, len(z.HeaderValues)+1)
+		for k, v := range z.HeaderValues {
+			values[k] = v
+		}
+		values[name] = []string{value}
+		z.HeaderValues = values
+	}
+}
+
 // Returns shallow copy of request with new body
 func (z *Request) WithBody(reader io.ReadCloser) *Request {
 	if z == nil {
@@ -55,17 +106,34 @@ func (z *Request) WithBody(reader io.ReadCloser) *Request {
 	return &cp
 }
 
-func getRequestAddress(r *http.Request) string {
-	address := getFirstHeader(r, r.RemoteAddr, "X-Real-Ip", "X-Forwarded-For")
-	address = strings.TrimSpace(strings.SplitN(address, ",", 2)[0])
-	return address
-}
-
-func getFirstHeader(r *http.Request, defaultValue string, headers ...string) string {
-	for _, h := range headers {
-		if v := r.Header.Get(h); v != "" {
-			return v
+// Client address is the right-most address in the chain that is not a trusted proxy.
+func getRequestAddress(r *http.Request, trustedProxies Networks) string {
+	if len(trustedProxies) == 0 || !trustedProxies.Contains(ParseIP(r.RemoteAddr)) {
+		return r.RemoteAddr
+	}
+	var chain []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				chain = append(chain, address)
+			}
 		}
 	}
-	return defaultValue
+	if len(chain) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); realIP != "" {
+			chain = append(chain, realIP)
+		}
+	}
+	var address = r.RemoteAddr
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := ParseIP(chain[i])
+		if ip == nil {
+			break // malformed chain - keep last trusted hop
+		}
+		address = ip.String()
+		if !trustedProxies.Contains(ip) {
+			break
+		}
+	}
+	return address
 }
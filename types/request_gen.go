@@ -3,6 +3,8 @@ package types
 // Code generated by github.com/tinylib/msgp DO NOT EDIT.
 
 import (
+	"time"
+
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -108,6 +110,146 @@ func (z *Request) DecodeMsg(dc *msgp.Reader) (err error) {
 				}
 				z.Headers[za0003] = za0004
 			}
+		case "form_values":
+			var zb0004 uint32
+			zb0004, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "FormValues")
+				return
+			}
+			if z.FormValues == nil {
+				z.FormValues = make(map[string][]string, zb0004)
+			} else if len(z.FormValues) > 0 {
+				for key := range z.FormValues {
+					delete(z.FormValues, key)
+				}
+			}
+			for zb0004 > 0 {
+				zb0004--
+				var za0005 string
+				var za0006 []string
+				za0005, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "FormValues")
+					return
+				}
+				var zb0005 uint32
+				zb0005, err = dc.ReadArrayHeader()
+				if err != nil {
+					err = msgp.WrapError(err, "FormValues", za0005)
+					return
+				}
+				if cap(za0006) >= int(zb0005) {
+					za0006 = (za0006)[:zb0005]
+				} else {
+					za0006 = make([]string, zb0005)
+				}
+				for za0007 := range za0006 {
+					za0006[za0007], err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "FormValues", za0005, za0007)
+						return
+					}
+				}
+				z.FormValues[za0005] = za0006
+			}
+		case "header_values":
+			var zb0006 uint32
+			zb0006, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "HeaderValues")
+				return
+			}
+			if z.HeaderValues == nil {
+				z.HeaderValues = make(map[string][]string, zb0006)
+			} else if len(z.HeaderValues) > 0 {
+				for key := range z.HeaderValues {
+					delete(z.HeaderValues, key)
+				}
+			}
+			for zb0006 > 0 {
+				zb0006--
+				var za0008 string
+				var za0009 []string
+				za0008, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "HeaderValues")
+					return
+				}
+				var zb0007 uint32
+				zb0007, err = dc.ReadArrayHeader()
+				if err != nil {
+					err = msgp.WrapError(err, "HeaderValues", za0008)
+					return
+				}
+				if cap(za0009) >= int(zb0007) {
+					za0009 = (za0009)[:zb0007]
+				} else {
+					za0009 = make([]string, zb0007)
+				}
+				for za0010 := range za0009 {
+					za0009[za0010], err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "HeaderValues", za0008, za0010)
+						return
+					}
+				}
+				z.HeaderValues[za0008] = za0009
+			}
+		case "traceparent":
+			z.Traceparent, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "Traceparent")
+				return
+			}
+		case "deliver_at":
+			if dc.IsNil() {
+				err = dc.ReadNil()
+				if err != nil {
+					err = msgp.WrapError(err, "DeliverAt")
+					return
+				}
+				z.DeliverAt = nil
+			} else {
+				if z.DeliverAt == nil {
+					z.DeliverAt = new(time.Time)
+				}
+				*z.DeliverAt, err = dc.ReadTime()
+				if err != nil {
+					err = msgp.WrapError(err, "DeliverAt")
+					return
+				}
+			}
+		case "claims":
+			var zb0008 uint32
+			zb0008, err = dc.ReadMapHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			if z.Claims == nil {
+				z.Claims = make(map[string]string, zb0008)
+			} else if len(z.Claims) > 0 {
+				for key := range z.Claims {
+					delete(z.Claims, key)
+				}
+			}
+			for zb0008 > 0 {
+				zb0008--
+				var za0011 string
+				var za0012 string
+				za0011, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Claims")
+					return
+				}
+				za0012, err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Claims", za0011)
+					return
+				}
+				z.Claims[za0011] = za0012
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -121,9 +263,40 @@ func (z *Request) DecodeMsg(dc *msgp.Reader) (err error) {
 
 // EncodeMsg implements msgp.Encodable
 func (z *Request) EncodeMsg(en *msgp.Writer) (err error) {
-	// map header, size 6
+	// omitempty: check for empty values
+	zb0001Len := uint32(11)
+	var zb0001Mask uint16 /* 11 bits */
+	_ = zb0001Mask
+	if z.FormValues == nil {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.HeaderValues == nil {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Traceparent == "" {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.DeliverAt == nil {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Claims == nil {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	// variable map header, size zb0001Len
+	err = en.Append(0x80 | uint8(zb0001Len))
+	if err != nil {
+		return
+	}
+	if zb0001Len == 0 {
+		return
+	}
 	// write "method"
-	err = en.Append(0x86, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
+	err = en.Append(0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
 	if err != nil {
 		return
 	}
@@ -206,15 +379,160 @@ func (z *Request) EncodeMsg(en *msgp.Writer) (err error) {
 			return
 		}
 	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// write "form_values"
+		err = en.Append(0xab, 0x66, 0x6f, 0x72, 0x6d, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteMapHeader(uint32(len(z.FormValues)))
+		if err != nil {
+			err = msgp.WrapError(err, "FormValues")
+			return
+		}
+		for za0005, za0006 := range z.FormValues {
+			err = en.WriteString(za0005)
+			if err != nil {
+				err = msgp.WrapError(err, "FormValues")
+				return
+			}
+			err = en.WriteArrayHeader(uint32(len(za0006)))
+			if err != nil {
+				err = msgp.WrapError(err, "FormValues", za0005)
+				return
+			}
+			for za0007 := range za0006 {
+				err = en.WriteString(za0006[za0007])
+				if err != nil {
+					err = msgp.WrapError(err, "FormValues", za0005, za0007)
+					return
+				}
+			}
+		}
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// write "header_values"
+		err = en.Append(0xad, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteMapHeader(uint32(len(z.HeaderValues)))
+		if err != nil {
+			err = msgp.WrapError(err, "HeaderValues")
+			return
+		}
+		for za0008, za0009 := range z.HeaderValues {
+			err = en.WriteString(za0008)
+			if err != nil {
+				err = msgp.WrapError(err, "HeaderValues")
+				return
+			}
+			err = en.WriteArrayHeader(uint32(len(za0009)))
+			if err != nil {
+				err = msgp.WrapError(err, "HeaderValues", za0008)
+				return
+			}
+			for za0010 := range za0009 {
+				err = en.WriteString(za0009[za0010])
+				if err != nil {
+					err = msgp.WrapError(err, "HeaderValues", za0008, za0010)
+					return
+				}
+			}
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// write "traceparent"
+		err = en.Append(0xab, 0x74, 0x72, 0x61, 0x63, 0x65, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.Traceparent)
+		if err != nil {
+			err = msgp.WrapError(err, "Traceparent")
+			return
+		}
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// write "deliver_at"
+		err = en.Append(0xaa, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x5f, 0x61, 0x74)
+		if err != nil {
+			return
+		}
+		if z.DeliverAt == nil {
+			err = en.WriteNil()
+			if err != nil {
+				return
+			}
+		} else {
+			err = en.WriteTime(*z.DeliverAt)
+			if err != nil {
+				err = msgp.WrapError(err, "DeliverAt")
+				return
+			}
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// write "claims"
+		err = en.Append(0xa6, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73)
+		if err != nil {
+			return
+		}
+		err = en.WriteMapHeader(uint32(len(z.Claims)))
+		if err != nil {
+			err = msgp.WrapError(err, "Claims")
+			return
+		}
+		for za0011, za0012 := range z.Claims {
+			err = en.WriteString(za0011)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			err = en.WriteString(za0012)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims", za0011)
+				return
+			}
+		}
+	}
 	return
 }
 
 // MarshalMsg implements msgp.Marshaler
 func (z *Request) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
-	// map header, size 6
+	// omitempty: check for empty values
+	zb0001Len := uint32(11)
+	var zb0001Mask uint16 /* 11 bits */
+	_ = zb0001Mask
+	if z.FormValues == nil {
+		zb0001Len--
+		zb0001Mask |= 0x40
+	}
+	if z.HeaderValues == nil {
+		zb0001Len--
+		zb0001Mask |= 0x80
+	}
+	if z.Traceparent == "" {
+		zb0001Len--
+		zb0001Mask |= 0x100
+	}
+	if z.DeliverAt == nil {
+		zb0001Len--
+		zb0001Mask |= 0x200
+	}
+	if z.Claims == nil {
+		zb0001Len--
+		zb0001Mask |= 0x400
+	}
+	// variable map header, size zb0001Len
+	o = append(o, 0x80|uint8(zb0001Len))
+	if zb0001Len == 0 {
+		return
+	}
 	// string "method"
-	o = append(o, 0x86, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
+	o = append(o, 0xa6, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64)
 	o = msgp.AppendString(o, z.Method)
 	// string "url"
 	o = append(o, 0xa3, 0x75, 0x72, 0x6c)
@@ -239,6 +557,53 @@ func (z *Request) MarshalMsg(b []byte) (o []byte, err error) {
 		o = msgp.AppendString(o, za0003)
 		o = msgp.AppendString(o, za0004)
 	}
+	if (zb0001Mask & 0x40) == 0 { // if not empty
+		// string "form_values"
+		o = append(o, 0xab, 0x66, 0x6f, 0x72, 0x6d, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73)
+		o = msgp.AppendMapHeader(o, uint32(len(z.FormValues)))
+		for za0005, za0006 := range z.FormValues {
+			o = msgp.AppendString(o, za0005)
+			o = msgp.AppendArrayHeader(o, uint32(len(za0006)))
+			for za0007 := range za0006 {
+				o = msgp.AppendString(o, za0006[za0007])
+			}
+		}
+	}
+	if (zb0001Mask & 0x80) == 0 { // if not empty
+		// string "header_values"
+		o = append(o, 0xad, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73)
+		o = msgp.AppendMapHeader(o, uint32(len(z.HeaderValues)))
+		for za0008, za0009 := range z.HeaderValues {
+			o = msgp.AppendString(o, za0008)
+			o = msgp.AppendArrayHeader(o, uint32(len(za0009)))
+			for za0010 := range za0009 {
+				o = msgp.AppendString(o, za0009[za0010])
+			}
+		}
+	}
+	if (zb0001Mask & 0x100) == 0 { // if not empty
+		// string "traceparent"
+		o = append(o, 0xab, 0x74, 0x72, 0x61, 0x63, 0x65, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74)
+		o = msgp.AppendString(o, z.Traceparent)
+	}
+	if (zb0001Mask & 0x200) == 0 { // if not empty
+		// string "deliver_at"
+		o = append(o, 0xaa, 0x64, 0x65, 0x6c, 0x69, 0x76, 0x65, 0x72, 0x5f, 0x61, 0x74)
+		if z.DeliverAt == nil {
+			o = msgp.AppendNil(o)
+		} else {
+			o = msgp.AppendTime(o, *z.DeliverAt)
+		}
+	}
+	if (zb0001Mask & 0x400) == 0 { // if not empty
+		// string "claims"
+		o = append(o, 0xa6, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73)
+		o = msgp.AppendMapHeader(o, uint32(len(z.Claims)))
+		for za0011, za0012 := range z.Claims {
+			o = msgp.AppendString(o, za0011)
+			o = msgp.AppendString(o, za0012)
+		}
+	}
 	return
 }
 
@@ -344,6 +709,145 @@ func (z *Request) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				}
 				z.Headers[za0003] = za0004
 			}
+		case "form_values":
+			var zb0004 uint32
+			zb0004, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "FormValues")
+				return
+			}
+			if z.FormValues == nil {
+				z.FormValues = make(map[string][]string, zb0004)
+			} else if len(z.FormValues) > 0 {
+				for key := range z.FormValues {
+					delete(z.FormValues, key)
+				}
+			}
+			for zb0004 > 0 {
+				var za0005 string
+				var za0006 []string
+				zb0004--
+				za0005, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "FormValues")
+					return
+				}
+				var zb0005 uint32
+				zb0005, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "FormValues", za0005)
+					return
+				}
+				if cap(za0006) >= int(zb0005) {
+					za0006 = (za0006)[:zb0005]
+				} else {
+					za0006 = make([]string, zb0005)
+				}
+				for za0007 := range za0006 {
+					za0006[za0007], bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "FormValues", za0005, za0007)
+						return
+					}
+				}
+				z.FormValues[za0005] = za0006
+			}
+		case "header_values":
+			var zb0006 uint32
+			zb0006, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "HeaderValues")
+				return
+			}
+			if z.HeaderValues == nil {
+				z.HeaderValues = make(map[string][]string, zb0006)
+			} else if len(z.HeaderValues) > 0 {
+				for key := range z.HeaderValues {
+					delete(z.HeaderValues, key)
+				}
+			}
+			for zb0006 > 0 {
+				var za0008 string
+				var za0009 []string
+				zb0006--
+				za0008, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "HeaderValues")
+					return
+				}
+				var zb0007 uint32
+				zb0007, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "HeaderValues", za0008)
+					return
+				}
+				if cap(za0009) >= int(zb0007) {
+					za0009 = (za0009)[:zb0007]
+				} else {
+					za0009 = make([]string, zb0007)
+				}
+				for za0010 := range za0009 {
+					za0009[za0010], bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "HeaderValues", za0008, za0010)
+						return
+					}
+				}
+				z.HeaderValues[za0008] = za0009
+			}
+		case "traceparent":
+			z.Traceparent, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Traceparent")
+				return
+			}
+		case "deliver_at":
+			if msgp.IsNil(bts) {
+				bts, err = msgp.ReadNilBytes(bts)
+				if err != nil {
+					return
+				}
+				z.DeliverAt = nil
+			} else {
+				if z.DeliverAt == nil {
+					z.DeliverAt = new(time.Time)
+				}
+				*z.DeliverAt, bts, err = msgp.ReadTimeBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "DeliverAt")
+					return
+				}
+			}
+		case "claims":
+			var zb0008 uint32
+			zb0008, bts, err = msgp.ReadMapHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Claims")
+				return
+			}
+			if z.Claims == nil {
+				z.Claims = make(map[string]string, zb0008)
+			} else if len(z.Claims) > 0 {
+				for key := range z.Claims {
+					delete(z.Claims, key)
+				}
+			}
+			for zb0008 > 0 {
+				var za0011 string
+				var za0012 string
+				zb0008--
+				za0011, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Claims")
+					return
+				}
+				za0012, bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Claims", za0011)
+					return
+				}
+				z.Claims[za0011] = za0012
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -372,5 +876,38 @@ func (z *Request) Msgsize() (s int) {
 			s += msgp.StringPrefixSize + len(za0003) + msgp.StringPrefixSize + len(za0004)
 		}
 	}
+	s += 12 + msgp.MapHeaderSize
+	if z.FormValues != nil {
+		for za0005, za0006 := range z.FormValues {
+			_ = za0006
+			s += msgp.StringPrefixSize + len(za0005) + msgp.ArrayHeaderSize
+			for za0007 := range za0006 {
+				s += msgp.StringPrefixSize + len(za0006[za0007])
+			}
+		}
+	}
+	s += 14 + msgp.MapHeaderSize
+	if z.HeaderValues != nil {
+		for za0008, za0009 := range z.HeaderValues {
+			_ = za0009
+			s += msgp.StringPrefixSize + len(za0008) + msgp.ArrayHeaderSize
+			for za0010 := range za0009 {
+				s += msgp.StringPrefixSize + len(za0009[za0010])
+			}
+		}
+	}
+	s += 12 + msgp.StringPrefixSize + len(z.Traceparent) + 11
+	if z.DeliverAt == nil {
+		s += msgp.NilSize
+	} else {
+		s += msgp.TimeSize
+	}
+	s += 7 + msgp.MapHeaderSize
+	if z.Claims != nil {
+		for za0011, za0012 := range z.Claims {
+			_ = za0012
+			s += msgp.StringPrefixSize + len(za0011) + msgp.StringPrefixSize + len(za0012)
+		}
+	}
 	return
 }